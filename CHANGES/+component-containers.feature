@@ -0,0 +1 @@
+Added `init_containers` and `sidecar_containers` fields to api, content, worker, web, database and cache to run additional containers in Pulp pods.
\ No newline at end of file
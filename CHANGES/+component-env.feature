@@ -0,0 +1 @@
+Added `env` and `env_from` fields to pulp components to inject extra environment variables.
\ No newline at end of file
@@ -0,0 +1 @@
+Added `volumes` and `volume_mounts` fields to api, content, worker, web, database and cache to mount additional volumes in Pulp pods.
\ No newline at end of file
@@ -0,0 +1 @@
+Added `database.metrics` to deploy a postgres_exporter sidecar and a ServiceMonitor for the database managed by the operator.
\ No newline at end of file
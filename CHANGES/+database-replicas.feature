@@ -0,0 +1 @@
+Added `database.replicas` to deploy PostgreSQL hot standby replicas with streaming replication and a read-only service.
\ No newline at end of file
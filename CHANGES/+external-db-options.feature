@@ -0,0 +1 @@
+Added support to client certificates, CONN_MAX_AGE and extra libpq options in the external database secret (also used by PulpBackup). IAM authentication is not supported.
\ No newline at end of file
@@ -0,0 +1 @@
+Added `autoscaling` to api, content and web to deploy a HorizontalPodAutoscaler for these components.
\ No newline at end of file
@@ -0,0 +1 @@
+Added support to image references with digest, `api.image`, `content.image` and `worker.image` fields to override the pulpcore image of each component and `.status.images` with the images running in the pods.
\ No newline at end of file
@@ -0,0 +1 @@
+Added a Job to run the database migrations before rolling out a new pulpcore image and the `Pulp-Migration-Complete` condition.
\ No newline at end of file
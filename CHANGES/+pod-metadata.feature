@@ -0,0 +1 @@
+Added `common_labels` field to add labels to all the objects created by the operator and `pod_labels` and `pod_annotations` fields to pulp components.
\ No newline at end of file
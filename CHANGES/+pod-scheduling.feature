@@ -0,0 +1 @@
+Added `priority_class_name`, `runtime_class_name`, `termination_grace_period_seconds` and `host_aliases` fields to pulp components and a preStop hook that stops pulp-worker pods gracefully after their running task.
\ No newline at end of file
@@ -0,0 +1 @@
+Added `database.postgres_parameters` to configure postgresql.conf of the database deployed by the operator.
\ No newline at end of file
@@ -0,0 +1 @@
+Added `profile` field with deployment size profiles (dev, small, medium and large) that define the default replicas, resources, PDBs and pod anti-affinity of the components.
\ No newline at end of file
@@ -0,0 +1 @@
+Added `cache.redis_config` to configure the memory limit, eviction policy and persistence of the managed Redis.
\ No newline at end of file
@@ -0,0 +1 @@
+Protected the managed Redis instance with a generated password that can be rotated through the `-redis-password` secret.
\ No newline at end of file
@@ -0,0 +1 @@
+Added `cache.mode: sentinel` to deploy a highly available Redis with Sentinel.
\ No newline at end of file
@@ -0,0 +1 @@
+Added TLS support for the Redis instance deployed by the operator (`cache.tls`) and for external Redis installations (`REDIS_SSL`, `REDIS_CA_CERT` and `REDIS_URL`).
\ No newline at end of file
@@ -0,0 +1 @@
+The operator now watches the secrets referenced in Pulp CR (external database, external cache, object storage and SSO) and redeploys pulpcore pods with the new settings when they are modified.
\ No newline at end of file
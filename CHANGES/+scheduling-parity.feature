@@ -0,0 +1 @@
+Added `affinity` (with pod affinity and pod anti-affinity), `tolerations`, `topology_spread_constraints` and `strategy` fields to the web, database and cache components where they were missing.
\ No newline at end of file
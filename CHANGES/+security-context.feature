@@ -0,0 +1 @@
+Added `pod_security_context` and `security_context` fields to pulp components (and to PulpBackup and PulpRestore CRs) and deployed the pulpcore and pulp-web pods with "restricted" Pod Security Standard compliant security contexts by default. The Redis, Postgres and backup-manager pods keep running with the user from their images unless their security contexts are defined.
\ No newline at end of file
@@ -0,0 +1 @@
+Added a default liveness probe to pulp-web and `startupProbe` field to all components. The restore now waits (up to 3 minutes, without blocking the reconciliation) for the restored pulp-api and pulp-web replicas to be ready (pulp-web readiness probe checks pulp-api status through nginx) instead of sleeping for a minute. The state of the wait is reported in the `DeploymentsReady` condition of PulpRestore.
\ No newline at end of file
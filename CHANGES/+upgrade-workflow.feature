@@ -0,0 +1 @@
+Added an upgrade workflow that drains the workers, runs the migrations and rolls out api, content and workers in order, rolling back to the previous image if a step fails. The progress is reported in `status.upgrade`.
\ No newline at end of file
@@ -0,0 +1 @@
+Added `vpa` field to api, content, worker, database and cache to create VerticalPodAutoscalers and report their resource recommendations in Pulp CR status.
\ No newline at end of file
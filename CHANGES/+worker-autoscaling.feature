@@ -0,0 +1 @@
+Added `worker.autoscaling` to scale the pulp-worker pods based on the number of tasks in Pulp queue.
\ No newline at end of file
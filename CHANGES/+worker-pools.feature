@@ -0,0 +1 @@
+Added `worker_pools` field to deploy additional pools of pulp-workers with their own replicas, resources, placement, environment variables and PDB.
\ No newline at end of file
@@ -18,8 +18,10 @@ package v1alpha1
 
 import (
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
 	policy "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -37,6 +39,15 @@ type PulpSpec struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:hidden"}
 	DeploymentType string `json:"deployment_type,omitempty"`
 
+	// Deployment size profile used as the default for the replicas, resource requirements,
+	// gunicorn workers, PDBs and pod anti-affinity of the components. Fields explicitly
+	// defined in pulp CR always take precedence over the profile. The medium and large
+	// profiles are highly available (multiple replicas spread across nodes and PDBs).
+	// +kubebuilder:validation:Enum:=dev;small;medium;large
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:dev","urn:alm:descriptor:com.tectonic.ui:select:small","urn:alm:descriptor:com.tectonic.ui:select:medium","urn:alm:descriptor:com.tectonic.ui:select:large"}
+	Profile string `json:"profile,omitempty"`
+
 	// The size of the file storage; for example 100Gi.
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:fieldDependency:storage_type:File"}
 	FileStorageSize string `json:"file_storage_size,omitempty"`
@@ -135,12 +146,15 @@ type PulpSpec struct {
 	ContainerAuthPrivateKey string `json:"container_auth_private_key_name,omitempty"`
 
 	// The image name (repo name) for the pulp image.
+	// It can also be a reference with a digest (for example, quay.io/pulp/pulp@sha256:...),
+	// in which case image_version is ignored.
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default:="quay.io/pulp/pulp"
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
 	Image string `json:"image,omitempty"`
 
 	// The image version for the pulp image.
+	// It can be a tag or a digest (sha256:...).
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default:="stable"
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
@@ -168,6 +182,13 @@ type PulpSpec struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
 	Worker Worker `json:"worker,omitempty"`
 
+	// Additional pools of pulp-workers. Each pool is deployed in its own <name>-worker-<pool name>
+	// deployment with the settings from worker and the replicas, resources, placement and
+	// environment variables defined for the pool.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	WorkerPools []WorkerPool `json:"worker_pools,omitempty"`
+
 	//+kubebuilder:validation:Optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:hidden"}
 	Web Web `json:"web,omitempty"`
@@ -183,12 +204,14 @@ type PulpSpec struct {
 	PulpSettings runtime.RawExtension `json:"pulp_settings,omitempty"`
 
 	// The image name (repo name) for the pulp webserver image.
+	// It can also be a reference with a digest, in which case image_web_version is ignored.
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default:="quay.io/pulp/pulp-web"
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:hidden"}
 	ImageWeb string `json:"image_web,omitempty"`
 
 	// The image version for the pulp webserver image.
+	// It can be a tag or a digest (sha256:...).
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default:="stable"
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:hidden"}
@@ -214,22 +237,45 @@ type PulpSpec struct {
 	// +kubebuilder:default:=false
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:hidden"}
 	TrustedCa bool `json:"mount_trusted_ca,omitempty"`
+
+	// Labels added to all the objects (and pods) created by the operator.
+	// The labels defined by the operator take precedence over them.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	CommonLabels map[string]string `json:"common_labels,omitempty"`
 }
 
 type Affinity struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:nodeAffinity","urn:alm:descriptor:com.tectonic.ui:advanced"}
 	*corev1.NodeAffinity `json:"nodeAffinity,omitempty" protobuf:"bytes,1,opt,name=nodeAffinity"`
+
+	// Describes pod affinity scheduling rules (e.g. co-locate this pod in the same node, zone, etc. as some other pod(s)).
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podAffinity","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodAffinity *corev1.PodAffinity `json:"podAffinity,omitempty"`
+
+	// Describes pod anti-affinity scheduling rules (e.g. avoid putting this pod in the same node, zone, etc. as some other pod(s)).
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podAntiAffinity","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodAntiAffinity *corev1.PodAntiAffinity `json:"podAntiAffinity,omitempty"`
 }
 
 type Api struct {
 
 	// Size is the size of number of pulp-api replicas.
-	// +kubebuilder:default:=1
+	// If not defined, the value from profile is used or 1 if no profile is defined.
 	// +kubebuilder:validation:Minimum:=0
 	// +kubebuilder:validation:Optional
 	// +nullable
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podCount"}
-	Replicas int32 `json:"replicas"`
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Image (with a tag or a digest) of the pulp-api containers. If defined, it is used instead of
+	// image and image_version. The database migrations also run with this image and its modifications
+	// are rolled out through the upgrade process, like the ones of image and image_version.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Image string `json:"image,omitempty"`
 
 	// Defines various deployment affinities.
 	// +kubebuilder:validation:Optional
@@ -258,10 +304,10 @@ type Api struct {
 	GunicornTimeout int `json:"gunicorn_timeout,omitempty"`
 
 	// The number of gunicorn workers to use for the api.
-	// +kubebuilder:default:=2
+	// If not defined, the value from profile is used or 2 if no profile is defined.
 	// +kubebuilder:validation:Optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:com.tectonic.ui:advanced"}
-	GunicornWorkers int `json:"gunicorn_workers,omitempty"`
+	GunicornWorkers *int `json:"gunicorn_workers,omitempty"`
 
 	// Resource requirements for the pulp api container.
 	// +kubebuilder:validation:Optional
@@ -280,25 +326,132 @@ type Api struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:Probe","urn:alm:descriptor:com.tectonic.ui:advanced"}
 	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
 
+	// Probe that indicates that the container has successfully started.
+	// Liveness and readiness probes are not executed until it succeeds, which avoids the
+	// container to be restarted during a slow first boot.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:Probe","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	StartupProbe *corev1.Probe `json:"startupProbe,omitempty"`
+
 	// PodDisruptionBudget is an object to define the max disruption that can be caused to a collection of pods
 	// +optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:hidden"}
 	PDB *policy.PodDisruptionBudgetSpec `json:"pdb,omitempty"`
 
+	// Horizontal pod autoscaling of the pulp-api pods. When defined, the operator creates a
+	// HorizontalPodAutoscaler and stops enforcing the number of replicas of the deployment.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Autoscaling *Autoscaling `json:"autoscaling,omitempty"`
+
+	// Vertical pod autoscaling of the pulp-api container. When defined and the autoscaling.k8s.io API is
+	// available, the operator creates a VerticalPodAutoscaler that recommends (or sets) the resources
+	// of the container. The recommendations are stored in .status.resource_recommendations.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	VPA *VPA `json:"vpa,omitempty"`
+
 	// The deployment strategy to use to replace existing pods with new ones.
 	// +optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:updateStrategy","urn:alm:descriptor:com.tectonic.ui:advanced"}
 	Strategy appsv1.DeploymentStrategy `json:"strategy,omitempty"`
+
+	// Environment variables added to the pulp-api container after the ones defined by the operator.
+	// A variable with the same name as one defined by the operator overrides it.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// Sources (configmaps or secrets) of environment variables for the pulp-api container.
+	// Variables defined in env, or by the operator, take precedence over the ones from env_from.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	EnvFrom []corev1.EnvFromSource `json:"env_from,omitempty"`
+
+	// Additional volumes added to the pulp-api pods.
+	// The names of the volumes defined by the operator are reserved and cannot be used.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+
+	// Additional volume mounts added to the pulp-api container.
+	// The mount paths used by the operator are reserved and cannot be used.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	VolumeMounts []corev1.VolumeMount `json:"volume_mounts,omitempty"`
+
+	// Init containers added to the pulp-api pods. They run before the operator init containers (if any)
+	// and can mount the volumes defined by the operator or in volumes by name.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	InitContainers []corev1.Container `json:"init_containers,omitempty"`
+
+	// Sidecar containers added to the pulp-api pods, after the container defined by the operator.
+	// They can mount the volumes defined by the operator or in volumes by name.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	SidecarContainers []corev1.Container `json:"sidecar_containers,omitempty"`
+
+	// Security context of the pulp-api pods. By default, the pods run as a non-root user with the
+	// RuntimeDefault seccomp profile (restricted Pod Security Standard).
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodSecurityContext *corev1.PodSecurityContext `json:"pod_security_context,omitempty"`
+
+	// Security context of the pulp-api container. By default, privilege escalation is not allowed
+	// and all capabilities are dropped (restricted Pod Security Standard).
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	SecurityContext *corev1.SecurityContext `json:"security_context,omitempty"`
+
+	// Name of the PriorityClass of the pulp-api pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PriorityClassName string `json:"priority_class_name,omitempty"`
+
+	// Name of the RuntimeClass used to run the pulp-api pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	RuntimeClassName *string `json:"runtime_class_name,omitempty"`
+
+	// Duration in seconds the pulp-api pods need to terminate gracefully. Default: 30
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	TerminationGracePeriodSeconds *int64 `json:"termination_grace_period_seconds,omitempty"`
+
+	// Entries added to the /etc/hosts file of the pulp-api pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	HostAliases []corev1.HostAlias `json:"host_aliases,omitempty"`
+
+	// Annotations added to the pulp-api pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodAnnotations map[string]string `json:"pod_annotations,omitempty"`
+
+	// Labels added to the pulp-api pods.
+	// The labels defined by the operator (used by the selectors) take precedence over them.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodLabels map[string]string `json:"pod_labels,omitempty"`
 }
 
 type Content struct {
-	// Size is the size of number of pulp-content replicas
-	// +kubebuilder:default:=2
+	// Size is the size of number of pulp-content replicas.
+	// If not defined, the value from profile is used or 2 if no profile is defined.
 	// +kubebuilder:validation:Minimum:=0
 	// +kubebuilder:validation:Optional
 	// +nullable
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podCount"}
-	Replicas int32 `json:"replicas"`
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Image (with a tag or a digest) of the pulp-content containers. If defined, it is used instead of
+	// image and image_version. The database migrations also run with this image and its modifications
+	// are rolled out through the upgrade process, like the ones of image and image_version.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Image string `json:"image,omitempty"`
 
 	// Resource requirements for the pulp-content container
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:resourceRequirements","urn:alm:descriptor:com.tectonic.ui:advanced"}
@@ -331,10 +484,10 @@ type Content struct {
 	GunicornTimeout int `json:"gunicorn_timeout,omitempty"`
 
 	// The number of gunicorn workers to use for the api.
-	// +kubebuilder:default:=2
+	// If not defined, the value from profile is used or 2 if no profile is defined.
 	// +kubebuilder:validation:Optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:com.tectonic.ui:advanced"}
-	GunicornWorkers int `json:"gunicorn_workers,omitempty"`
+	GunicornWorkers *int `json:"gunicorn_workers,omitempty"`
 
 	// Periodic probe of container service readiness.
 	// Container will be removed from service endpoints if the probe fails.
@@ -348,25 +501,132 @@ type Content struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:Probe","urn:alm:descriptor:com.tectonic.ui:advanced"}
 	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
 
+	// Probe that indicates that the container has successfully started.
+	// Liveness and readiness probes are not executed until it succeeds, which avoids the
+	// container to be restarted during a slow first boot.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:Probe","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	StartupProbe *corev1.Probe `json:"startupProbe,omitempty"`
+
 	// PodDisruptionBudget is an object to define the max disruption that can be caused to a collection of pods
 	// +optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:hidden"}
 	PDB *policy.PodDisruptionBudgetSpec `json:"pdb,omitempty"`
 
+	// Horizontal pod autoscaling of the pulp-content pods. When defined, the operator creates a
+	// HorizontalPodAutoscaler and stops enforcing the number of replicas of the deployment.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Autoscaling *Autoscaling `json:"autoscaling,omitempty"`
+
+	// Vertical pod autoscaling of the pulp-content container. When defined and the autoscaling.k8s.io API is
+	// available, the operator creates a VerticalPodAutoscaler that recommends (or sets) the resources
+	// of the container. The recommendations are stored in .status.resource_recommendations.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	VPA *VPA `json:"vpa,omitempty"`
+
 	// The deployment strategy to use to replace existing pods with new ones.
 	// +optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:updateStrategy","urn:alm:descriptor:com.tectonic.ui:advanced"}
 	Strategy appsv1.DeploymentStrategy `json:"strategy,omitempty"`
+
+	// Environment variables added to the pulp-content container after the ones defined by the operator.
+	// A variable with the same name as one defined by the operator overrides it.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// Sources (configmaps or secrets) of environment variables for the pulp-content container.
+	// Variables defined in env, or by the operator, take precedence over the ones from env_from.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	EnvFrom []corev1.EnvFromSource `json:"env_from,omitempty"`
+
+	// Additional volumes added to the pulp-content pods.
+	// The names of the volumes defined by the operator are reserved and cannot be used.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+
+	// Additional volume mounts added to the pulp-content container.
+	// The mount paths used by the operator are reserved and cannot be used.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	VolumeMounts []corev1.VolumeMount `json:"volume_mounts,omitempty"`
+
+	// Init containers added to the pulp-content pods. They run before the operator init containers (if any)
+	// and can mount the volumes defined by the operator or in volumes by name.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	InitContainers []corev1.Container `json:"init_containers,omitempty"`
+
+	// Sidecar containers added to the pulp-content pods, after the container defined by the operator.
+	// They can mount the volumes defined by the operator or in volumes by name.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	SidecarContainers []corev1.Container `json:"sidecar_containers,omitempty"`
+
+	// Security context of the pulp-content pods. By default, the pods run as a non-root user with the
+	// RuntimeDefault seccomp profile (restricted Pod Security Standard).
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodSecurityContext *corev1.PodSecurityContext `json:"pod_security_context,omitempty"`
+
+	// Security context of the pulp-content container. By default, privilege escalation is not allowed
+	// and all capabilities are dropped (restricted Pod Security Standard).
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	SecurityContext *corev1.SecurityContext `json:"security_context,omitempty"`
+
+	// Name of the PriorityClass of the pulp-content pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PriorityClassName string `json:"priority_class_name,omitempty"`
+
+	// Name of the RuntimeClass used to run the pulp-content pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	RuntimeClassName *string `json:"runtime_class_name,omitempty"`
+
+	// Duration in seconds the pulp-content pods need to terminate gracefully. Default: 30
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	TerminationGracePeriodSeconds *int64 `json:"termination_grace_period_seconds,omitempty"`
+
+	// Entries added to the /etc/hosts file of the pulp-content pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	HostAliases []corev1.HostAlias `json:"host_aliases,omitempty"`
+
+	// Annotations added to the pulp-content pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodAnnotations map[string]string `json:"pod_annotations,omitempty"`
+
+	// Labels added to the pulp-content pods.
+	// The labels defined by the operator (used by the selectors) take precedence over them.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodLabels map[string]string `json:"pod_labels,omitempty"`
 }
 
 type Worker struct {
-	// Size is the size of number of pulp-worker replicas
-	// +kubebuilder:default:=2
+	// Size is the size of number of pulp-worker replicas.
+	// If not defined, the value from profile is used or 2 if no profile is defined.
 	// +kubebuilder:validation:Minimum:=0
 	// +kubebuilder:validation:Optional
 	// +nullable
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podCount"}
-	Replicas int32 `json:"replicas"`
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Image (with a tag or a digest) of the pulp-worker containers. If defined, it is used instead of
+	// image and image_version. The database migrations also run with this image and its modifications
+	// are rolled out through the upgrade process, like the ones of image and image_version.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Image string `json:"image,omitempty"`
 
 	// Resource requirements for the pulp-api container
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:resourceRequirements","urn:alm:descriptor:com.tectonic.ui:advanced"}
@@ -404,151 +664,538 @@ type Worker struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:Probe","urn:alm:descriptor:com.tectonic.ui:advanced"}
 	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
 
+	// Probe that indicates that the container has successfully started.
+	// Liveness and readiness probes are not executed until it succeeds, which avoids the
+	// container to be restarted during a slow first boot.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:Probe","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	StartupProbe *corev1.Probe `json:"startupProbe,omitempty"`
+
 	// PodDisruptionBudget is an object to define the max disruption that can be caused to a collection of pods
 	// +optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:hidden"}
 	PDB *policy.PodDisruptionBudgetSpec `json:"pdb,omitempty"`
 
+	// Scale the pulp-worker pods based on the number of tasks in Pulp queue. When defined,
+	// the operator stops enforcing the number of replicas of the deployment.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Autoscaling *WorkerAutoscaling `json:"autoscaling,omitempty"`
+
+	// Vertical pod autoscaling of the pulp-worker container. When defined and the autoscaling.k8s.io API is
+	// available, the operator creates a VerticalPodAutoscaler that recommends (or sets) the resources
+	// of the container. The recommendations are stored in .status.resource_recommendations.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	VPA *VPA `json:"vpa,omitempty"`
+
 	// The deployment strategy to use to replace existing pods with new ones.
 	// +optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:updateStrategy","urn:alm:descriptor:com.tectonic.ui:advanced"}
 	Strategy appsv1.DeploymentStrategy `json:"strategy,omitempty"`
-}
 
-type Web struct {
-	// Size is the size of number of pulp-web replicas
-	// +kubebuilder:default:=1
-	// +kubebuilder:validation:Minimum:=0
+	// Environment variables added to the pulp-worker container after the ones defined by the operator.
+	// A variable with the same name as one defined by the operator overrides it.
 	// +kubebuilder:validation:Optional
-	// +nullable
-	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podCount"}
-	Replicas int32 `json:"replicas"`
-
-	// Resource requirements for the pulp-web container
-	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:resourceRequirements","urn:alm:descriptor:com.tectonic.ui:advanced"}
-	ResourceRequirements corev1.ResourceRequirements `json:"resource_requirements,omitempty"`
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Env []corev1.EnvVar `json:"env,omitempty"`
 
-	// Periodic probe of container service readiness.
-	// Container will be removed from service endpoints if the probe fails.
+	// Sources (configmaps or secrets) of environment variables for the pulp-worker container.
+	// Variables defined in env, or by the operator, take precedence over the ones from env_from.
 	// +kubebuilder:validation:Optional
-	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:Probe","urn:alm:descriptor:com.tectonic.ui:advanced"}
-	ReadinessProbe *corev1.Probe `json:"readinessProbe,omitempty"`
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	EnvFrom []corev1.EnvFromSource `json:"env_from,omitempty"`
 
-	// Periodic probe of container liveness.
-	// Container will be restarted if the probe fails.
+	// Additional volumes added to the pulp-worker pods.
+	// The names of the volumes defined by the operator are reserved and cannot be used.
 	// +kubebuilder:validation:Optional
-	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:Probe","urn:alm:descriptor:com.tectonic.ui:advanced"}
-	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
 
-	// NodeSelector for the Web pods.
+	// Additional volume mounts added to the pulp-worker container.
+	// The mount paths used by the operator are reserved and cannot be used.
 	// +kubebuilder:validation:Optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
-	NodeSelector map[string]string `json:"node_selector,omitempty"`
-
-	// PodDisruptionBudget is an object to define the max disruption that can be caused to a collection of pods
-	// +optional
-	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:hidden"}
-	PDB *policy.PodDisruptionBudgetSpec `json:"pdb,omitempty"`
-}
-
-type ExternalDB struct {
-	PostgresPort     int    `json:"postgres_port,omitempty"`
-	PostgresSSLMode  string `json:"postgres_ssl_mode,omitempty"`
-	PostgresHost     string `json:"postgres_host,omitempty"`
-	PostgresUser     string `json:"postgres_user,omitempty"`
-	PostgresPassword string `json:"postgres_password,omitempty"`
-	PostgresDBName   string `json:"postgres_db_name,omitempty"`
+	VolumeMounts []corev1.VolumeMount `json:"volume_mounts,omitempty"`
 
+	// Init containers added to the pulp-worker pods. They run before the operator init containers (if any)
+	// and can mount the volumes defined by the operator or in volumes by name.
 	// +kubebuilder:validation:Optional
-	// +operator-sdk:csv:customresourcedefinitions:type=spec
-	PostgresConMaxAge string `json:"postgres_con_max_age,omitempty"`
-}
-
-type Database struct {
-	// Size is the size of number of db replicas
-	// The default postgres image does not provide clustering
-	//Replicas int32 `json:"replicas,omitempty"`
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	InitContainers []corev1.Container `json:"init_containers,omitempty"`
 
-	// Secret name with the configuration to use an external database
+	// Sidecar containers added to the pulp-worker pods, after the container defined by the operator.
+	// They can mount the volumes defined by the operator or in volumes by name.
 	// +kubebuilder:validation:Optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
-	ExternalDBSecret string `json:"external_db_secret,omitempty"`
+	SidecarContainers []corev1.Container `json:"sidecar_containers,omitempty"`
 
-	// PostgreSQL version [default: "13"]
+	// Security context of the pulp-worker pods. By default, the pods run as a non-root user with the
+	// RuntimeDefault seccomp profile (restricted Pod Security Standard).
 	// +kubebuilder:validation:Optional
-	// +operator-sdk:csv:customresourcedefinitions:type=spec
-	PostgresVersion string `json:"version,omitempty"`
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodSecurityContext *corev1.PodSecurityContext `json:"pod_security_context,omitempty"`
 
-	// PostgreSQL port [default: 5432]
-	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
-	PostgresPort int `json:"postgres_port,omitempty"`
+	// Security context of the pulp-worker container. By default, privilege escalation is not allowed
+	// and all capabilities are dropped (restricted Pod Security Standard).
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	SecurityContext *corev1.SecurityContext `json:"security_context,omitempty"`
 
-	// Configure PostgreSQL connection sslmode option [default: "prefer"]
+	// Name of the PriorityClass of the pulp-worker pods.
 	// +kubebuilder:validation:Optional
-	// +operator-sdk:csv:customresourcedefinitions:type=spec
-	PostgresSSLMode string `json:"postgres_ssl_mode,omitempty"`
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PriorityClassName string `json:"priority_class_name,omitempty"`
 
-	// PostgreSQL container image [default: "postgres:13"]
-	// +operator-sdk:csv:customresourcedefinitions:type=spec
-	PostgresImage string `json:"postgres_image,omitempty"`
+	// Name of the RuntimeClass used to run the pulp-worker pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	RuntimeClassName *string `json:"runtime_class_name,omitempty"`
 
+	// Duration in seconds the pulp-worker pods need to terminate gracefully. Default: 600
+	// The pulp-worker pods wait (in a preStop hook) for the tasks they are running to finish
+	// before stopping, so this should be longer than the expected duration of the tasks.
 	// +kubebuilder:validation:Optional
-	// +operator-sdk:csv:customresourcedefinitions:type=spec
-	PostgresExtraArgs []string `json:"postgres_extra_args,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	TerminationGracePeriodSeconds *int64 `json:"termination_grace_period_seconds,omitempty"`
 
-	// Registry path to the PostgreSQL container to use [default: "/var/lib/postgresql/data/pgdata"]
+	// Entries added to the /etc/hosts file of the pulp-worker pods.
 	// +kubebuilder:validation:Optional
-	// +operator-sdk:csv:customresourcedefinitions:type=spec
-	PostgresDataPath string `json:"postgres_data_path,omitempty"`
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	HostAliases []corev1.HostAlias `json:"host_aliases,omitempty"`
 
-	// Arguments to pass to PostgreSQL initdb command when creating a new cluster. [default: "--auth-host=scram-sha-256"]
+	// Annotations added to the pulp-worker pods.
 	// +kubebuilder:validation:Optional
-	// +operator-sdk:csv:customresourcedefinitions:type=spec
-	PostgresInitdbArgs string `json:"postgres_initdb_args,omitempty"`
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodAnnotations map[string]string `json:"pod_annotations,omitempty"`
 
-	// PostgreSQL host authentication method [default: "scram-sha-256"]
+	// Labels added to the pulp-worker pods.
+	// The labels defined by the operator (used by the selectors) take precedence over them.
 	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodLabels map[string]string `json:"pod_labels,omitempty"`
+}
+
+// WorkerPool defines a pool of pulp-workers with its own resources and placement
+type WorkerPool struct {
+	// Name of the pool. It is used in the name of the pool deployment and PDB.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern:=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength:=40
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
-	PostgresHostAuthMethod string `json:"postgres_host_auth_method,omitempty"`
+	Name string `json:"name"`
 
-	// Resource requirements for the database container.
+	// Size is the size of number of pulp-worker replicas of the pool.
+	// +kubebuilder:default:=1
+	// +kubebuilder:validation:Minimum:=0
 	// +kubebuilder:validation:Optional
-	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:resourceRequirements","urn:alm:descriptor:com.tectonic.ui:advanced"}
-	ResourceRequirements corev1.ResourceRequirements `json:"postgres_resource_requirements,omitempty"`
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podCount"}
+	Replicas int32 `json:"replicas"`
 
-	// Defines various deployment affinities.
+	// Resource requirements for the pulp-worker containers of the pool.
+	// If not defined, the ones from worker are used.
 	// +kubebuilder:validation:Optional
-	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
-	Affinity Affinity `json:"affinity,omitempty"`
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:resourceRequirements","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	ResourceRequirements *corev1.ResourceRequirements `json:"resource_requirements,omitempty"`
 
-	// NodeSelector for the database pod.
+	// NodeSelector for the pulp-worker pods of the pool.
+	// If not defined, the one from worker is used.
 	// +kubebuilder:validation:Optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
 	NodeSelector map[string]string `json:"node_selector,omitempty"`
 
-	// Node tolerations for the database pod.
+	// Node tolerations for the pulp-worker pods of the pool.
+	// If not defined, the ones from worker are used.
 	// +kubebuilder:validation:Optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
 	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
 
-	// Temporarily modifying it as a string to avoid an issue with backup and json.Unmarshal
-	// when set as resource.Quantity and no value passed on pulp CR, during backup steps
-	// json.Unmarshal is settings it with "0"
+	// Environment variables added to the pulp-worker containers of the pool after the ones
+	// from worker.env (a variable with the same name overrides them).
 	// +kubebuilder:validation:Optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
-	PostgresStorageRequirements string `json:"postgres_storage_requirements,omitempty"`
+	Env []corev1.EnvVar `json:"env,omitempty"`
 
-	// Name of the StorageClass required by the claim.
-	// +kubebuilder:validation:Optional
-	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:StorageClass","urn:alm:descriptor:com.tectonic.ui:advanced"}
-	PostgresStorageClass *string `json:"postgres_storage_class,omitempty"`
+	// PodDisruptionBudget of the pulp-worker pods of the pool
+	// +optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:hidden"}
+	PDB *policy.PodDisruptionBudgetSpec `json:"pdb,omitempty"`
+}
 
-	// PersistenVolumeClaim name that will be used by database pods
-	// If defined, the PVC must be provisioned by the user and the operator will only
-	// configure the deployment to use it
+// WorkerAutoscaling defines how the pulp-worker pods are scaled based on the tasks queue
+type WorkerAutoscaling struct {
+	// Minimum number of pulp-worker replicas. [default: 1]
+	// +kubebuilder:default:=1
+	// +kubebuilder:validation:Minimum:=0
 	// +kubebuilder:validation:Optional
-	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:PersistentVolumeClaim","urn:alm:descriptor:com.tectonic.ui:advanced"}
-	PVC string `json:"pvc,omitempty"`
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podCount"}
+	Min int32 `json:"min,omitempty"`
+
+	// Maximum number of pulp-worker replicas.
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Required
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podCount"}
+	Max int32 `json:"max"`
+
+	// Number of waiting tasks each additional worker is expected to handle. [default: 2]
+	// +kubebuilder:default:=2
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	TasksPerWorker int32 `json:"tasks_per_worker,omitempty"`
+
+	// Interval, in seconds, between two queries to Pulp tasks API. [default: 30]
+	// +kubebuilder:default:=30
+	// +kubebuilder:validation:Minimum:=5
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	PollInterval int32 `json:"poll_interval,omitempty"`
+
+	// Minimum time, in seconds, between a scaling operation and a scale down. [default: 300]
+	// +kubebuilder:default:=300
+	// +kubebuilder:validation:Minimum:=0
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	ScaleDownCooldown *int32 `json:"scale_down_cooldown,omitempty"`
+}
+
+type Web struct {
+	// Size is the size of number of pulp-web replicas.
+	// If not defined, the value from profile is used or 1 if no profile is defined.
+	// +kubebuilder:validation:Minimum:=0
+	// +kubebuilder:validation:Optional
+	// +nullable
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podCount"}
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Resource requirements for the pulp-web container
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:resourceRequirements","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	ResourceRequirements corev1.ResourceRequirements `json:"resource_requirements,omitempty"`
+
+	// Periodic probe of container service readiness.
+	// Container will be removed from service endpoints if the probe fails.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:Probe","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	ReadinessProbe *corev1.Probe `json:"readinessProbe,omitempty"`
+
+	// Periodic probe of container liveness.
+	// Container will be restarted if the probe fails.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:Probe","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
+
+	// Probe that indicates that the container has successfully started.
+	// Liveness and readiness probes are not executed until it succeeds, which avoids the
+	// container to be restarted during a slow first boot.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:Probe","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	StartupProbe *corev1.Probe `json:"startupProbe,omitempty"`
+
+	// Defines various deployment affinities.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Affinity Affinity `json:"affinity,omitempty"`
+
+	// NodeSelector for the Web pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	NodeSelector map[string]string `json:"node_selector,omitempty"`
+
+	// Node tolerations for the Web pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Topology rule(s) for the pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topology_spread_constraints,omitempty"`
+
+	// The deployment strategy to use to replace existing pods with new ones.
+	// +optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:updateStrategy","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Strategy appsv1.DeploymentStrategy `json:"strategy,omitempty"`
+
+	// PodDisruptionBudget is an object to define the max disruption that can be caused to a collection of pods
+	// +optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:hidden"}
+	PDB *policy.PodDisruptionBudgetSpec `json:"pdb,omitempty"`
+
+	// Horizontal pod autoscaling of the pulp-web pods. When defined, the operator creates a
+	// HorizontalPodAutoscaler and stops enforcing the number of replicas of the deployment.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Autoscaling *Autoscaling `json:"autoscaling,omitempty"`
+
+	// Environment variables added to the pulp-web container after the ones defined by the operator.
+	// A variable with the same name as one defined by the operator overrides it.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// Sources (configmaps or secrets) of environment variables for the pulp-web container.
+	// Variables defined in env, or by the operator, take precedence over the ones from env_from.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	EnvFrom []corev1.EnvFromSource `json:"env_from,omitempty"`
+
+	// Additional volumes added to the pulp-web pods.
+	// The names of the volumes defined by the operator are reserved and cannot be used.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+
+	// Additional volume mounts added to the pulp-web container.
+	// The mount paths used by the operator are reserved and cannot be used.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	VolumeMounts []corev1.VolumeMount `json:"volume_mounts,omitempty"`
+
+	// Init containers added to the pulp-web pods. They run before the operator init containers (if any)
+	// and can mount the volumes defined by the operator or in volumes by name.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	InitContainers []corev1.Container `json:"init_containers,omitempty"`
+
+	// Sidecar containers added to the pulp-web pods, after the container defined by the operator.
+	// They can mount the volumes defined by the operator or in volumes by name.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	SidecarContainers []corev1.Container `json:"sidecar_containers,omitempty"`
+
+	// Security context of the pulp-web pods. By default, the pods run as a non-root user with the
+	// RuntimeDefault seccomp profile (restricted Pod Security Standard).
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodSecurityContext *corev1.PodSecurityContext `json:"pod_security_context,omitempty"`
+
+	// Security context of the pulp-web container. By default, privilege escalation is not allowed
+	// and all capabilities are dropped (restricted Pod Security Standard).
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	SecurityContext *corev1.SecurityContext `json:"security_context,omitempty"`
+
+	// Name of the PriorityClass of the pulp-web pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PriorityClassName string `json:"priority_class_name,omitempty"`
+
+	// Name of the RuntimeClass used to run the pulp-web pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	RuntimeClassName *string `json:"runtime_class_name,omitempty"`
+
+	// Duration in seconds the pulp-web pods need to terminate gracefully. Default: 30
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	TerminationGracePeriodSeconds *int64 `json:"termination_grace_period_seconds,omitempty"`
+
+	// Entries added to the /etc/hosts file of the pulp-web pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	HostAliases []corev1.HostAlias `json:"host_aliases,omitempty"`
+
+	// Annotations added to the pulp-web pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodAnnotations map[string]string `json:"pod_annotations,omitempty"`
+
+	// Labels added to the pulp-web pods.
+	// The labels defined by the operator (used by the selectors) take precedence over them.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodLabels map[string]string `json:"pod_labels,omitempty"`
+}
+
+// Autoscaling defines the HorizontalPodAutoscaler of a component
+type Autoscaling struct {
+	// Minimum number of replicas. [default: 1]
+	// +kubebuilder:default:=1
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podCount"}
+	Min *int32 `json:"min,omitempty"`
+
+	// Maximum number of replicas.
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Required
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podCount"}
+	Max int32 `json:"max"`
+
+	// Target average CPU utilization (percentage of the requested CPU).
+	// If no target nor metric is defined, 80% of CPU utilization is used.
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	TargetCPUUtilization *int32 `json:"target_cpu_utilization,omitempty"`
+
+	// Target average memory utilization (percentage of the requested memory).
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	TargetMemoryUtilization *int32 `json:"target_memory_utilization,omitempty"`
+
+	// Additional metrics (for example, pods or external custom metrics) used by the HorizontalPodAutoscaler.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Metrics []autoscalingv2.MetricSpec `json:"metrics,omitempty"`
+
+	// Scaling behavior in both up and down directions.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Behavior *autoscalingv2.HorizontalPodAutoscalerBehavior `json:"behavior,omitempty"`
+}
+
+// VPA defines the VerticalPodAutoscaler of a component
+type VPA struct {
+	// Update mode of the VerticalPodAutoscaler: Off to only provide recommendations, Initial to set the
+	// recommended resources when the pods are created or Auto to also recreate the running pods
+	// with the recommended resources. [default: Off]
+	// +kubebuilder:default:="Off"
+	// +kubebuilder:validation:Enum:=Off;Initial;Auto
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:Off","urn:alm:descriptor:com.tectonic.ui:select:Initial","urn:alm:descriptor:com.tectonic.ui:select:Auto"}
+	UpdateMode string `json:"update_mode,omitempty"`
+
+	// Minimum resources the VerticalPodAutoscaler can recommend.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	MinAllowed corev1.ResourceList `json:"min_allowed,omitempty"`
+
+	// Maximum resources the VerticalPodAutoscaler can recommend.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	MaxAllowed corev1.ResourceList `json:"max_allowed,omitempty"`
+}
+
+type ExternalDB struct {
+	PostgresPort     int    `json:"postgres_port,omitempty"`
+	PostgresSSLMode  string `json:"postgres_ssl_mode,omitempty"`
+	PostgresHost     string `json:"postgres_host,omitempty"`
+	PostgresUser     string `json:"postgres_user,omitempty"`
+	PostgresPassword string `json:"postgres_password,omitempty"`
+	PostgresDBName   string `json:"postgres_db_name,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	PostgresConMaxAge string `json:"postgres_con_max_age,omitempty"`
+}
+
+type Database struct {
+	// Number of database pods. The first pod is the primary and the others are read-only hot standby
+	// replicas kept in sync through streaming replication (there is no automatic failover). [default: 1]
+	// +kubebuilder:default:=1
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podCount"}
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// Secret name with the configuration to use an external database
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	ExternalDBSecret string `json:"external_db_secret,omitempty"`
+
+	// PostgreSQL version [default: "13"]
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	PostgresVersion string `json:"version,omitempty"`
+
+	// PostgreSQL port [default: 5432]
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number"}
+	PostgresPort int `json:"postgres_port,omitempty"`
+
+	// Configure PostgreSQL connection sslmode option [default: "prefer"]
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	PostgresSSLMode string `json:"postgres_ssl_mode,omitempty"`
+
+	// PostgreSQL container image [default: "postgres:13"]
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	PostgresImage string `json:"postgres_image,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	PostgresExtraArgs []string `json:"postgres_extra_args,omitempty"`
+
+	// PostgreSQL configuration parameters (postgresql.conf) for the database deployed by the operator.
+	// Parameters that need a server restart (like shared_buffers or max_connections) will restart
+	// the database pod, all the others are applied with a configuration reload.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PostgresParameters map[string]string `json:"postgres_parameters,omitempty"`
+
+	// Registry path to the PostgreSQL container to use [default: "/var/lib/postgresql/data/pgdata"]
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	PostgresDataPath string `json:"postgres_data_path,omitempty"`
+
+	// Arguments to pass to PostgreSQL initdb command when creating a new cluster. [default: "--auth-host=scram-sha-256"]
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	PostgresInitdbArgs string `json:"postgres_initdb_args,omitempty"`
+
+	// PostgreSQL host authentication method [default: "scram-sha-256"]
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	PostgresHostAuthMethod string `json:"postgres_host_auth_method,omitempty"`
+
+	// Resource requirements for the database container.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:resourceRequirements","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	ResourceRequirements corev1.ResourceRequirements `json:"postgres_resource_requirements,omitempty"`
+
+	// Vertical pod autoscaling of the postgres container. When defined and the autoscaling.k8s.io API is
+	// available, the operator creates a VerticalPodAutoscaler that recommends (or sets) the resources
+	// of the container. The recommendations are stored in .status.resource_recommendations.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	VPA *VPA `json:"vpa,omitempty"`
+
+	// Defines various deployment affinities.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Affinity Affinity `json:"affinity,omitempty"`
+
+	// NodeSelector for the database pod.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	NodeSelector map[string]string `json:"node_selector,omitempty"`
+
+	// Node tolerations for the database pod.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Topology rule(s) for the database pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topology_spread_constraints,omitempty"`
+
+	// The statefulset update strategy to use to replace existing pods with new ones.
+	// +optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:updateStrategy","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Strategy appsv1.StatefulSetUpdateStrategy `json:"strategy,omitempty"`
+
+	// Temporarily modifying it as a string to avoid an issue with backup and json.Unmarshal
+	// when set as resource.Quantity and no value passed on pulp CR, during backup steps
+	// json.Unmarshal is settings it with "0"
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PostgresStorageRequirements string `json:"postgres_storage_requirements,omitempty"`
+
+	// Name of the StorageClass required by the claim.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:StorageClass","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PostgresStorageClass *string `json:"postgres_storage_class,omitempty"`
+
+	// PersistenVolumeClaim name that will be used by database pods
+	// If defined, the PVC must be provisioned by the user and the operator will only
+	// configure the deployment to use it
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:PersistentVolumeClaim","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PVC string `json:"pvc,omitempty"`
 
 	// Periodic probe of container service readiness.
 	// Container will be removed from service endpoints if the probe fails.
@@ -561,6 +1208,131 @@ type Database struct {
 	// +kubebuilder:validation:Optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:Probe","urn:alm:descriptor:com.tectonic.ui:advanced"}
 	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
+
+	// Probe that indicates that the container has successfully started.
+	// Liveness and readiness probes are not executed until it succeeds, which avoids the
+	// container to be restarted during a slow first boot.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:Probe","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	StartupProbe *corev1.Probe `json:"startupProbe,omitempty"`
+
+	// Configuration of the postgres_exporter sidecar used to expose the database metrics
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Metrics DatabaseMetrics `json:"metrics,omitempty"`
+
+	// Environment variables added to the postgres container after the ones defined by the operator.
+	// A variable with the same name as one defined by the operator overrides it.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// Sources (configmaps or secrets) of environment variables for the postgres container.
+	// Variables defined in env, or by the operator, take precedence over the ones from env_from.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	EnvFrom []corev1.EnvFromSource `json:"env_from,omitempty"`
+
+	// Additional volumes added to the postgres pods.
+	// The names of the volumes defined by the operator are reserved and cannot be used.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+
+	// Additional volume mounts added to the postgres container.
+	// The mount paths used by the operator are reserved and cannot be used.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	VolumeMounts []corev1.VolumeMount `json:"volume_mounts,omitempty"`
+
+	// Init containers added to the postgres pods. They run before the operator init containers (if any)
+	// and can mount the volumes defined by the operator or in volumes by name.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	InitContainers []corev1.Container `json:"init_containers,omitempty"`
+
+	// Sidecar containers added to the postgres pods, after the containers defined by the operator.
+	// They can mount the volumes defined by the operator or in volumes by name.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	SidecarContainers []corev1.Container `json:"sidecar_containers,omitempty"`
+
+	// Security context of the postgres pods. It is not defined by default, so the pods run with the user
+	// from the postgres image (which starts as root to fix the ownership of the data before dropping privileges).
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodSecurityContext *corev1.PodSecurityContext `json:"pod_security_context,omitempty"`
+
+	// Security context of the postgres containers. It is not defined by default.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	SecurityContext *corev1.SecurityContext `json:"security_context,omitempty"`
+
+	// Name of the PriorityClass of the postgres pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PriorityClassName string `json:"priority_class_name,omitempty"`
+
+	// Name of the RuntimeClass used to run the postgres pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	RuntimeClassName *string `json:"runtime_class_name,omitempty"`
+
+	// Duration in seconds the postgres pods need to terminate gracefully. Default: 30
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	TerminationGracePeriodSeconds *int64 `json:"termination_grace_period_seconds,omitempty"`
+
+	// Entries added to the /etc/hosts file of the postgres pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	HostAliases []corev1.HostAlias `json:"host_aliases,omitempty"`
+
+	// Annotations added to the postgres pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodAnnotations map[string]string `json:"pod_annotations,omitempty"`
+
+	// Labels added to the postgres pods.
+	// The labels defined by the operator (used by the selectors) take precedence over them.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodLabels map[string]string `json:"pod_labels,omitempty"`
+}
+
+// DatabaseMetrics defines the postgres_exporter sidecar configuration
+type DatabaseMetrics struct {
+	// Defines if a postgres_exporter container should be deployed with the database.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch"}
+	Enabled bool `json:"enabled,omitempty"`
+
+	// The image name for the postgres_exporter image. [default: "quay.io/prometheuscommunity/postgres-exporter:v0.11.1"]
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Image string `json:"image,omitempty"`
+
+	// The port used to expose the metrics. [default: 9187]
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Port int `json:"port,omitempty"`
+
+	// Resource requirements for the postgres_exporter container.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:resourceRequirements","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	ResourceRequirements corev1.ResourceRequirements `json:"resource_requirements,omitempty"`
+
+	// Interval at which metrics should be scraped by Prometheus, used in the ServiceMonitor
+	// created when the monitoring.coreos.com/v1 API is available in the cluster. [default: "30s"]
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	ScrapeInterval string `json:"scrape_interval,omitempty"`
+
+	// Additional labels for the ServiceMonitor, used by Prometheus serviceMonitorSelector.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	ServiceMonitorLabels map[string]string `json:"service_monitor_labels,omitempty"`
 }
 
 type Cache struct {
@@ -591,11 +1363,45 @@ type Cache struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:com.tectonic.ui:advanced"}
 	RedisPort int `json:"redis_port,omitempty"`
 
+	// Defines how the Redis instance is deployed by the operator:
+	// standalone (a single Redis pod) or sentinel (a Redis StatefulSet with Sentinel
+	// monitoring the master and promoting a replica in case of failure). [default: standalone]
+	// +kubebuilder:validation:Enum:=standalone;sentinel
+	// +kubebuilder:default:=standalone
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:select:standalone","urn:alm:descriptor:com.tectonic.ui:select:sentinel","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Mode string `json:"mode,omitempty"`
+
+	// Number of Redis pods (each one running a Sentinel process) when mode is sentinel. [default: 3]
+	// +kubebuilder:validation:Minimum:=3
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:podCount","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// Redis configuration (memory limit, eviction and persistence) rendered into
+	// the redis.conf mounted in the Redis pods deployed by the operator.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	RedisConfig *RedisConfig `json:"redis_config,omitempty"`
+
+	// Enable TLS in the Redis instance deployed by the operator.
+	// The operator generates a CA and a server certificate in the <deployment-name>-redis-tls secret.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	TLS bool `json:"tls,omitempty"`
+
 	// Resource requirements for the Redis container
 	// +kubebuilder:validation:Optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:resourceRequirements","urn:alm:descriptor:com.tectonic.ui:advanced"}
 	RedisResourceRequirements corev1.ResourceRequirements `json:"redis_resource_requirements,omitempty"`
 
+	// Vertical pod autoscaling of the redis container. When defined and the autoscaling.k8s.io API is
+	// available, the operator creates a VerticalPodAutoscaler that recommends (or sets) the resources
+	// of the container. The recommendations are stored in .status.resource_recommendations.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	VPA *VPA `json:"vpa,omitempty"`
+
 	// PersistenVolumeClaim name that will be used by Redis pods
 	// If defined, the PVC must be provisioned by the user and the operator will only
 	// configure the deployment to use it
@@ -615,6 +1421,13 @@ type Cache struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:Probe","urn:alm:descriptor:com.tectonic.ui:advanced"}
 	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
 
+	// Probe that indicates that the container has successfully started.
+	// Liveness and readiness probes are not executed until it succeeds, which avoids the
+	// container to be restarted during a slow first boot.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:Probe","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	StartupProbe *corev1.Probe `json:"startupProbe,omitempty"`
+
 	// Defines various deployment affinities.
 	// +kubebuilder:validation:Optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
@@ -630,16 +1443,286 @@ type Cache struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
 	NodeSelector map[string]string `json:"node_selector,omitempty"`
 
+	// Topology rule(s) for the redis pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topology_spread_constraints,omitempty"`
+
 	// The deployment strategy to use to replace existing pods with new ones.
+	// It is not used when the cache runs with sentinel (statefulset).
 	// +optional
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:updateStrategy","urn:alm:descriptor:com.tectonic.ui:advanced"}
 	Strategy appsv1.DeploymentStrategy `json:"strategy,omitempty"`
+
+	// Environment variables added to the redis containers after the ones defined by the operator.
+	// A variable with the same name as one defined by the operator overrides it.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// Sources (configmaps or secrets) of environment variables for the redis containers.
+	// Variables defined in env, or by the operator, take precedence over the ones from env_from.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	EnvFrom []corev1.EnvFromSource `json:"env_from,omitempty"`
+
+	// Additional volumes added to the redis pods.
+	// The names of the volumes defined by the operator are reserved and cannot be used.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Volumes []corev1.Volume `json:"volumes,omitempty"`
+
+	// Additional volume mounts added to the redis container.
+	// The mount paths used by the operator are reserved and cannot be used.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	VolumeMounts []corev1.VolumeMount `json:"volume_mounts,omitempty"`
+
+	// Init containers added to the redis pods. They run before the operator init containers (if any)
+	// and can mount the volumes defined by the operator or in volumes by name.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	InitContainers []corev1.Container `json:"init_containers,omitempty"`
+
+	// Sidecar containers added to the redis pods, after the containers defined by the operator.
+	// They can mount the volumes defined by the operator or in volumes by name.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	SidecarContainers []corev1.Container `json:"sidecar_containers,omitempty"`
+
+	// Security context of the redis pods. It is not defined by default, so the pods run with the user
+	// from the redis image (which starts as root to fix the ownership of the data before dropping privileges).
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodSecurityContext *corev1.PodSecurityContext `json:"pod_security_context,omitempty"`
+
+	// Security context of the redis containers. It is not defined by default.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	SecurityContext *corev1.SecurityContext `json:"security_context,omitempty"`
+
+	// Name of the PriorityClass of the redis pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PriorityClassName string `json:"priority_class_name,omitempty"`
+
+	// Name of the RuntimeClass used to run the redis pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	RuntimeClassName *string `json:"runtime_class_name,omitempty"`
+
+	// Duration in seconds the redis pods need to terminate gracefully. Default: 30
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:number","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	TerminationGracePeriodSeconds *int64 `json:"termination_grace_period_seconds,omitempty"`
+
+	// Entries added to the /etc/hosts file of the redis pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	HostAliases []corev1.HostAlias `json:"host_aliases,omitempty"`
+
+	// Annotations added to the redis pods.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodAnnotations map[string]string `json:"pod_annotations,omitempty"`
+
+	// Labels added to the redis pods.
+	// The labels defined by the operator (used by the selectors) take precedence over them.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodLabels map[string]string `json:"pod_labels,omitempty"`
+}
+
+// RedisConfig defines the redis.conf directives of the Redis instance deployed by the operator
+type RedisConfig struct {
+	// Maximum amount of memory used by Redis for data (maxmemory directive).
+	// It cannot be greater than 80% of the memory limit from redis_resource_requirements.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	MaxMemory *resource.Quantity `json:"maxmemory,omitempty"`
+
+	// How Redis selects what to remove when maxmemory is reached (maxmemory-policy directive).
+	// +kubebuilder:validation:Enum:=noeviction;allkeys-lru;allkeys-lfu;allkeys-random;volatile-lru;volatile-lfu;volatile-random;volatile-ttl
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	MaxMemoryPolicy string `json:"maxmemory_policy,omitempty"`
+
+	// Enable the AOF persistence (appendonly directive).
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:booleanSwitch","urn:alm:descriptor:com.tectonic.ui:advanced"}
+	AppendOnly *bool `json:"appendonly,omitempty"`
+
+	// How often the AOF is synced to disk (appendfsync directive).
+	// +kubebuilder:validation:Enum:=always;everysec;no
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	AppendFsync string `json:"appendfsync,omitempty"`
+
+	// RDB snapshots (save directive), for example "3600 1 300 100".
+	// An empty string disables the RDB persistence.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	Save *string `json:"save,omitempty"`
+
+	// Additional redis.conf directives.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	ExtraConfig map[string]string `json:"extra_config,omitempty"`
 }
 
 // PulpStatus defines the observed state of Pulp
 type PulpStatus struct {
 	//+operator-sdk:csv:customresourcedefinitions:type=status,xDescriptors={"urn:alm:descriptor:io.kubernetes.conditions"}
 	Conditions []metav1.Condition `json:"conditions"`
+
+	// Role of each database pod deployed by the operator
+	//+operator-sdk:csv:customresourcedefinitions:type=status
+	DatabaseReplicas []DatabaseReplicaStatus `json:"database_replicas,omitempty"`
+
+	// Role of each Redis pod deployed by the operator when cache.mode is sentinel
+	//+operator-sdk:csv:customresourcedefinitions:type=status
+	CacheTopology []CacheNodeStatus `json:"cache_topology,omitempty"`
+
+	// State of the pulp-worker autoscaling when worker.autoscaling is defined
+	//+operator-sdk:csv:customresourcedefinitions:type=status
+	WorkerAutoscaling *WorkerAutoscalingStatus `json:"worker_autoscaling,omitempty"`
+
+	// Progress of the last upgrade of the pulpcore image
+	//+operator-sdk:csv:customresourcedefinitions:type=status
+	Upgrade *UpgradeStatus `json:"upgrade,omitempty"`
+
+	// Images of the components deployed by the operator and the digests running in their pods
+	//+operator-sdk:csv:customresourcedefinitions:type=status
+	Images []ImageStatus `json:"images,omitempty"`
+
+	// Deployment size profile and the values it set in the fields not defined in pulp CR
+	//+operator-sdk:csv:customresourcedefinitions:type=status
+	Profile *ProfileStatus `json:"profile,omitempty"`
+
+	// Resources recommended by the VerticalPodAutoscalers of the components with vpa defined
+	//+operator-sdk:csv:customresourcedefinitions:type=status
+	ResourceRecommendations []ResourceRecommendationStatus `json:"resource_recommendations,omitempty"`
+}
+
+// ResourceRecommendationStatus defines the resources recommended for the container of a component
+type ResourceRecommendationStatus struct {
+	// Name of the component (api, content, worker, database or cache)
+	Component string `json:"component"`
+
+	// Recommended resources
+	// +optional
+	Target corev1.ResourceList `json:"target,omitempty"`
+
+	// Minimum recommended resources
+	// +optional
+	LowerBound corev1.ResourceList `json:"lower_bound,omitempty"`
+
+	// Maximum recommended resources
+	// +optional
+	UpperBound corev1.ResourceList `json:"upper_bound,omitempty"`
+}
+
+// ProfileStatus defines the deployment size profile applied to pulp CR
+type ProfileStatus struct {
+	// Name of the profile
+	Name string `json:"name"`
+
+	// Fields of pulp CR spec (and their values) defined by the profile, which are
+	// the ones not explicitly defined in pulp CR
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Applied runtime.RawExtension `json:"applied,omitempty"`
+}
+
+// ImageStatus defines the image of a component and the images running in its pods
+type ImageStatus struct {
+	// Name of the component (api, content, worker, worker-<pool name>, web, database or cache)
+	Component string `json:"component"`
+
+	// Image defined in the component deployment (or statefulset)
+	Image string `json:"image"`
+
+	// Images (with digest) running in the component pods, as resolved by the container runtime.
+	// There is more than one digest while a rollout is in progress.
+	// +optional
+	Digests []string `json:"digests,omitempty"`
+}
+
+// UpgradeStatus defines the state of an upgrade of the pulpcore image
+type UpgradeStatus struct {
+	// Current step of the upgrade (Draining, Migrating, RollingAPI, RollingContent,
+	// RollingWorkers, Completed, RollingBack or Failed)
+	Phase string `json:"phase"`
+
+	// Image used by pulpcore pods before the upgrade
+	FromImage string `json:"from_image"`
+
+	// Image pulpcore pods are being upgraded to
+	ToImage string `json:"to_image"`
+
+	// Images defined for the components in pulp CR ({api,content,worker}.image) before the upgrade
+	// +optional
+	FromComponentImages map[string]string `json:"from_component_images,omitempty"`
+
+	// Images defined for the components in pulp CR ({api,content,worker}.image) the pods are being upgraded to
+	// +optional
+	ToComponentImages map[string]string `json:"to_component_images,omitempty"`
+
+	// Number of pulp-worker replicas before the upgrade
+	WorkerReplicas int32 `json:"worker_replicas"`
+
+	// Time the upgrade started
+	// +optional
+	StartTime *metav1.Time `json:"start_time,omitempty"`
+
+	// Time the upgrade completed or was rolled back
+	// +optional
+	CompletionTime *metav1.Time `json:"completion_time,omitempty"`
+
+	// Details about the current step or the failure
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// WorkerAutoscalingStatus defines the last state of the tasks queue seen by the operator
+type WorkerAutoscalingStatus struct {
+	// Number of pulp-worker replicas
+	Replicas int32 `json:"replicas"`
+
+	// Number of tasks waiting to be picked up by a worker
+	WaitingTasks int32 `json:"waiting_tasks"`
+
+	// Number of tasks being executed
+	RunningTasks int32 `json:"running_tasks"`
+
+	// Last time the operator scaled the pulp-worker deployment
+	// +optional
+	LastScaleTime *metav1.Time `json:"last_scale_time,omitempty"`
+}
+
+// DatabaseReplicaStatus defines the state of a database pod
+type DatabaseReplicaStatus struct {
+	// Name of the database pod
+	Pod string `json:"pod"`
+
+	// Replication role of the pod (primary or replica)
+	Role string `json:"role"`
+
+	// Defines if the pod is ready to receive connections
+	Ready bool `json:"ready"`
+}
+
+// CacheNodeStatus defines the state of a Redis pod
+type CacheNodeStatus struct {
+	// Name of the Redis pod
+	Pod string `json:"pod"`
+
+	// Replication role of the pod (master or replica)
+	Role string `json:"role"`
+
+	// Defines if the pod is ready to receive connections
+	Ready bool `json:"ready"`
 }
 
 // Pulp is the Schema for the pulps API
@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -69,6 +70,17 @@ type PulpBackupSpec struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,displayName="Database configuration"
 	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:io.kubernetes:Secret"}
 	PostgresConfigurationSecret string `json:"postgres_configuration_secret"`
+
+	// Security context of the backup-manager pod. It is not defined by default, so the pod runs with
+	// the user from the image (root) and can handle the files from the previous backups.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	PodSecurityContext *corev1.PodSecurityContext `json:"pod_security_context,omitempty"`
+
+	// Security context of the backup-manager container. It is not defined by default.
+	// +kubebuilder:validation:Optional
+	// +operator-sdk:csv:customresourcedefinitions:type=spec,xDescriptors={"urn:alm:descriptor:com.tectonic.ui:advanced"}
+	SecurityContext *corev1.SecurityContext `json:"security_context,omitempty"`
 }
 
 // PulpBackupStatus defines the observed state of PulpBackup
@@ -216,6 +216,13 @@ func (r *PulpBackupReconciler) createBackupPod(ctx context.Context, pulpBackup *
 		volumes = append(volumes, fileStorageVolume)
 	}
 
+	// if pulp is using an external database we should also mount the client certificates
+	// so that pg_dump can connect to it
+	if len(pulp.Spec.Database.ExternalDBSecret) > 0 {
+		volumes = append(volumes, controllers.PostgresCertsVolume(pulp.Spec.Database.ExternalDBSecret))
+		volumeMounts = append(volumeMounts, controllers.PostgresCertsVolumeMount())
+	}
+
 	// running a dumb command on bkp mount point just to make sure that
 	// the pod is ready to execute the backup commands (mkdir,cp,echo,etc)
 	readinessProbe := &corev1.Probe{
@@ -246,11 +253,13 @@ func (r *PulpBackupReconciler) createBackupPod(ctx context.Context, pulpBackup *
 					"sleep",
 					"infinity",
 				},
-				VolumeMounts:   volumeMounts,
-				ReadinessProbe: readinessProbe,
+				VolumeMounts:    volumeMounts,
+				ReadinessProbe:  readinessProbe,
+				SecurityContext: pulpBackup.Spec.SecurityContext,
 			}},
-			Volumes:       volumes,
-			RestartPolicy: corev1.RestartPolicyNever,
+			SecurityContext: pulpBackup.Spec.PodSecurityContext,
+			Volumes:         volumes,
+			RestartPolicy:   corev1.RestartPolicyNever,
 		},
 	}
 	if err != nil && errors.IsNotFound(err) {
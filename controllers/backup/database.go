@@ -2,6 +2,7 @@ package pulp_backup
 
 import (
 	"context"
+	"net/url"
 
 	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
 	"github.com/pulp/pulp-operator/controllers"
@@ -29,15 +30,13 @@ func (r *PulpBackupReconciler) backupDatabase(ctx context.Context, pulpBackup *r
 		return err
 	}
 
-	pgConfig := &corev1.Secret{}
-	err = r.Get(ctx, types.NamespacedName{Name: pulpBackup.Spec.PostgresConfigurationSecret, Namespace: pulpBackup.Namespace}, pgConfig)
+	connectionURL, err := r.databaseConnectionURL(ctx, pulpBackup)
 	if err != nil {
-		log.Error(err, "Failed to find postgres-configuration secret")
 		return err
 	}
 	execCmd = []string{
 		"pg_dump", "--clean", "--create", "-Ft",
-		"-d", "postgresql://" + string(pgConfig.Data["username"]) + ":" + string(pgConfig.Data["password"]) + "@" + string(pgConfig.Data["host"]) + ":" + string(pgConfig.Data["port"]) + "/" + string(pgConfig.Data["database"]),
+		"-d", connectionURL,
 		"-f", backupDir + "/" + backupFile,
 	}
 
@@ -50,3 +49,44 @@ func (r *PulpBackupReconciler) backupDatabase(ctx context.Context, pulpBackup *r
 	log.Info("Database Backup finished!")
 	return nil
 }
+
+// databaseConnectionURL returns the postgresql:// URL used by pg_dump to connect to the database.
+// If pulp CR is configured with an external database the connection parameters (including the
+// client certificates and extra libpq options) are retrieved from the external database secret.
+func (r *PulpBackupReconciler) databaseConnectionURL(ctx context.Context, pulpBackup *repomanagerv1alpha1.PulpBackup) (string, error) {
+	log := r.RawLogger
+
+	pulp := &repomanagerv1alpha1.Pulp{}
+	if err := r.Get(ctx, types.NamespacedName{Name: pulpBackup.Spec.DeploymentName, Namespace: pulpBackup.Namespace}, pulp); err != nil {
+		log.Error(err, "Failed to get Pulp")
+		return "", err
+	}
+
+	connectionURL := url.URL{Scheme: "postgresql"}
+	query := url.Values{}
+	if len(pulp.Spec.Database.ExternalDBSecret) > 0 {
+		externalDB := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: pulp.Spec.Database.ExternalDBSecret, Namespace: pulpBackup.Namespace}, externalDB); err != nil {
+			log.Error(err, "Failed to find "+pulp.Spec.Database.ExternalDBSecret+" secret")
+			return "", err
+		}
+		connectionURL.User = url.UserPassword(string(externalDB.Data["POSTGRES_USERNAME"]), string(externalDB.Data["POSTGRES_PASSWORD"]))
+		connectionURL.Host = string(externalDB.Data["POSTGRES_HOST"]) + ":" + string(externalDB.Data["POSTGRES_PORT"])
+		connectionURL.Path = "/" + string(externalDB.Data["POSTGRES_DB_NAME"])
+		for key, value := range controllers.PostgresConnectionOptions(externalDB.Data) {
+			query.Set(key, value)
+		}
+	} else {
+		pgConfig := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: pulpBackup.Spec.PostgresConfigurationSecret, Namespace: pulpBackup.Namespace}, pgConfig); err != nil {
+			log.Error(err, "Failed to find postgres-configuration secret")
+			return "", err
+		}
+		connectionURL.User = url.UserPassword(string(pgConfig.Data["username"]), string(pgConfig.Data["password"]))
+		connectionURL.Host = string(pgConfig.Data["host"]) + ":" + string(pgConfig.Data["port"])
+		connectionURL.Path = "/" + string(pgConfig.Data["database"])
+	}
+	connectionURL.RawQuery = query.Encode()
+
+	return connectionURL.String(), nil
+}
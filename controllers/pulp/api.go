@@ -19,7 +19,7 @@ package pulp
 import (
 	"context"
 	"fmt"
-	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -114,6 +114,25 @@ func (r *PulpReconciler) pulpApiController(ctx context.Context, pulp *repomanage
 		return ctrl.Result{}, err
 	}
 
+	// Reconcile pulp-server secret in case any of the secrets referenced in pulp CR
+	// (external database, external cache, object storage, sso) or the settings rendered
+	// from pulp CR (for example, cache.mode or cache.tls) have been modified
+	sec := r.pulpServerSecret(ctx, pulp, log)
+	if secret.Annotations[referencedSecretsAnnotation] != sec.Annotations[referencedSecretsAnnotation] || string(secret.Data["settings.py"]) != sec.StringData["settings.py"] {
+		log.Info("The settings from " + pulp.Name + " CR or a secret referenced in it have been modified! Reconciling pulp-server secret ...")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "UpdatingServerSecret", "Reconciling "+pulp.Name+"-server secret")
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updating", "Reconciling server secret")
+		err = r.Update(ctx, sec)
+		if err != nil {
+			log.Error(err, "Error trying to update the pulp-server secret object ... ")
+			r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "ErrorUpdatingServerSecret", "Failed to reconcile "+pulp.Name+"-server secret: "+err.Error())
+			r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to update server secret")
+			return ctrl.Result{}, err
+		}
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updated", "Reconciled server secret")
+		return ctrl.Result{Requeue: true}, nil
+	}
+
 	// Create pulp-db-fields-encryption secret
 	dbFieldsEnc := &corev1.Secret{}
 	err = r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-db-fields-encryption", Namespace: pulp.Namespace}, dbFieldsEnc)
@@ -194,16 +213,48 @@ func (r *PulpReconciler) pulpApiController(ctx context.Context, pulp *repomanage
 		return ctrl.Result{}, err
 	}
 
+	// the volumes and containers from pulp CR cannot use the names or mount paths reserved by the operator
+	apiPodSpec := r.deploymentForPulpApi(pulp, "").Spec.Template.Spec
+	if err := validateVolumes("api", apiPodSpec); err != nil {
+		log.Error(err, "Invalid api volumes configuration")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "InvalidVolumes", err.Error())
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid api volumes configuration: "+err.Error())
+		return ctrl.Result{}, err
+	}
+	if err := validateContainers("api", apiPodSpec); err != nil {
+		log.Error(err, "Invalid api containers configuration")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "InvalidContainers", err.Error())
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid api containers configuration: "+err.Error())
+		return ctrl.Result{}, err
+	}
+
+	// Run the database migrations before rolling out the pulpcore deployments
+	// (unless the upgrade to the images from pulp CR failed and was rolled back)
+	if !upgradeRolledBack(pulp) {
+		if res, err := r.migrationController(ctx, pulp, log); err != nil || res.Requeue || res.RequeueAfter > 0 {
+			return res, err
+		}
+	}
+
+	// the pods are redeployed when settings.py is modified
+	settingsChecksum, err := r.settingsChecksum(ctx, pulp)
+	if err != nil {
+		log.Error(err, "Failed to get the checksum of settings.py")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "ErrorGettingSettings", "Failed to get "+pulp.Name+"-server secret: "+err.Error())
+		return ctrl.Result{}, err
+	}
+
 	// Create pulp-api deployment
 	found := &appsv1.Deployment{}
 	err = r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-api", Namespace: pulp.Namespace}, found)
-	dep := r.deploymentForPulpApi(pulp)
+	dep := r.deploymentForPulpApi(pulp, settingsChecksum)
 
 	if err != nil && errors.IsNotFound(err) {
 		// Define a new deployment
 		log.Info("Creating a new Pulp API Deployment", "Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
 		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "CreatingApiDeployment", "Creating "+pulp.Name+"-api deployment")
 		controllers.CheckEmptyDir(pulp, controllers.PulpResource)
+		r.warnEnvOverrides(pulp, "api", dep.Spec.Template.Spec)
 		err = r.Create(ctx, dep)
 		if err != nil {
 			log.Error(err, "Failed to create new Pulp API Deployment", "Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
@@ -219,12 +270,16 @@ func (r *PulpReconciler) pulpApiController(ctx context.Context, pulp *repomanage
 		return ctrl.Result{}, err
 	}
 
+	// the number of replicas is managed by the HPA when autoscaling is enabled
+	dep.Spec.Replicas = autoscaledReplicas(pulp.Spec.Api.Autoscaling, *effectivePulp(pulp).Spec.Api.Replicas, found)
+
 	// Ensure the deployment template spec is as expected
 	// https://github.com/kubernetes-sigs/kubebuilder/issues/592
-	if !equality.Semantic.DeepDerivative(dep.Spec, found.Spec) {
+	if !equality.Semantic.DeepDerivative(dep.Spec, found.Spec) || podTemplateModified(dep.Spec.Template, found.Spec.Template) {
 		log.Info("The API deployment has been modified! Reconciling ...")
 		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "UpdatingApiDeployment", "Reconciling "+pulp.Name+"-api deployment")
 		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updating", "Reconciling API deployment")
+		r.warnEnvOverrides(pulp, "api", dep.Spec.Template.Spec)
 		err = r.Update(ctx, dep)
 		if err != nil {
 			log.Error(err, "Error trying to update the API deployment object ... ")
@@ -315,27 +370,26 @@ func (r *PulpReconciler) fileStoragePVC(m *repomanagerv1alpha1.Pulp) *corev1.Per
 }
 
 // deploymentForPulpApi returns a pulp-api Deployment object
-func (r *PulpReconciler) deploymentForPulpApi(m *repomanagerv1alpha1.Pulp) *appsv1.Deployment {
-	replicas := m.Spec.Api.Replicas
+func (r *PulpReconciler) deploymentForPulpApi(m *repomanagerv1alpha1.Pulp, settingsChecksum string) *appsv1.Deployment {
+	m = effectivePulp(m)
+	replicas := autoscaledReplicas(m.Spec.Api.Autoscaling, *m.Spec.Api.Replicas, nil)
 	ls := labelsForPulpApi(m)
 
 	affinity := &corev1.Affinity{}
 	if m.Spec.Api.Affinity.NodeAffinity != nil {
 		affinity.NodeAffinity = m.Spec.Api.Affinity.NodeAffinity
 	}
+	if m.Spec.Api.Affinity.PodAffinity != nil {
+		affinity.PodAffinity = m.Spec.Api.Affinity.PodAffinity
+	}
+	if m.Spec.Api.Affinity.PodAntiAffinity != nil {
+		affinity.PodAntiAffinity = m.Spec.Api.Affinity.PodAntiAffinity
+	}
 
 	// pulp image is built to run with user 0
-	// we are enforcing the containers to run as 1000
-	runAsUser := int64(700)
-	fsGroup := int64(700)
-	podSecurityContext := &corev1.PodSecurityContext{}
+	// we are enforcing the containers to run as 700 (restricted Pod Security Standard)
+	podSecurityContext := componentPodSecurityContext("api", m.Spec.Api.PodSecurityContext)
 	IsOpenShift, _ := controllers.IsOpenShift()
-	if !IsOpenShift {
-		podSecurityContext = &corev1.PodSecurityContext{
-			RunAsUser: &runAsUser,
-			FSGroup:   &fsGroup,
-		}
-	}
 
 	nodeSelector := map[string]string{}
 	if m.Spec.Api.NodeSelector != nil {
@@ -354,7 +408,7 @@ func (r *PulpReconciler) deploymentForPulpApi(m *repomanagerv1alpha1.Pulp) *apps
 
 	envVars := []corev1.EnvVar{
 		{Name: "PULP_GUNICORN_TIMEOUT", Value: strconv.Itoa(m.Spec.Api.GunicornTimeout)},
-		{Name: "PULP_API_WORKERS", Value: strconv.Itoa(m.Spec.Api.GunicornWorkers)},
+		{Name: "PULP_API_WORKERS", Value: strconv.Itoa(*m.Spec.Api.GunicornWorkers)},
 	}
 
 	var dbHost, dbPort string
@@ -421,6 +475,7 @@ func (r *PulpReconciler) deploymentForPulpApi(m *repomanagerv1alpha1.Pulp) *apps
 			redisEnvVars := []corev1.EnvVar{
 				{Name: "REDIS_SERVICE_HOST", Value: cacheHost},
 				{Name: "REDIS_SERVICE_PORT", Value: cachePort},
+				redisPasswordEnvVar(m),
 			}
 			envVars = append(envVars, redisEnvVars...)
 		} else {
@@ -469,6 +524,7 @@ func (r *PulpReconciler) deploymentForPulpApi(m *repomanagerv1alpha1.Pulp) *apps
 			}
 			envVars = append(envVars, redisEnvVars...)
 		}
+		envVars = append(envVars, redisTLSEnvVars(m)...)
 	}
 
 	if m.Spec.SigningSecret != "" {
@@ -484,6 +540,9 @@ func (r *PulpReconciler) deploymentForPulpApi(m *repomanagerv1alpha1.Pulp) *apps
 		envVars = append(envVars, signingKeyEnvVars...)
 	}
 
+	// the variables from pulp CR are added after the ones defined by the operator (so they take precedence)
+	envVars = append(envVars, m.Spec.Api.Env...)
+
 	dbFieldsEncryptionSecret := ""
 	if m.Spec.DBFieldsEncryptionSecret == "" {
 		dbFieldsEncryptionSecret = m.Name + "-db-fields-encryption"
@@ -733,6 +792,16 @@ func (r *PulpReconciler) deploymentForPulpApi(m *repomanagerv1alpha1.Pulp) *apps
 		volumes, volumeMounts = mountCASpec(m, volumes, volumeMounts)
 	}
 
+	// mountExternalDBCerts adds the client certificates from the external database secret
+	volumes, volumeMounts = mountExternalDBCerts(m, volumes, volumeMounts)
+
+	// mountRedisCerts adds the CA bundle used to verify the redis server certificate
+	volumes, volumeMounts = mountRedisCerts(m, volumes, volumeMounts)
+
+	// the volumes from pulp CR are added after the ones defined by the operator
+	volumes = append(volumes, m.Spec.Api.Volumes...)
+	volumeMounts = append(volumeMounts, m.Spec.Api.VolumeMounts...)
+
 	resources := m.Spec.Api.ResourceRequirements
 
 	readinessProbe := m.Spec.Api.ReadinessProbe
@@ -776,15 +845,10 @@ func (r *PulpReconciler) deploymentForPulpApi(m *repomanagerv1alpha1.Pulp) *apps
 
 	// the following variables are defined to avoid issues with reconciliation
 	restartPolicy := corev1.RestartPolicy("Always")
-	terminationGracePeriodSeconds := int64(30)
 	dnsPolicy := corev1.DNSPolicy("ClusterFirst")
 	schedulerName := corev1.DefaultSchedulerName
-	Image := os.Getenv("RELATED_IMAGE_PULP")
-	if len(m.Spec.Image) > 0 && len(m.Spec.ImageVersion) > 0 {
-		Image = m.Spec.Image + ":" + m.Spec.ImageVersion
-	} else if Image == "" {
-		Image = "quay.io/pulp/pulp:stable"
-	}
+	Image := deployedComponentImage(m, "api")
+	deployedImage, deployedImages := deployedPulpcoreImages(m)
 
 	// deployment definition
 	dep := &appsv1.Deployment{
@@ -794,6 +858,8 @@ func (r *PulpReconciler) deploymentForPulpApi(m *repomanagerv1alpha1.Pulp) *apps
 			Annotations: map[string]string{
 				"email": "pulp-dev@redhat.com",
 				"ignore-check.kube-linter.io/no-node-affinity": "Do not check node affinity",
+				pulpcoreImageAnnotation:                        deployedImage,
+				componentImagesAnnotation:                      encodeComponentImages(deployedImages),
 			},
 			Labels: map[string]string{
 				"app.kubernetes.io/name":       m.Spec.DeploymentType + "-api",
@@ -807,7 +873,7 @@ func (r *PulpReconciler) deploymentForPulpApi(m *repomanagerv1alpha1.Pulp) *apps
 			},
 		},
 		Spec: appsv1.DeploymentSpec{
-			Replicas: &replicas,
+			Replicas: replicas,
 			Strategy: m.Spec.Api.Strategy,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: ls,
@@ -815,6 +881,9 @@ func (r *PulpReconciler) deploymentForPulpApi(m *repomanagerv1alpha1.Pulp) *apps
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: ls,
+					Annotations: map[string]string{
+						settingsChecksumAnnotation: settingsChecksum,
+					},
 				},
 				Spec: corev1.PodSpec{
 					Affinity:                  affinity,
@@ -823,27 +892,34 @@ func (r *PulpReconciler) deploymentForPulpApi(m *repomanagerv1alpha1.Pulp) *apps
 					Tolerations:               toleration,
 					Volumes:                   volumes,
 					ServiceAccountName:        m.Name,
+					PriorityClassName:         m.Spec.Api.PriorityClassName,
+					RuntimeClassName:          m.Spec.Api.RuntimeClassName,
+					HostAliases:               m.Spec.Api.HostAliases,
 					TopologySpreadConstraints: topologySpreadConstraint,
-					Containers: []corev1.Container{{
+					InitContainers:            m.Spec.Api.InitContainers,
+					Containers: append([]corev1.Container{{
 						Name:            "api",
 						Image:           Image,
 						ImagePullPolicy: corev1.PullPolicy(m.Spec.ImagePullPolicy),
 						Args:            []string{"pulp-api"},
 						Env:             envVars,
+						EnvFrom:         m.Spec.Api.EnvFrom,
 						Ports: []corev1.ContainerPort{{
 							ContainerPort: 24817,
 							Protocol:      "TCP",
 						}},
-						LivenessProbe:  livenessProbe,
-						ReadinessProbe: readinessProbe,
-						Resources:      resources,
-						VolumeMounts:   volumeMounts,
-					}},
+						LivenessProbe:   livenessProbe,
+						StartupProbe:    m.Spec.Api.StartupProbe,
+						ReadinessProbe:  readinessProbe,
+						Resources:       resources,
+						VolumeMounts:    volumeMounts,
+						SecurityContext: componentSecurityContext("api", m.Spec.Api.SecurityContext),
+					}}, m.Spec.Api.SidecarContainers...),
 
 					/* the following configs are not defined on pulp-operator (ansible version)  */
 					/* but i'll keep it here just in case we can manage to make deepequal usable */
 					RestartPolicy:                 restartPolicy,
-					TerminationGracePeriodSeconds: &terminationGracePeriodSeconds,
+					TerminationGracePeriodSeconds: terminationGracePeriod(m.Spec.Api.TerminationGracePeriodSeconds, defaultTerminationGracePeriod),
 					DNSPolicy:                     dnsPolicy,
 					SchedulerName:                 schedulerName,
 				},
@@ -852,6 +928,7 @@ func (r *PulpReconciler) deploymentForPulpApi(m *repomanagerv1alpha1.Pulp) *apps
 	}
 
 	// Set Pulp instance as the owner and controller
+	setPodMetadata(m, &dep.Spec.Template, m.Spec.Api.PodLabels, m.Spec.Api.PodAnnotations)
 	ctrl.SetControllerReference(m, dep, r.Scheme)
 	return dep
 }
@@ -874,7 +951,9 @@ func labelsForPulpApi(m *repomanagerv1alpha1.Pulp) map[string]string {
 // populate the /etc/pulp/settings.py config file
 func (r *PulpReconciler) pulpServerSecret(ctx context.Context, m *repomanagerv1alpha1.Pulp, log logr.Logger) *corev1.Secret {
 
-	var dbHost, dbPort, dbUser, dbPass, dbName, dbSSLMode string
+	var dbHost, dbPort, dbUser, dbPass, dbName string
+	dbConnMaxAge := "0"
+	dbOptions := map[string]string{}
 	_, storageType := controllers.MultiStorageConfigured(m, "Pulp")
 
 	// if there is no external database configuration get the databaseconfig from pulp-postgres-configuration secret
@@ -889,7 +968,7 @@ func (r *PulpReconciler) pulpServerSecret(ctx context.Context, m *repomanagerv1a
 		dbUser = pgCredentials["username"]
 		dbPass = pgCredentials["password"]
 		dbName = pgCredentials["database"]
-		dbSSLMode = pgCredentials["sslmode"]
+		dbOptions["sslmode"] = pgCredentials["sslmode"]
 	} else {
 		log.Info("Retrieving Postgres credentials from "+m.Spec.Database.ExternalDBSecret+" secret", "Secret.Namespace", m.Namespace, "Secret.Name", m.Name)
 		externalPostgresData := []string{"POSTGRES_HOST", "POSTGRES_PORT", "POSTGRES_USERNAME", "POSTGRES_PASSWORD", "POSTGRES_DB_NAME", "POSTGRES_SSLMODE"}
@@ -902,7 +981,29 @@ func (r *PulpReconciler) pulpServerSecret(ctx context.Context, m *repomanagerv1a
 		dbUser = pgCredentials["POSTGRES_USERNAME"]
 		dbPass = pgCredentials["POSTGRES_PASSWORD"]
 		dbName = pgCredentials["POSTGRES_DB_NAME"]
-		dbSSLMode = pgCredentials["POSTGRES_SSLMODE"]
+
+		// optional keys: client certificates, extra libpq options and CONN_MAX_AGE
+		externalDBSecret := &corev1.Secret{}
+		r.Get(ctx, types.NamespacedName{Name: m.Spec.Database.ExternalDBSecret, Namespace: m.Namespace}, externalDBSecret)
+		dbOptions = controllers.PostgresConnectionOptions(externalDBSecret.Data)
+		if connMaxAge := string(externalDBSecret.Data["POSTGRES_CONN_MAX_AGE"]); len(connMaxAge) > 0 {
+			if _, err := strconv.Atoi(connMaxAge); err == nil || connMaxAge == "None" {
+				dbConnMaxAge = connMaxAge
+			} else {
+				log.Error(err, "Invalid POSTGRES_CONN_MAX_AGE value, it should be the number of seconds or None", "Secret.Name", m.Spec.Database.ExternalDBSecret)
+			}
+		}
+	}
+
+	// libpq connection parameters rendered in DATABASES OPTIONS
+	dbOptionKeys := make([]string, 0, len(dbOptions))
+	for key := range dbOptions {
+		dbOptionKeys = append(dbOptionKeys, key)
+	}
+	sort.Strings(dbOptionKeys)
+	renderedDBOptions := []string{}
+	for _, key := range dbOptionKeys {
+		renderedDBOptions = append(renderedDBOptions, "'"+key+"': '"+strings.ReplaceAll(dbOptions[key], "'", "\\'")+"'")
 	}
 
 	// Handling user facing URLs
@@ -932,8 +1033,8 @@ DATABASES = {
 		'USER': '` + dbUser + `',
 		'PASSWORD': '` + dbPass + `',
 		'PORT': '` + dbPort + `',
-		'CONN_MAX_AGE': 0,
-		'OPTIONS': { 'sslmode': '` + dbSSLMode + `' },
+		'CONN_MAX_AGE': ` + dbConnMaxAge + `,
+		'OPTIONS': { ` + strings.Join(renderedDBOptions, ", ") + ` },
 	}
 }
 GALAXY_FEATURE_FLAGS = {
@@ -952,7 +1053,8 @@ TOKEN_SIGNATURE_ALGORITHM = "ES256"
 	// add cache settings
 	if m.Spec.Cache.Enabled {
 
-		var cacheHost, cachePort, cachePassword, cacheDB string
+		var cacheHost, cachePort, cachePassword, cacheDB, cacheURL string
+		var cacheSSL, cacheCA bool
 
 		// if there is no ExternalCacheSecret defined, we should
 		// use the redis instance provided by the operator
@@ -963,6 +1065,9 @@ TOKEN_SIGNATURE_ALGORITHM = "ES256"
 				cachePort = strconv.Itoa(m.Spec.Cache.RedisPort)
 			}
 			cacheHost = m.Name + "-redis-svc." + m.Namespace
+			redisPassword, _ := r.retrieveSecretData(context.TODO(), redisPasswordSecretName(m), m.Namespace, true, "password")
+			cachePassword = redisPassword["password"]
+			cacheSSL, cacheCA = m.Spec.Cache.TLS, m.Spec.Cache.TLS
 		} else {
 			// retrieve the connection data from ExternalCacheSecret secret
 			externalCacheData := []string{"REDIS_HOST", "REDIS_PORT", "REDIS_PASSWORD", "REDIS_DB"}
@@ -971,6 +1076,12 @@ TOKEN_SIGNATURE_ALGORITHM = "ES256"
 			cachePort = externalCacheConfig["REDIS_PORT"]
 			cachePassword = externalCacheConfig["REDIS_PASSWORD"]
 			cacheDB = externalCacheConfig["REDIS_DB"]
+
+			// retrieve the optional TLS configuration from ExternalCacheSecret secret
+			externalCacheTLS, _ := r.retrieveSecretData(context.TODO(), m.Spec.Cache.ExternalCacheSecret, m.Namespace, false, "REDIS_SSL", "REDIS_URL", "REDIS_CA_CERT")
+			cacheURL = externalCacheTLS["REDIS_URL"]
+			cacheSSL = externalRedisTLS(externalCacheTLS)
+			cacheCA = len(externalCacheTLS["REDIS_CA_CERT"]) > 0
 		}
 
 		cacheSettings := `CACHE_ENABLED = "True"
@@ -979,6 +1090,15 @@ REDIS_PORT =  "` + cachePort + `"
 REDIS_PASSWORD = "` + cachePassword + `"
 REDIS_DB = "` + cacheDB + `"
 `
+		if len(cacheURL) > 0 {
+			cacheSettings = cacheSettings + "REDIS_URL = \"" + cacheURL + "\"\n"
+		}
+		if cacheSSL {
+			cacheSettings = cacheSettings + "REDIS_SSL = True\n"
+		}
+		if cacheSSL && cacheCA {
+			cacheSettings = cacheSettings + "REDIS_SSL_CA_CERTS = \"" + redisCertsMountPath + "/ca.crt\"\n"
+		}
 		pulp_settings = pulp_settings + cacheSettings
 	}
 
@@ -1039,6 +1159,9 @@ MEDIA_ROOT = ""
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      m.Name + "-server",
 			Namespace: m.Namespace,
+			Annotations: map[string]string{
+				referencedSecretsAnnotation: r.referencedSecretsChecksum(ctx, m),
+			},
 		},
 		StringData: map[string]string{
 			"settings.py": pulp_settings,
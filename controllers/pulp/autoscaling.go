@@ -0,0 +1,220 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// autoscaledComponents returns the autoscaling configuration of the components
+// that can be scaled by a HorizontalPodAutoscaler
+func autoscaledComponents(pulp *repomanagerv1alpha1.Pulp) map[string]*repomanagerv1alpha1.Autoscaling {
+	components := map[string]*repomanagerv1alpha1.Autoscaling{
+		"api":     pulp.Spec.Api.Autoscaling,
+		"content": pulp.Spec.Content.Autoscaling,
+		"web":     pulp.Spec.Web.Autoscaling,
+	}
+
+	// pulp-web is not deployed when the services are exposed through a route
+	if strings.ToLower(pulp.Spec.IngressType) == "route" {
+		components["web"] = nil
+	}
+	return components
+}
+
+// autoscalingMin returns the minimum number of replicas of an autoscaled component
+func autoscalingMin(autoscaling *repomanagerv1alpha1.Autoscaling) int32 {
+	if autoscaling.Min == nil || *autoscaling.Min < 1 {
+		return 1
+	}
+	return *autoscaling.Min
+}
+
+// validateAutoscaling returns an error if the autoscaling configuration is not consistent
+func validateAutoscaling(component string, autoscaling *repomanagerv1alpha1.Autoscaling) error {
+	if autoscaling.Max < autoscalingMin(autoscaling) {
+		return fmt.Errorf("%v.autoscaling.max (%v) cannot be lower than %v.autoscaling.min (%v)", component, autoscaling.Max, component, autoscalingMin(autoscaling))
+	}
+	return nil
+}
+
+// autoscaledReplicas returns the number of replicas that should be set in the deployment
+// of a component: the replicas from pulp CR if autoscaling is not enabled, the current
+// replicas (managed by the autoscaler) if the deployment already exists or autoscaling.min
+// in the first deployment
+func autoscaledReplicas(autoscaling *repomanagerv1alpha1.Autoscaling, replicas int32, found *appsv1.Deployment) *int32 {
+	if autoscaling == nil {
+		return &replicas
+	}
+	if found != nil && found.Spec.Replicas != nil {
+		return found.Spec.Replicas
+	}
+	min := autoscalingMin(autoscaling)
+	return &min
+}
+
+// hpaForComponent returns the HorizontalPodAutoscaler of a pulp component
+func hpaForComponent(m *repomanagerv1alpha1.Pulp, component string, autoscaling *repomanagerv1alpha1.Autoscaling) *autoscalingv2.HorizontalPodAutoscaler {
+	metrics := []autoscalingv2.MetricSpec{}
+	if autoscaling.TargetCPUUtilization != nil {
+		metrics = append(metrics, resourceMetric(corev1.ResourceCPU, *autoscaling.TargetCPUUtilization))
+	}
+	if autoscaling.TargetMemoryUtilization != nil {
+		metrics = append(metrics, resourceMetric(corev1.ResourceMemory, *autoscaling.TargetMemoryUtilization))
+	}
+	metrics = append(metrics, autoscaling.Metrics...)
+	if len(metrics) == 0 {
+		metrics = append(metrics, resourceMetric(corev1.ResourceCPU, 80))
+	}
+
+	min := autoscalingMin(autoscaling)
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.Name + "-" + component,
+			Namespace: m.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       m.Spec.DeploymentType + "-" + component,
+				"app.kubernetes.io/instance":   m.Spec.DeploymentType + "-" + component + "-" + m.Name,
+				"app.kubernetes.io/component":  component,
+				"app.kubernetes.io/part-of":    m.Spec.DeploymentType,
+				"app.kubernetes.io/managed-by": m.Spec.DeploymentType + "-operator",
+			},
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       m.Name + "-" + component,
+			},
+			MinReplicas: &min,
+			MaxReplicas: autoscaling.Max,
+			Metrics:     metrics,
+			Behavior:    autoscaling.Behavior,
+		},
+	}
+}
+
+// resourceMetric returns a MetricSpec targeting the average utilization of a resource
+func resourceMetric(resourceName corev1.ResourceName, utilization int32) autoscalingv2.MetricSpec {
+	return autoscalingv2.MetricSpec{
+		Type: autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{
+			Name: resourceName,
+			Target: autoscalingv2.MetricTarget{
+				Type:               autoscalingv2.UtilizationMetricType,
+				AverageUtilization: &utilization,
+			},
+		},
+	}
+}
+
+// hpaScalesOnCPU returns true if the HorizontalPodAutoscaler of a component uses the CPU utilization
+// (defined explicitly or the default target) as a metric
+func hpaScalesOnCPU(m *repomanagerv1alpha1.Pulp, component string, autoscaling *repomanagerv1alpha1.Autoscaling) bool {
+	if autoscaling == nil {
+		return false
+	}
+	for _, metric := range hpaForComponent(m, component, autoscaling).Spec.Metrics {
+		if metric.Resource != nil && metric.Resource.Name == corev1.ResourceCPU {
+			return true
+		}
+		if metric.ContainerResource != nil && metric.ContainerResource.Name == corev1.ResourceCPU {
+			return true
+		}
+	}
+	return false
+}
+
+// hpaController creates, reconciles and removes the {api,content,web} HorizontalPodAutoscalers
+func (r *PulpReconciler) hpaController(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) (ctrl.Result, error) {
+
+	components := autoscaledComponents(pulp)
+	for _, component := range []string{"api", "content", "web"} {
+		autoscaling := components[component]
+		hpaFound := &autoscalingv2.HorizontalPodAutoscaler{}
+		err := r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-" + component, Namespace: pulp.Namespace}, hpaFound)
+
+		// remove the HPA if autoscaling is not defined anymore
+		if autoscaling == nil {
+			if err == nil {
+				log.Info("Removing " + component + " HPA ...")
+				if err = r.Delete(ctx, hpaFound); err != nil {
+					log.Error(err, "Failed to remove "+component+" HPA")
+					return ctrl.Result{}, err
+				}
+				r.recorder.Event(pulp, corev1.EventTypeNormal, "Deleted", component+" HPA removed")
+			} else if !errors.IsNotFound(err) {
+				log.Error(err, "Failed to get "+component+" HPA")
+				return ctrl.Result{}, err
+			}
+			continue
+		}
+
+		if err := validateAutoscaling(component, autoscaling); err != nil {
+			log.Error(err, "Invalid "+component+" autoscaling configuration")
+			r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid "+component+" autoscaling configuration: "+err.Error())
+			return ctrl.Result{}, err
+		}
+
+		expectedHPA := hpaForComponent(pulp, component, autoscaling)
+		ctrl.SetControllerReference(pulp, expectedHPA, r.Scheme)
+
+		// Create HPA if not found
+		if err != nil && errors.IsNotFound(err) {
+			log.Info("Creating a new " + component + " HPA ...")
+			if err = r.Create(ctx, expectedHPA); err != nil {
+				log.Error(err, "Failed to create new "+component+" HPA")
+				r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to create new "+component+" HPA")
+				return ctrl.Result{}, err
+			}
+			// HPA created successfully - return and requeue
+			r.recorder.Event(pulp, corev1.EventTypeNormal, "Created", component+" HPA created")
+			return ctrl.Result{Requeue: true}, nil
+		} else if err != nil {
+			log.Error(err, "Failed to get "+component+" HPA")
+			return ctrl.Result{}, err
+		}
+
+		// Reconcile HPA
+		if !equality.Semantic.DeepDerivative(expectedHPA.Spec, hpaFound.Spec) || len(expectedHPA.Spec.Metrics) != len(hpaFound.Spec.Metrics) {
+			log.Info("The " + component + " HPA has been modified! Reconciling ...")
+			r.recorder.Event(pulp, corev1.EventTypeNormal, "Updating", "Reconciling "+component+" HPA")
+			if err = r.Update(ctx, expectedHPA); err != nil {
+				log.Error(err, "Error trying to update the "+component+" HPA object ... ")
+				r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to reconcile "+component+" HPA")
+				return ctrl.Result{}, err
+			}
+			r.recorder.Event(pulp, corev1.EventTypeNormal, "Updated", component+" HPA reconciled")
+			return ctrl.Result{Requeue: true}, nil
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,36 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// validateContainers returns an error if an init or sidecar container from pulp CR uses the
+// name of another container from the pod (like the container defined by the operator)
+func validateContainers(component string, podSpec corev1.PodSpec) error {
+	names := map[string]bool{}
+	for _, container := range append(append([]corev1.Container{}, podSpec.Containers...), podSpec.InitContainers...) {
+		if names[container.Name] {
+			return fmt.Errorf("%v: the container name %v is reserved or already in use", component, container.Name)
+		}
+		names[container.Name] = true
+	}
+	return nil
+}
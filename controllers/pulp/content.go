@@ -18,7 +18,6 @@ package pulp
 
 import (
 	"context"
-	"os"
 	"strconv"
 	"time"
 
@@ -44,13 +43,34 @@ func (r *PulpReconciler) pulpContentController(ctx context.Context, pulp *repoma
 	// conditionType is used to update .status.conditions with the current resource state
 	conditionType := cases.Title(language.English, cases.Compact).String(pulp.Spec.DeploymentType) + "-Content-Ready"
 
+	// the pods are redeployed when settings.py is modified
+	settingsChecksum, err := r.settingsChecksum(ctx, pulp)
+	if err != nil {
+		log.Error(err, "Failed to get the checksum of settings.py")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "ErrorGettingSettings", "Failed to get "+pulp.Name+"-server secret: "+err.Error())
+		return ctrl.Result{}, err
+	}
+
 	// Controller Deployment
 	cntDeployment := &appsv1.Deployment{}
-	err := r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-content", Namespace: pulp.Namespace}, cntDeployment)
-	newCntDeployment := r.deploymentForPulpContent(pulp)
+	err = r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-content", Namespace: pulp.Namespace}, cntDeployment)
+	newCntDeployment := r.deploymentForPulpContent(pulp, settingsChecksum)
+	if err := validateVolumes("content", newCntDeployment.Spec.Template.Spec); err != nil {
+		log.Error(err, "Invalid content volumes configuration")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "InvalidVolumes", err.Error())
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid content volumes configuration: "+err.Error())
+		return ctrl.Result{}, err
+	}
+	if err := validateContainers("content", newCntDeployment.Spec.Template.Spec); err != nil {
+		log.Error(err, "Invalid content containers configuration")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "InvalidContainers", err.Error())
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid content containers configuration: "+err.Error())
+		return ctrl.Result{}, err
+	}
 	if err != nil && errors.IsNotFound(err) {
 		log.Info("Creating a new Pulp Content Deployment", "Deployment.Namespace", newCntDeployment.Namespace, "Deployment.Name", newCntDeployment.Name)
 		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "CreatingContentDeployment", "Creating "+pulp.Name+"-content deployment resource")
+		r.warnEnvOverrides(pulp, "content", newCntDeployment.Spec.Template.Spec)
 		err = r.Create(ctx, newCntDeployment)
 		if err != nil {
 			log.Error(err, "Failed to create new Pulp Content Deployment", "Deployment.Namespace", newCntDeployment.Namespace, "Deployment.Name", newCntDeployment.Name)
@@ -66,11 +86,15 @@ func (r *PulpReconciler) pulpContentController(ctx context.Context, pulp *repoma
 		return ctrl.Result{}, err
 	}
 
+	// the number of replicas is managed by the HPA when autoscaling is enabled
+	newCntDeployment.Spec.Replicas = autoscaledReplicas(pulp.Spec.Content.Autoscaling, *effectivePulp(pulp).Spec.Content.Replicas, cntDeployment)
+
 	// Reconcile Deployment
-	if !equality.Semantic.DeepDerivative(newCntDeployment.Spec, cntDeployment.Spec) {
+	if !equality.Semantic.DeepDerivative(newCntDeployment.Spec, cntDeployment.Spec) || podTemplateModified(newCntDeployment.Spec.Template, cntDeployment.Spec.Template) {
 		log.Info("The Content Deployment has been modified! Reconciling ...")
 		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "UpdatingContentDeployment", "Reconciling "+pulp.Name+"-content deployment resource")
 		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updating", "Reconciling content deployment")
+		r.warnEnvOverrides(pulp, "content", newCntDeployment.Spec.Template.Spec)
 		err = r.Update(ctx, newCntDeployment)
 		if err != nil {
 			log.Error(err, "Error trying to update the Content Deployment object ... ")
@@ -125,7 +149,8 @@ func (r *PulpReconciler) pulpContentController(ctx context.Context, pulp *repoma
 }
 
 // deploymentForPulpContent returns a pulp-content Deployment object
-func (r *PulpReconciler) deploymentForPulpContent(m *repomanagerv1alpha1.Pulp) *appsv1.Deployment {
+func (r *PulpReconciler) deploymentForPulpContent(m *repomanagerv1alpha1.Pulp, settingsChecksum string) *appsv1.Deployment {
+	m = effectivePulp(m)
 
 	labels := map[string]string{
 		"app.kubernetes.io/name":       m.Spec.DeploymentType + "-content",
@@ -138,26 +163,24 @@ func (r *PulpReconciler) deploymentForPulpContent(m *repomanagerv1alpha1.Pulp) *
 		"owner":                        "pulp-dev",
 	}
 
-	replicas := m.Spec.Content.Replicas
+	replicas := autoscaledReplicas(m.Spec.Content.Autoscaling, *m.Spec.Content.Replicas, nil)
 	ls := labelsForPulpContent(m)
 
 	affinity := &corev1.Affinity{}
 	if m.Spec.Content.Affinity.NodeAffinity != nil {
 		affinity.NodeAffinity = m.Spec.Content.Affinity.NodeAffinity
 	}
+	if m.Spec.Content.Affinity.PodAffinity != nil {
+		affinity.PodAffinity = m.Spec.Content.Affinity.PodAffinity
+	}
+	if m.Spec.Content.Affinity.PodAntiAffinity != nil {
+		affinity.PodAntiAffinity = m.Spec.Content.Affinity.PodAntiAffinity
+	}
 
 	// pulp image is built to run with user 0
-	// we are enforcing the containers to run as 1000
-	runAsUser := int64(700)
-	fsGroup := int64(700)
-	podSecurityContext := &corev1.PodSecurityContext{}
+	// we are enforcing the containers to run as 700 (restricted Pod Security Standard)
+	podSecurityContext := componentPodSecurityContext("content", m.Spec.Content.PodSecurityContext)
 	IsOpenShift, _ := controllers.IsOpenShift()
-	if !IsOpenShift {
-		podSecurityContext = &corev1.PodSecurityContext{
-			RunAsUser: &runAsUser,
-			FSGroup:   &fsGroup,
-		}
-	}
 
 	nodeSelector := map[string]string{}
 	if m.Spec.Content.NodeSelector != nil {
@@ -285,7 +308,7 @@ func (r *PulpReconciler) deploymentForPulpContent(m *repomanagerv1alpha1.Pulp) *
 
 	envVars := []corev1.EnvVar{
 		{Name: "PULP_GUNICORN_TIMEOUT", Value: strconv.Itoa(m.Spec.Content.GunicornTimeout)},
-		{Name: "PULP_CONTENT_WORKERS", Value: strconv.Itoa(m.Spec.Content.GunicornWorkers)},
+		{Name: "PULP_CONTENT_WORKERS", Value: strconv.Itoa(*m.Spec.Content.GunicornWorkers)},
 	}
 
 	var dbHost, dbPort string
@@ -351,6 +374,7 @@ func (r *PulpReconciler) deploymentForPulpContent(m *repomanagerv1alpha1.Pulp) *
 			redisEnvVars := []corev1.EnvVar{
 				{Name: "REDIS_SERVICE_HOST", Value: cacheHost},
 				{Name: "REDIS_SERVICE_PORT", Value: cachePort},
+				redisPasswordEnvVar(m),
 			}
 			envVars = append(envVars, redisEnvVars...)
 		} else {
@@ -399,6 +423,7 @@ func (r *PulpReconciler) deploymentForPulpContent(m *repomanagerv1alpha1.Pulp) *
 			}
 			envVars = append(envVars, redisEnvVars...)
 		}
+		envVars = append(envVars, redisTLSEnvVars(m)...)
 	}
 
 	if m.Spec.SigningSecret != "" {
@@ -411,6 +436,9 @@ func (r *PulpReconciler) deploymentForPulpContent(m *repomanagerv1alpha1.Pulp) *
 		envVars = append(envVars, signingKeyEnvVars...)
 	}
 
+	// the variables from pulp CR are added after the ones defined by the operator (so they take precedence)
+	envVars = append(envVars, m.Spec.Content.Env...)
+
 	volumeMounts := []corev1.VolumeMount{
 		{
 			Name:      m.Name + "-server",
@@ -475,12 +503,17 @@ func (r *PulpReconciler) deploymentForPulpContent(m *repomanagerv1alpha1.Pulp) *
 		volumes, volumeMounts = mountCASpec(m, volumes, volumeMounts)
 	}
 
-	Image := os.Getenv("RELATED_IMAGE_PULP")
-	if len(m.Spec.Image) > 0 && len(m.Spec.ImageVersion) > 0 {
-		Image = m.Spec.Image + ":" + m.Spec.ImageVersion
-	} else if Image == "" {
-		Image = "quay.io/pulp/pulp:stable"
-	}
+	// mountExternalDBCerts adds the client certificates from the external database secret
+	volumes, volumeMounts = mountExternalDBCerts(m, volumes, volumeMounts)
+
+	// mountRedisCerts adds the CA bundle used to verify the redis server certificate
+	volumes, volumeMounts = mountRedisCerts(m, volumes, volumeMounts)
+
+	// the volumes from pulp CR are added after the ones defined by the operator
+	volumes = append(volumes, m.Spec.Content.Volumes...)
+	volumeMounts = append(volumeMounts, m.Spec.Content.VolumeMounts...)
+
+	Image := deployedComponentImage(m, "content")
 
 	readinessProbe := m.Spec.Content.ReadinessProbe
 	if readinessProbe == nil {
@@ -509,7 +542,7 @@ func (r *PulpReconciler) deploymentForPulpContent(m *repomanagerv1alpha1.Pulp) *
 			Labels:    labels,
 		},
 		Spec: appsv1.DeploymentSpec{
-			Replicas: &replicas,
+			Replicas: replicas,
 			Strategy: m.Spec.Content.Strategy,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: ls,
@@ -517,35 +550,47 @@ func (r *PulpReconciler) deploymentForPulpContent(m *repomanagerv1alpha1.Pulp) *
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: ls,
+					Annotations: map[string]string{
+						settingsChecksumAnnotation: settingsChecksum,
+					},
 				},
 				Spec: corev1.PodSpec{
-					Affinity:                  affinity,
-					SecurityContext:           podSecurityContext,
-					NodeSelector:              nodeSelector,
-					Tolerations:               toleration,
-					Volumes:                   volumes,
-					ServiceAccountName:        m.Name,
-					TopologySpreadConstraints: topologySpreadConstraint,
-					Containers: []corev1.Container{{
+					Affinity:                      affinity,
+					SecurityContext:               podSecurityContext,
+					NodeSelector:                  nodeSelector,
+					Tolerations:                   toleration,
+					Volumes:                       volumes,
+					ServiceAccountName:            m.Name,
+					PriorityClassName:             m.Spec.Content.PriorityClassName,
+					RuntimeClassName:              m.Spec.Content.RuntimeClassName,
+					HostAliases:                   m.Spec.Content.HostAliases,
+					TerminationGracePeriodSeconds: terminationGracePeriod(m.Spec.Content.TerminationGracePeriodSeconds, defaultTerminationGracePeriod),
+					TopologySpreadConstraints:     topologySpreadConstraint,
+					InitContainers:                m.Spec.Content.InitContainers,
+					Containers: append([]corev1.Container{{
 						Name:            "content",
 						Image:           Image,
 						ImagePullPolicy: corev1.PullPolicy(m.Spec.ImagePullPolicy),
 						Args:            []string{"pulp-content"},
 						Resources:       resources,
 						Env:             envVars,
+						EnvFrom:         m.Spec.Content.EnvFrom,
 						Ports: []corev1.ContainerPort{{
 							ContainerPort: 24816,
 							Protocol:      "TCP",
 						}},
-						LivenessProbe:  livenessProbe,
-						ReadinessProbe: readinessProbe,
-						VolumeMounts:   volumeMounts,
-					}},
+						LivenessProbe:   livenessProbe,
+						StartupProbe:    m.Spec.Content.StartupProbe,
+						ReadinessProbe:  readinessProbe,
+						VolumeMounts:    volumeMounts,
+						SecurityContext: componentSecurityContext("content", m.Spec.Content.SecurityContext),
+					}}, m.Spec.Content.SidecarContainers...),
 				},
 			},
 		},
 	}
 	// Set Pulp instance as the owner and controller
+	setPodMetadata(m, &dep.Spec.Template, m.Spec.Content.PodLabels, m.Spec.Content.PodAnnotations)
 	ctrl.SetControllerReference(m, dep, r.Scheme)
 	return dep
 }
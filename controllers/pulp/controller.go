@@ -20,10 +20,13 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	policy "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -36,6 +39,8 @@ import (
 
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
@@ -52,6 +57,14 @@ type PulpReconciler struct {
 	RESTConfig *rest.Config
 	Scheme     *runtime.Scheme
 	recorder   record.EventRecorder
+
+	// PulpAPIURL returns the address of Pulp API used to get the tasks queue.
+	// If not defined, the <pulp-name>-api-svc service is used.
+	PulpAPIURL func(*repomanagerv1alpha1.Pulp) string
+
+	// apis caches the availability of the optional APIs (see isAPIAvailable)
+	apis     map[string]apiAvailability
+	apisLock sync.Mutex
 }
 
 //+kubebuilder:rbac:groups=repo-manager.pulpproject.org,namespace=pulp,resources=pulps,verbs=get;list;watch;create;update;patch;delete
@@ -61,10 +74,16 @@ type PulpReconciler struct {
 //+kubebuilder:rbac:groups=config.openshift.io,resources=ingresses,verbs=get;list;watch
 //+kubebuilder:rbac:groups=route.openshift.io,namespace=pulp,resources=routes;routes/custom-host,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,namespace=pulp,resources=pods;pods/log,verbs=get;list;
+//+kubebuilder:rbac:groups=core,namespace=pulp,resources=pods,verbs=patch;update
+//+kubebuilder:rbac:groups=core,namespace=pulp,resources=pods/exec,verbs=create
 //+kubebuilder:rbac:groups=core;rbac.authorization.k8s.io,namespace=pulp,resources=roles;rolebindings;serviceaccounts,verbs=create;update;patch;delete;watch;get;list;
 //+kubebuilder:rbac:groups=core,namespace=pulp,resources=configmaps;secrets;services;persistentvolumeclaims,verbs=create;update;patch;delete;watch;get;list;
 //+kubebuilder:rbac:groups="",namespace=pulp,resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups=policy,namespace=pulp,resources=poddisruptionbudgets,verbs=get;list;create;delete;patch;update;watch
+//+kubebuilder:rbac:groups=batch,namespace=pulp,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=autoscaling,namespace=pulp,resources=horizontalpodautoscalers,verbs=get;list;create;delete;patch;update;watch
+//+kubebuilder:rbac:groups=autoscaling.k8s.io,namespace=pulp,resources=verticalpodautoscalers,verbs=get;list;create;delete;patch;update;watch
+//+kubebuilder:rbac:groups=monitoring.coreos.com,namespace=pulp,resources=servicemonitors,verbs=get;list;create;delete;patch;update;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -124,7 +143,9 @@ func (r *PulpReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		})
 	}
 
-	if strings.ToLower(pulp.Spec.IngressType) != "route" && pulp.Spec.ImageVersion != pulp.Spec.ImageWebVersion {
+	// digests of different images cannot be compared, so the versions are only checked for tags
+	if strings.ToLower(pulp.Spec.IngressType) != "route" && pulp.Spec.ImageVersion != pulp.Spec.ImageWebVersion &&
+		!digestReference(pulp.Spec.Image, pulp.Spec.ImageVersion) && !digestReference(pulp.Spec.ImageWeb, pulp.Spec.ImageWebVersion) {
 		err := fmt.Errorf("image version and image web version should be equal ")
 		log.Error(err, "ImageVersion should be equal to ImageWebVersion")
 		return ctrl.Result{}, err
@@ -193,6 +214,16 @@ func (r *PulpReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		}
 	}
 
+	log.V(1).Info("Running upgrade tasks")
+	pulpController, err = r.upgradeController(ctx, pulp, log)
+	if err != nil {
+		return pulpController, err
+	} else if pulpController.Requeue {
+		return pulpController, nil
+	} else if pulpController.RequeueAfter > 0 {
+		return pulpController, nil
+	}
+
 	log.V(1).Info("Running API tasks")
 	pulpController, err = r.pulpApiController(ctx, pulp, log)
 	if err != nil {
@@ -255,6 +286,56 @@ func (r *PulpReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		return pulpController, nil
 	}
 
+	log.V(1).Info("Running HPA tasks")
+	pulpController, err = r.hpaController(ctx, pulp, log)
+	if err != nil {
+		return pulpController, err
+	} else if pulpController.Requeue {
+		return pulpController, nil
+	} else if pulpController.RequeueAfter > 0 {
+		return pulpController, nil
+	}
+
+	log.V(1).Info("Running VPA tasks")
+	pulpController, err = r.vpaController(ctx, pulp, log)
+	if err != nil {
+		return pulpController, err
+	} else if pulpController.Requeue {
+		return pulpController, nil
+	} else if pulpController.RequeueAfter > 0 {
+		return pulpController, nil
+	}
+
+	log.V(1).Info("Running common labels tasks")
+	pulpController, err = r.commonLabelsController(ctx, pulp, log)
+	if err != nil {
+		return pulpController, err
+	} else if pulpController.Requeue {
+		return pulpController, nil
+	} else if pulpController.RequeueAfter > 0 {
+		return pulpController, nil
+	}
+
+	log.V(1).Info("Running profile status tasks")
+	pulpController, err = r.profileController(ctx, pulp, log)
+	if err != nil {
+		return pulpController, err
+	} else if pulpController.Requeue {
+		return pulpController, nil
+	} else if pulpController.RequeueAfter > 0 {
+		return pulpController, nil
+	}
+
+	log.V(1).Info("Running images status tasks")
+	pulpController, err = r.imagesStatusController(ctx, pulp, log)
+	if err != nil {
+		return pulpController, err
+	} else if pulpController.Requeue {
+		return pulpController, nil
+	} else if pulpController.RequeueAfter > 0 {
+		return pulpController, nil
+	}
+
 	log.V(1).Info("Running status tasks")
 	pulpController, err = r.pulpStatus(ctx, pulp, log)
 	if err != nil {
@@ -263,6 +344,24 @@ func (r *PulpReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		return pulpController, nil
 	}
 
+	// the worker autoscaling runs last because it requeues periodically to poll the tasks queue
+	log.V(1).Info("Running worker autoscaling tasks")
+	pulpController, err = r.workerAutoscalingController(ctx, pulp, log)
+	if err != nil {
+		return pulpController, err
+	}
+
+	// the VPA recommendations are not watched, so they are refreshed periodically
+	if vpaDefined(pulp) && (pulpController.RequeueAfter == 0 || pulpController.RequeueAfter > vpaRecommendationsInterval) {
+		pulpController.RequeueAfter = vpaRecommendationsInterval
+	}
+
+	// the role label of the redis pods must follow the master elected by Sentinel
+	if len(pulp.Spec.Cache.ExternalCacheSecret) == 0 && pulp.Spec.Cache.Enabled && redisSentinelMode(pulp) &&
+		(pulpController.RequeueAfter == 0 || pulpController.RequeueAfter > redisTopologyInterval) {
+		pulpController.RequeueAfter = redisTopologyInterval
+	}
+
 	// If we get into here it means that there is no reconciliation
 	// nor controller tasks pending
 	log.Info("Operator tasks synced")
@@ -275,6 +374,11 @@ func (r *PulpReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	// creates a new eventRecorder to be able to interact with events
 	r.recorder = mgr.GetEventRecorderFor("Pulp")
 
+	// index the secrets referenced in Pulp CRs so that we can find them when a secret is modified
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &repomanagerv1alpha1.Pulp{}, referencedSecretsIndexKey, indexReferencedSecrets); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&repomanagerv1alpha1.Pulp{}).
 		Owns(&appsv1.StatefulSet{}).
@@ -283,5 +387,8 @@ func (r *PulpReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Owns(&corev1.Secret{}).
 		Owns(&corev1.ConfigMap{}).
 		Owns(&policy.PodDisruptionBudget{}).
+		Owns(&autoscalingv2.HorizontalPodAutoscaler{}).
+		Owns(&batchv1.Job{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.findPulpForSecret)).
 		Complete(r)
 }
@@ -2,6 +2,7 @@ package pulp_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"path/filepath"
 	"reflect"
@@ -14,6 +15,7 @@ import (
 	"github.com/onsi/gomega/format"
 	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -21,7 +23,10 @@ import (
 	routev1 "github.com/openshift/api/route/v1"
 	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -36,6 +41,7 @@ const (
 	ApiName       = "pulp-operator-api"
 	ContentName   = "pulp-operator-content"
 	WorkerName    = "pulp-operator-worker"
+	WebName       = "pulp-operator-web"
 	OperatorType  = "pulp"
 
 	timeout  = time.Second
@@ -172,6 +178,17 @@ var _ = Describe("Pulp controller", Ordered, func() {
 		{Name: "POSTGRES_SERVICE_PORT", Value: "5432"},
 		{Name: "REDIS_SERVICE_HOST", Value: PulpName + "-redis-svc." + PulpNamespace},
 		{Name: "REDIS_SERVICE_PORT", Value: strconv.Itoa(6379)},
+		{
+			Name: "REDIS_SERVICE_PASSWORD",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: PulpName + "-redis-password",
+					},
+					Key: "password",
+				},
+			},
+		},
 	}
 
 	envVarsContent := []corev1.EnvVar{
@@ -181,6 +198,17 @@ var _ = Describe("Pulp controller", Ordered, func() {
 		{Name: "POSTGRES_SERVICE_PORT", Value: "5432"},
 		{Name: "REDIS_SERVICE_HOST", Value: PulpName + "-redis-svc." + PulpNamespace},
 		{Name: "REDIS_SERVICE_PORT", Value: strconv.Itoa(6379)},
+		{
+			Name: "REDIS_SERVICE_PASSWORD",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: PulpName + "-redis-password",
+					},
+					Key: "password",
+				},
+			},
+		},
 	}
 
 	envVarsWorker := []corev1.EnvVar{
@@ -188,6 +216,17 @@ var _ = Describe("Pulp controller", Ordered, func() {
 		{Name: "POSTGRES_SERVICE_PORT", Value: "5432"},
 		{Name: "REDIS_SERVICE_HOST", Value: PulpName + "-redis-svc." + PulpNamespace},
 		{Name: "REDIS_SERVICE_PORT", Value: strconv.Itoa(6379)},
+		{
+			Name: "REDIS_SERVICE_PASSWORD",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: PulpName + "-redis-password",
+					},
+					Key: "password",
+				},
+			},
+		},
 	}
 
 	volumeMountsSts := []corev1.VolumeMount{
@@ -682,6 +721,7 @@ var _ = Describe("Pulp controller", Ordered, func() {
 		pulpSettings := runtime.RawExtension{
 			Raw: []byte(`{"Api_Root": "/pulp/"}`),
 		}
+		pulpApiReplicas, pulpContentReplicas, pulpWorkerReplicas, pulpWebReplicas := int32(1), int32(1), int32(1), int32(1)
 
 		// [TODO] Instead of using this hardcoded pulp CR we should
 		// use the samples from config/samples/ folder during each
@@ -701,16 +741,16 @@ var _ = Describe("Pulp controller", Ordered, func() {
 				ImageVersion:    "latest",
 				ImageWebVersion: "latest",
 				Api: repomanagerv1alpha1.Api{
-					Replicas: 1,
+					Replicas: &pulpApiReplicas,
 				},
 				Content: repomanagerv1alpha1.Content{
-					Replicas: 1,
+					Replicas: &pulpContentReplicas,
 				},
 				Worker: repomanagerv1alpha1.Worker{
-					Replicas: 1,
+					Replicas: &pulpWorkerReplicas,
 				},
 				Web: repomanagerv1alpha1.Web{
-					Replicas: 1,
+					Replicas: &pulpWebReplicas,
 				},
 				Database: repomanagerv1alpha1.Database{
 					PostgresStorageRequirements: "5Gi",
@@ -816,6 +856,234 @@ var _ = Describe("Pulp controller", Ordered, func() {
 		})
 	})
 
+	Context("When postgres_parameters are defined in pulp CR", func() {
+		It("Should mount the postgres configuration in database sts", func() {
+			By("Defining postgres parameters")
+
+			waitPulpOperatorFinish(ctx, createdPulp)
+
+			createdPulp.Spec.Database.PostgresParameters = map[string]string{
+				"shared_buffers": "256MB",
+				"work_mem":       "8MB",
+			}
+			objectUpdate(ctx, createdPulp)
+
+			waitPulpOperatorFinish(ctx, createdPulp)
+
+			By("Checking the postgres-config configmap")
+			createdConfigMap := &corev1.ConfigMap{}
+			objectGet(ctx, createdConfigMap, PulpName+"-postgres-config")
+			Expect(createdConfigMap.Data["postgresql.conf"]).Should(ContainSubstring("shared_buffers = '256MB'"))
+			Expect(createdConfigMap.Data["postgresql.conf"]).Should(ContainSubstring("work_mem = '8MB'"))
+
+			By("Checking if sts template is configured with the postgres configuration")
+			objectGet(ctx, createdSts, StsName)
+			Expect(createdSts.Spec.Template.Spec.Containers[0].Args).Should(ContainElement("config_file=/etc/postgresql/conf.d/postgresql.conf"))
+			Expect(createdSts.Spec.Template.Annotations).Should(HaveKey("repo-manager.pulpproject.org/postgres-restart-checksum"))
+
+			By("Modifying a parameter that does not need a restart")
+			restartChecksum := createdSts.Spec.Template.Annotations["repo-manager.pulpproject.org/postgres-restart-checksum"]
+			createdPulp.Spec.Database.PostgresParameters["work_mem"] = "16MB"
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() string {
+				objectGet(ctx, createdConfigMap, PulpName+"-postgres-config")
+				return createdConfigMap.Data["postgresql.conf"]
+			}, time.Second*10, interval).Should(ContainSubstring("work_mem = '16MB'"))
+
+			// we expect that the database pod is not restarted
+			objectGet(ctx, createdSts, StsName)
+			Expect(createdSts.Spec.Template.Annotations["repo-manager.pulpproject.org/postgres-restart-checksum"]).Should(Equal(restartChecksum))
+		})
+	})
+
+	Context("When postgres_parameters are not valid", func() {
+		It("Should not modify the postgres configuration", func() {
+			By("Defining a reserved parameter")
+
+			createdPulp.Spec.Database.PostgresParameters["data_directory"] = "/tmp"
+			objectUpdate(ctx, createdPulp)
+
+			Eventually(func() bool {
+				objectGet(ctx, createdPulp, PulpName)
+				return v1.IsStatusConditionFalse(createdPulp.Status.Conditions, "Pulp-Database-Ready")
+			}, time.Second*10, interval).Should(BeTrue())
+
+			createdConfigMap := &corev1.ConfigMap{}
+			objectGet(ctx, createdConfigMap, PulpName+"-postgres-config")
+			Expect(createdConfigMap.Data["postgresql.conf"]).ShouldNot(ContainSubstring("data_directory"))
+
+			By("Removing postgres parameters")
+			createdPulp.Spec.Database.PostgresParameters = nil
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, types.NamespacedName{Name: PulpName + "-postgres-config", Namespace: PulpNamespace}, createdConfigMap)
+				return errors.IsNotFound(err)
+			}, time.Second*10, interval).Should(BeTrue())
+		})
+	})
+
+	Context("When database metrics are enabled in pulp CR", func() {
+		It("Should deploy the postgres_exporter sidecar", func() {
+			By("Enabling database metrics")
+
+			waitPulpOperatorFinish(ctx, createdPulp)
+
+			createdPulp.Spec.Database.Metrics.Enabled = true
+			objectUpdate(ctx, createdPulp)
+
+			waitPulpOperatorFinish(ctx, createdPulp)
+
+			By("Checking if sts template has the postgres-exporter container")
+			objectGet(ctx, createdSts, StsName)
+			Expect(createdSts.Spec.Template.Spec.Containers).Should(HaveLen(2))
+			Expect(createdSts.Spec.Template.Spec.Containers[1].Name).Should(Equal("postgres-exporter"))
+
+			By("Checking if database service exposes the metrics port")
+			createdSvc := &corev1.Service{}
+			objectGet(ctx, createdSvc, PulpName+"-database-svc")
+			Expect(createdSvc.Spec.Ports).Should(ContainElement(HaveField("Name", "metrics")))
+
+			By("Disabling database metrics")
+			createdPulp.Spec.Database.Metrics.Enabled = false
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() int {
+				objectGet(ctx, createdSts, StsName)
+				return len(createdSts.Spec.Template.Spec.Containers)
+			}, time.Second*10, interval).Should(Equal(1))
+		})
+	})
+
+	Context("When database replicas are defined in pulp CR", func() {
+		It("Should configure the streaming replication", func() {
+			By("Increasing the number of database replicas")
+
+			waitPulpOperatorFinish(ctx, createdPulp)
+
+			createdPulp.Spec.Database.Replicas = 2
+			objectUpdate(ctx, createdPulp)
+
+			waitPulpOperatorFinish(ctx, createdPulp)
+
+			By("Checking if sts is configured with the replicas")
+			objectGet(ctx, createdSts, StsName)
+			Expect(*createdSts.Spec.Replicas).Should(Equal(int32(2)))
+			Expect(createdSts.Spec.Template.Spec.InitContainers).Should(ContainElement(HaveField("Name", "init-replica")))
+
+			By("Checking if the postgres-config configmap has the pg_hba.conf")
+			createdConfigMap := &corev1.ConfigMap{}
+			objectGet(ctx, createdConfigMap, PulpName+"-postgres-config")
+			Expect(createdConfigMap.Data["pg_hba.conf"]).Should(ContainSubstring("replication"))
+
+			By("Checking the database services")
+			createdSvc := &corev1.Service{}
+			objectGet(ctx, createdSvc, PulpName+"-database-svc")
+			Expect(createdSvc.Spec.Selector).Should(HaveKeyWithValue("statefulset.kubernetes.io/pod-name", StsName+"-0"))
+			createdRoSvc := &corev1.Service{}
+			objectGet(ctx, createdRoSvc, PulpName+"-database-ro-svc")
+			Expect(createdRoSvc.Spec.Selector).Should(HaveKeyWithValue("repo-manager.pulpproject.org/database-role", "replica"))
+
+			By("Removing the database replicas")
+			createdPulp.Spec.Database.Replicas = 1
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, types.NamespacedName{Name: PulpName + "-database-ro-svc", Namespace: PulpNamespace}, createdRoSvc)
+				return errors.IsNotFound(err)
+			}, time.Second*10, interval).Should(BeTrue())
+		})
+	})
+
+	Context("When database.external_db_secret defines the client certificates and connection options", func() {
+		It("Should mount the certificates and render the options in settings.py", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			if len(createdPulp.Spec.Database.ExternalDBSecret) > 0 {
+				Skip("Pulp CR is already configured with an external database")
+			}
+
+			By("Creating the external database secret")
+			externalDBSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-external-database",
+					Namespace: PulpNamespace,
+				},
+				StringData: map[string]string{
+					"POSTGRES_HOST":          "postgres.example.com",
+					"POSTGRES_PORT":          "5432",
+					"POSTGRES_USERNAME":      "pulp",
+					"POSTGRES_PASSWORD":      "password",
+					"POSTGRES_DB_NAME":       "pulp",
+					"POSTGRES_SSLMODE":       "verify-full",
+					"POSTGRES_SSLROOTCERT":   "root-ca",
+					"POSTGRES_SSLCERT":       "client-cert",
+					"POSTGRES_SSLKEY":        "client-key",
+					"POSTGRES_EXTRA_OPTIONS": "connect_timeout=10",
+					"POSTGRES_CONN_MAX_AGE":  "300",
+				},
+			}
+			Expect(k8sClient.Create(ctx, externalDBSecret)).Should(Succeed())
+			createdPulp.Spec.Database.ExternalDBSecret = "test-external-database"
+			objectUpdate(ctx, createdPulp)
+
+			By("Checking the DATABASES settings")
+			serverSecret := &corev1.Secret{}
+			Eventually(func() string {
+				objectGet(ctx, serverSecret, PulpName+"-server")
+				return string(serverSecret.Data["settings.py"])
+			}, timeout, interval).Should(And(
+				ContainSubstring("'CONN_MAX_AGE': 300,"),
+				ContainSubstring("'OPTIONS': { 'connect_timeout': '10', 'sslcert': '/etc/pulp/postgres-certs/postgresql.crt', "+
+					"'sslkey': '/etc/pulp/postgres-certs/postgresql.key', 'sslmode': 'verify-full', 'sslrootcert': '/etc/pulp/postgres-certs/root.crt' },"),
+			))
+
+			By("Checking the certificates volume of the api pods")
+			apiDeployment := &appsv1.Deployment{}
+			var certsVolume *corev1.Volume
+			Eventually(func() bool {
+				objectGet(ctx, apiDeployment, ApiName)
+				for i := range apiDeployment.Spec.Template.Spec.Volumes {
+					if apiDeployment.Spec.Template.Spec.Volumes[i].Name == "postgres-certs" {
+						certsVolume = &apiDeployment.Spec.Template.Spec.Volumes[i]
+						return true
+					}
+				}
+				return false
+			}, timeout, interval).Should(BeTrue())
+			Expect(certsVolume.Secret.SecretName).Should(Equal("test-external-database"))
+			Expect(*certsVolume.Secret.DefaultMode).Should(Equal(int32(0640)))
+			Expect(certsVolume.Secret.Items).Should(Equal([]corev1.KeyToPath{
+				{Key: "POSTGRES_SSLROOTCERT", Path: "root.crt"},
+				{Key: "POSTGRES_SSLCERT", Path: "postgresql.crt"},
+				{Key: "POSTGRES_SSLKEY", Path: "postgresql.key"},
+			}))
+			Expect(apiDeployment.Spec.Template.Spec.Containers[0].VolumeMounts).Should(ContainElement(corev1.VolumeMount{
+				Name:      "postgres-certs",
+				MountPath: "/etc/pulp/postgres-certs",
+				ReadOnly:  true,
+			}))
+
+			By("Ignoring an invalid POSTGRES_CONN_MAX_AGE")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			objectGet(ctx, externalDBSecret, "test-external-database")
+			externalDBSecret.StringData = map[string]string{"POSTGRES_CONN_MAX_AGE": "forever"}
+			objectUpdate(ctx, externalDBSecret)
+			Eventually(func() string {
+				objectGet(ctx, serverSecret, PulpName+"-server")
+				return string(serverSecret.Data["settings.py"])
+			}, timeout, interval).Should(ContainSubstring("'CONN_MAX_AGE': 0,"))
+
+			By("Going back to the database deployed by the operator")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Database.ExternalDBSecret = ""
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() string {
+				objectGet(ctx, serverSecret, PulpName+"-server")
+				return string(serverSecret.Data["settings.py"])
+			}, timeout, interval).ShouldNot(ContainSubstring("postgres.example.com"))
+			waitPulpOperatorFinish(ctx, createdPulp)
+			Expect(k8sClient.Delete(ctx, externalDBSecret)).Should(Succeed())
+		})
+	})
+
 	Context("When pulp.Spec.Database.PostgresStorageClass and cluster SC are not defined", func() {
 		It("Should configure the database pod template with an emptyDir volume", func() {
 
@@ -908,10 +1176,11 @@ var _ = Describe("Pulp controller", Ordered, func() {
 			waitPulpOperatorFinish(ctx, createdPulp)
 
 			// request api deployment state to kube-api
-			objectGet(ctx, createdApiDeployment, ApiName)
-
-			// we expect that pulp controller update sts with the new image defined in pulp CR
-			Expect(createdApiDeployment.Spec.Template.Spec.Containers[0].Image).Should(Equal("quay.io/pulp/pulp2:stable"))
+			// we expect that pulp controller update the deployment (once the upgrade finishes) with the new image defined in pulp CR
+			Eventually(func() string {
+				objectGet(ctx, createdApiDeployment, ApiName)
+				return createdApiDeployment.Spec.Template.Spec.Containers[0].Image
+			}, timeout*10, interval).Should(Equal("quay.io/pulp/pulp2:stable"))
 
 			// make sure that there is no tasks running before proceeding
 			waitPulpOperatorFinish(ctx, createdPulp)
@@ -978,10 +1247,11 @@ var _ = Describe("Pulp controller", Ordered, func() {
 			waitPulpOperatorFinish(ctx, createdPulp)
 
 			// request content deployment state to kube-api
-			objectGet(ctx, createdContentDeployment, ContentName)
-
-			// we expect that pulp controller update sts with the new image defined in pulp CR
-			Expect(createdContentDeployment.Spec.Template.Spec.Containers[0].Image).Should(Equal("quay.io/pulp/pulp2:stable"))
+			// we expect that pulp controller update the deployment (once the upgrade finishes) with the new image defined in pulp CR
+			Eventually(func() string {
+				objectGet(ctx, createdContentDeployment, ContentName)
+				return createdContentDeployment.Spec.Template.Spec.Containers[0].Image
+			}, timeout*10, interval).Should(Equal("quay.io/pulp/pulp2:stable"))
 
 			// rollback the config to not impact other tests
 			waitPulpOperatorFinish(ctx, createdPulp)
@@ -1040,10 +1310,11 @@ var _ = Describe("Pulp controller", Ordered, func() {
 			waitPulpOperatorFinish(ctx, createdPulp)
 
 			// request worker deployment state to kube-api
-			objectGet(ctx, createdWorkerDeployment, WorkerName)
-
-			// we expect that pulp controller update sts with the new image defined in pulp CR
-			Expect(createdWorkerDeployment.Spec.Template.Spec.Containers[0].Image).Should(Equal("quay.io/pulp/pulp2:stable"))
+			// we expect that pulp controller update the deployment (once the upgrade finishes) with the new image defined in pulp CR
+			Eventually(func() string {
+				objectGet(ctx, createdWorkerDeployment, WorkerName)
+				return createdWorkerDeployment.Spec.Template.Spec.Containers[0].Image
+			}, timeout*10, interval).Should(Equal("quay.io/pulp/pulp2:stable"))
 
 			// rollback the config to not impact other tests
 			waitPulpOperatorFinish(ctx, createdPulp)
@@ -1079,6 +1350,1255 @@ var _ = Describe("Pulp controller", Ordered, func() {
 	// - route crd not bootstrapped (https://github.com/kubernetes-sigs/controller-runtime/issues/1191)
 	// - could not understand why the podList from route.go is always empty during the tests
 	// - what happens with the exec command to run route_paths.py during the tests?
+	Context("When a secret referenced in pulp CR is modified", func() {
+		It("Should reconcile the pulp-server secret and the pulpcore deployments", func() {
+			By("Creating a sso secret")
+
+			waitPulpOperatorFinish(ctx, createdPulp)
+
+			ssoSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-sso-secret",
+					Namespace: PulpNamespace,
+				},
+				StringData: map[string]string{
+					"social_auth_keycloak_key":        "pulp",
+					"social_auth_keycloak_secret":     "secret",
+					"social_auth_keycloak_public_key": "key",
+					"keycloak_host":                   "keycloak.example.com",
+					"keycloak_protocol":               "https",
+					"keycloak_port":                   "443",
+					"keycloak_realm":                  "pulp",
+				},
+			}
+			Expect(k8sClient.Create(ctx, ssoSecret)).Should(Succeed())
+
+			createdPulp.Spec.SSOSecret = "test-sso-secret"
+			objectUpdate(ctx, createdPulp)
+
+			serverSecret := &corev1.Secret{}
+			Eventually(func() string {
+				objectGet(ctx, serverSecret, PulpName+"-server")
+				return string(serverSecret.Data["settings.py"])
+			}, time.Second*10, interval).Should(ContainSubstring("keycloak.example.com"))
+
+			waitPulpOperatorFinish(ctx, createdPulp)
+
+			By("Modifying the sso secret")
+			createdDeployment := &appsv1.Deployment{}
+			objectGet(ctx, createdDeployment, ApiName)
+			settingsChecksum := createdDeployment.Spec.Template.Annotations["repo-manager.pulpproject.org/settings-checksum"]
+
+			objectGet(ctx, ssoSecret, "test-sso-secret")
+			ssoSecret.StringData = map[string]string{"keycloak_host": "sso.example.com"}
+			objectUpdate(ctx, ssoSecret)
+
+			Eventually(func() string {
+				objectGet(ctx, serverSecret, PulpName+"-server")
+				return string(serverSecret.Data["settings.py"])
+			}, time.Second*10, interval).Should(ContainSubstring("sso.example.com"))
+
+			By("Checking if the deployments were redeployed")
+			for _, deployment := range []string{ApiName, ContentName, WorkerName} {
+				Eventually(func() string {
+					objectGet(ctx, createdDeployment, deployment)
+					return createdDeployment.Spec.Template.Annotations["repo-manager.pulpproject.org/settings-checksum"]
+				}, time.Second*10, interval).ShouldNot(Equal(settingsChecksum))
+			}
+
+			By("Removing the sso secret from pulp CR")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			createdPulp.Spec.SSOSecret = ""
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() string {
+				objectGet(ctx, serverSecret, PulpName+"-server")
+				return string(serverSecret.Data["settings.py"])
+			}, time.Second*10, interval).ShouldNot(ContainSubstring("sso.example.com"))
+			Expect(k8sClient.Delete(ctx, ssoSecret)).Should(Succeed())
+		})
+	})
+
+	Context("When the managed redis password is rotated", func() {
+		It("Should reconcile the pulp-server secret and the redis deployment", func() {
+			By("Checking the redis password secret")
+
+			waitPulpOperatorFinish(ctx, createdPulp)
+
+			redisSecret := &corev1.Secret{}
+			objectGet(ctx, redisSecret, PulpName+"-redis-password")
+			Expect(redisSecret.Data["password"]).ShouldNot(BeEmpty())
+
+			serverSecret := &corev1.Secret{}
+			objectGet(ctx, serverSecret, PulpName+"-server")
+			Expect(string(serverSecret.Data["settings.py"])).Should(ContainSubstring("REDIS_PASSWORD = \"" + string(redisSecret.Data["password"]) + "\""))
+
+			redisDeployment := &appsv1.Deployment{}
+			objectGet(ctx, redisDeployment, PulpName+"-redis")
+			Expect(redisDeployment.Spec.Template.Spec.Containers[0].Args).Should(Equal([]string{"redis-server", "/redis-conf/redis.conf"}))
+			Expect(redisDeployment.Spec.Template.Spec.InitContainers[0].Command[2]).Should(ContainSubstring("requirepass ${REDIS_PASSWORD}"))
+			passwordChecksum := redisDeployment.Spec.Template.Annotations["repo-manager.pulpproject.org/redis-password-checksum"]
+
+			By("Modifying the redis password")
+			redisSecret.StringData = map[string]string{"password": "rotated-password"}
+			objectUpdate(ctx, redisSecret)
+
+			Eventually(func() string {
+				objectGet(ctx, serverSecret, PulpName+"-server")
+				return string(serverSecret.Data["settings.py"])
+			}, time.Second*10, interval).Should(ContainSubstring("REDIS_PASSWORD = \"rotated-password\""))
+
+			Eventually(func() string {
+				objectGet(ctx, redisDeployment, PulpName+"-redis")
+				return redisDeployment.Spec.Template.Annotations["repo-manager.pulpproject.org/redis-password-checksum"]
+			}, time.Second*10, interval).ShouldNot(Equal(passwordChecksum))
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When cache.tls is enabled in pulp CR", func() {
+		It("Should configure redis and pulpcore to use TLS", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			createdPulp.Spec.Cache.TLS = true
+			objectUpdate(ctx, createdPulp)
+
+			By("Checking the generated certificates")
+			tlsSecret := &corev1.Secret{}
+			Eventually(func() bool {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: PulpName + "-redis-tls", Namespace: PulpNamespace}, tlsSecret) == nil
+			}, timeout, interval).Should(BeTrue())
+			Expect(tlsSecret.Data).Should(HaveKey("ca.crt"))
+			Expect(tlsSecret.Data).Should(HaveKey("tls.crt"))
+			Expect(tlsSecret.Data).Should(HaveKey("tls.key"))
+
+			By("Checking the redis deployment")
+			redisDeployment := &appsv1.Deployment{}
+			Eventually(func() []string {
+				objectGet(ctx, redisDeployment, PulpName+"-redis")
+				return redisDeployment.Spec.Template.Spec.Containers[0].Args
+			}, timeout, interval).Should(ContainElement("--tls-port"))
+
+			By("Checking the settings and the pulpcore deployments")
+			serverSecret := &corev1.Secret{}
+			Eventually(func() string {
+				objectGet(ctx, serverSecret, PulpName+"-server")
+				return string(serverSecret.Data["settings.py"])
+			}, timeout, interval).Should(ContainSubstring("REDIS_SSL_CA_CERTS = \"/etc/pulp/redis-certs/ca.crt\""))
+
+			createdDeployment := &appsv1.Deployment{}
+			for _, deployment := range []string{ApiName, ContentName, WorkerName} {
+				Eventually(func() []corev1.EnvVar {
+					objectGet(ctx, createdDeployment, deployment)
+					return createdDeployment.Spec.Template.Spec.Containers[0].Env
+				}, timeout, interval).Should(ContainElement(corev1.EnvVar{Name: "REDIS_SERVICE_SSL", Value: "true"}))
+			}
+
+			By("Disabling TLS")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			createdPulp.Spec.Cache.TLS = false
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() []string {
+				objectGet(ctx, redisDeployment, PulpName+"-redis")
+				return redisDeployment.Spec.Template.Spec.Containers[0].Args
+			}, timeout, interval).ShouldNot(ContainElement("--tls-port"))
+			Eventually(func() string {
+				objectGet(ctx, serverSecret, PulpName+"-server")
+				return string(serverSecret.Data["settings.py"])
+			}, timeout, interval).ShouldNot(ContainSubstring("REDIS_SSL"))
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When cache.mode is defined as sentinel in pulp CR", func() {
+		It("Should deploy redis as a statefulset with sentinel", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			createdPulp.Spec.Cache.Mode = "sentinel"
+			objectUpdate(ctx, createdPulp)
+
+			By("Checking the redis statefulset")
+			redisSts := &appsv1.StatefulSet{}
+			Eventually(func() bool {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: PulpName + "-redis", Namespace: PulpNamespace}, redisSts) == nil
+			}, timeout, interval).Should(BeTrue())
+			Expect(*redisSts.Spec.Replicas).Should(Equal(int32(3)))
+			Expect(redisSts.Spec.Template.Spec.Containers).Should(HaveLen(2))
+			Expect(redisSts.Spec.Template.Spec.Affinity.PodAntiAffinity).ShouldNot(BeNil())
+
+			By("Checking the redis deployment was removed")
+			Eventually(func() bool {
+				return errors.IsNotFound(k8sClient.Get(ctx, types.NamespacedName{Name: PulpName + "-redis", Namespace: PulpNamespace}, &appsv1.Deployment{}))
+			}, timeout, interval).Should(BeTrue())
+
+			By("Checking the services and the PDB")
+			redisSvc := &corev1.Service{}
+			objectGet(ctx, redisSvc, PulpName+"-redis-svc")
+			Expect(redisSvc.Spec.Selector).Should(HaveKeyWithValue("repo-manager.pulpproject.org/redis-role", "master"))
+			Eventually(func() bool {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: PulpName + "-redis-sentinel-svc", Namespace: PulpNamespace}, &corev1.Service{}) == nil
+			}, timeout, interval).Should(BeTrue())
+			Eventually(func() bool {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: PulpName + "-redis-pdb", Namespace: PulpNamespace}, &policy.PodDisruptionBudget{}) == nil
+			}, timeout, interval).Should(BeTrue())
+
+			By("Checking the sentinel settings")
+			serverSecret := &corev1.Secret{}
+			Eventually(func() string {
+				objectGet(ctx, serverSecret, PulpName+"-server")
+				return string(serverSecret.Data["settings.py"])
+			}, timeout, interval).Should(ContainSubstring("REDIS_HOST =  \"" + PulpName + "-redis-svc." + PulpNamespace + "\""))
+
+			By("Going back to standalone mode")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			createdPulp.Spec.Cache.Mode = "standalone"
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() bool {
+				return errors.IsNotFound(k8sClient.Get(ctx, types.NamespacedName{Name: PulpName + "-redis", Namespace: PulpNamespace}, &appsv1.StatefulSet{}))
+			}, timeout, interval).Should(BeTrue())
+			Eventually(func() bool {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: PulpName + "-redis", Namespace: PulpNamespace}, &appsv1.Deployment{}) == nil
+			}, timeout, interval).Should(BeTrue())
+			Eventually(func() map[string]string {
+				objectGet(ctx, redisSvc, PulpName+"-redis-svc")
+				return redisSvc.Spec.Selector
+			}, timeout, interval).ShouldNot(HaveKey("repo-manager.pulpproject.org/redis-role"))
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When redis_config is defined in pulp CR", func() {
+		It("Should mount the redis configuration in redis deployment", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			maxMemory := resource.MustParse("256Mi")
+			appendOnly := true
+			createdPulp.Spec.Cache.RedisConfig = &repomanagerv1alpha1.RedisConfig{
+				MaxMemory:       &maxMemory,
+				MaxMemoryPolicy: "allkeys-lru",
+				AppendOnly:      &appendOnly,
+				ExtraConfig:     map[string]string{"timeout": "300"},
+			}
+			objectUpdate(ctx, createdPulp)
+
+			By("Checking the redis configmap")
+			configMap := &corev1.ConfigMap{}
+			Eventually(func() bool {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: PulpName + "-redis-config", Namespace: PulpNamespace}, configMap) == nil
+			}, timeout, interval).Should(BeTrue())
+			Expect(configMap.Data["redis.conf"]).Should(ContainSubstring("maxmemory 268435456\n"))
+			Expect(configMap.Data["redis.conf"]).Should(ContainSubstring("maxmemory-policy allkeys-lru\n"))
+			Expect(configMap.Data["redis.conf"]).Should(ContainSubstring("appendonly yes\n"))
+			Expect(configMap.Data["redis.conf"]).Should(ContainSubstring("timeout 300\n"))
+
+			By("Checking the redis deployment")
+			redisDeployment := &appsv1.Deployment{}
+			Eventually(func() []string {
+				objectGet(ctx, redisDeployment, PulpName+"-redis")
+				return redisDeployment.Spec.Template.Spec.Containers[0].Args
+			}, timeout, interval).Should(ContainElement("/etc/redis/conf.d/redis.conf"))
+			configChecksum := redisDeployment.Spec.Template.Annotations["repo-manager.pulpproject.org/redis-config-checksum"]
+			Expect(configChecksum).ShouldNot(BeEmpty())
+
+			By("Modifying the redis configuration")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			createdPulp.Spec.Cache.RedisConfig.MaxMemoryPolicy = "allkeys-lfu"
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() string {
+				objectGet(ctx, redisDeployment, PulpName+"-redis")
+				return redisDeployment.Spec.Template.Annotations["repo-manager.pulpproject.org/redis-config-checksum"]
+			}, timeout, interval).ShouldNot(Equal(configChecksum))
+
+			By("Removing redis_config")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			createdPulp.Spec.Cache.RedisConfig = nil
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() bool {
+				return errors.IsNotFound(k8sClient.Get(ctx, types.NamespacedName{Name: PulpName + "-redis-config", Namespace: PulpNamespace}, configMap))
+			}, timeout, interval).Should(BeTrue())
+			Eventually(func() []string {
+				objectGet(ctx, redisDeployment, PulpName+"-redis")
+				return redisDeployment.Spec.Template.Spec.Containers[0].Args
+			}, timeout, interval).ShouldNot(ContainElement("/etc/redis/conf.d/redis.conf"))
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When redis_config.maxmemory is greater than the memory limit", func() {
+		It("Should not create the redis configuration", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			maxMemory := resource.MustParse("2Gi")
+			createdPulp.Spec.Cache.RedisResourceRequirements = corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			}
+			createdPulp.Spec.Cache.RedisConfig = &repomanagerv1alpha1.RedisConfig{MaxMemory: &maxMemory}
+			objectUpdate(ctx, createdPulp)
+
+			Consistently(func() bool {
+				return errors.IsNotFound(k8sClient.Get(ctx, types.NamespacedName{Name: PulpName + "-redis-config", Namespace: PulpNamespace}, &corev1.ConfigMap{}))
+			}, time.Second*3, interval).Should(BeTrue())
+
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Cache.RedisResourceRequirements = corev1.ResourceRequirements{}
+			createdPulp.Spec.Cache.RedisConfig = nil
+			objectUpdate(ctx, createdPulp)
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When redis_config.maxmemory does not leave room for redis in the memory limit", func() {
+		It("Should not create the redis configuration", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			maxMemory := resource.MustParse("1Gi")
+			createdPulp.Spec.Cache.RedisResourceRequirements = corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			}
+			createdPulp.Spec.Cache.RedisConfig = &repomanagerv1alpha1.RedisConfig{MaxMemory: &maxMemory}
+			objectUpdate(ctx, createdPulp)
+
+			Consistently(func() bool {
+				return errors.IsNotFound(k8sClient.Get(ctx, types.NamespacedName{Name: PulpName + "-redis-config", Namespace: PulpNamespace}, &corev1.ConfigMap{}))
+			}, time.Second*3, interval).Should(BeTrue())
+
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Cache.RedisResourceRequirements = corev1.ResourceRequirements{}
+			createdPulp.Spec.Cache.RedisConfig = nil
+			objectUpdate(ctx, createdPulp)
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When api.autoscaling is defined in pulp CR", func() {
+		It("Should create an HPA and stop enforcing the api replicas", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			min, targetCPU := int32(1), int32(70)
+			createdPulp.Spec.Api.Autoscaling = &repomanagerv1alpha1.Autoscaling{
+				Min:                  &min,
+				Max:                  4,
+				TargetCPUUtilization: &targetCPU,
+			}
+			objectUpdate(ctx, createdPulp)
+
+			By("Checking the api HPA")
+			hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+			Eventually(func() bool {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: PulpName + "-api", Namespace: PulpNamespace}, hpa) == nil
+			}, timeout, interval).Should(BeTrue())
+			Expect(hpa.Spec.ScaleTargetRef.Name).Should(Equal(PulpName + "-api"))
+			Expect(*hpa.Spec.MinReplicas).Should(Equal(int32(1)))
+			Expect(hpa.Spec.MaxReplicas).Should(Equal(int32(4)))
+			Expect(*hpa.Spec.Metrics[0].Resource.Target.AverageUtilization).Should(Equal(int32(70)))
+
+			By("Scaling the api deployment as the HPA would do")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			apiDeployment := &appsv1.Deployment{}
+			objectGet(ctx, apiDeployment, PulpName+"-api")
+			replicas := int32(3)
+			apiDeployment.Spec.Replicas = &replicas
+			objectUpdate(ctx, apiDeployment)
+			Consistently(func() int32 {
+				objectGet(ctx, apiDeployment, PulpName+"-api")
+				return *apiDeployment.Spec.Replicas
+			}, time.Second*3, interval).Should(Equal(int32(3)))
+
+			By("Removing api.autoscaling")
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Api.Autoscaling = nil
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() bool {
+				return errors.IsNotFound(k8sClient.Get(ctx, types.NamespacedName{Name: PulpName + "-api", Namespace: PulpNamespace}, hpa))
+			}, timeout, interval).Should(BeTrue())
+			Eventually(func() int32 {
+				objectGet(ctx, apiDeployment, PulpName+"-api")
+				return *apiDeployment.Spec.Replicas
+			}, timeout, interval).Should(Equal(*createdPulp.Spec.Api.Replicas))
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When worker.autoscaling is defined in pulp CR", func() {
+		It("Should scale the workers based on the tasks queue", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			cooldown := int32(0)
+			createdPulp.Spec.Worker.Autoscaling = &repomanagerv1alpha1.WorkerAutoscaling{
+				Min:               1,
+				Max:               5,
+				TasksPerWorker:    2,
+				PollInterval:      5,
+				ScaleDownCooldown: &cooldown,
+			}
+			setFakePulpTasks(6, 1)
+			objectUpdate(ctx, createdPulp)
+
+			By("Checking that the workers are scaled up")
+			workerDeployment := &appsv1.Deployment{}
+			Eventually(func() int32 {
+				objectGet(ctx, workerDeployment, WorkerName)
+				return *workerDeployment.Spec.Replicas
+			}, timeout*10, interval).Should(Equal(int32(4)))
+			Eventually(func() []int32 {
+				objectGet(ctx, createdPulp, PulpName)
+				if status := createdPulp.Status.WorkerAutoscaling; status != nil {
+					return []int32{status.Replicas, status.WaitingTasks, status.RunningTasks}
+				}
+				return nil
+			}, timeout*10, interval).Should(Equal([]int32{4, 6, 1}))
+			Expect(createdPulp.Status.WorkerAutoscaling.LastScaleTime).ShouldNot(BeNil())
+
+			By("Checking that the workers are scaled down to the minimum when the queue is empty")
+			setFakePulpTasks(0, 0)
+			Eventually(func() int32 {
+				objectGet(ctx, workerDeployment, WorkerName)
+				return *workerDeployment.Spec.Replicas
+			}, timeout*10, interval).Should(Equal(int32(1)))
+
+			By("Checking that the scale down waits for the cooldown period")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			cooldown = int32(600)
+			createdPulp.Spec.Worker.Autoscaling.ScaleDownCooldown = &cooldown
+			setFakePulpTasks(20, 0)
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() int32 {
+				objectGet(ctx, workerDeployment, WorkerName)
+				return *workerDeployment.Spec.Replicas
+			}, timeout*10, interval).Should(Equal(int32(5)))
+			setFakePulpTasks(0, 0)
+			Consistently(func() int32 {
+				objectGet(ctx, workerDeployment, WorkerName)
+				return *workerDeployment.Spec.Replicas
+			}, time.Second*7, interval).Should(Equal(int32(5)))
+
+			By("Removing worker.autoscaling")
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Worker.Autoscaling = nil
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() int32 {
+				objectGet(ctx, workerDeployment, WorkerName)
+				return *workerDeployment.Spec.Replicas
+			}, timeout*10, interval).Should(Equal(*createdPulp.Spec.Worker.Replicas))
+			Eventually(func() *repomanagerv1alpha1.WorkerAutoscalingStatus {
+				objectGet(ctx, createdPulp, PulpName)
+				return createdPulp.Status.WorkerAutoscaling
+			}, timeout*10, interval).Should(BeNil())
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When the image version is modified in pulp CR", func() {
+		It("Should run the database migrations before updating the deployments", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			Expect(v1.IsStatusConditionTrue(createdPulp.Status.Conditions, "Pulp-Migration-Complete")).Should(BeTrue())
+			oldJobs := &batchv1.JobList{}
+			k8sClient.List(ctx, oldJobs, client.InNamespace(PulpNamespace), client.MatchingLabels{"app.kubernetes.io/component": "migration"})
+			Expect(oldJobs.Items).Should(HaveLen(1))
+
+			By("Checking that a failed migration holds the rollout")
+			createdPulp.Spec.ImageVersion = "failed-migration"
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() string {
+				objectGet(ctx, createdPulp, PulpName)
+				if condition := v1.FindStatusCondition(createdPulp.Status.Conditions, "Pulp-Migration-Complete"); condition != nil {
+					return condition.Reason
+				}
+				return ""
+			}, timeout*10, interval).Should(Equal("MigrationFailed"))
+			apiDeployment := &appsv1.Deployment{}
+			objectGet(ctx, apiDeployment, ApiName)
+			Expect(apiDeployment.Spec.Template.Spec.Containers[0].Image).Should(Equal("quay.io/pulp/pulp:latest"))
+
+			By("Checking that a new migration runs for the new image")
+			createdPulp.Spec.ImageVersion = "stable"
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() string {
+				objectGet(ctx, apiDeployment, ApiName)
+				return apiDeployment.Spec.Template.Spec.Containers[0].Image
+			}, timeout*10, interval).Should(Equal("quay.io/pulp/pulp:stable"))
+			objectGet(ctx, createdPulp, PulpName)
+			Expect(v1.IsStatusConditionTrue(createdPulp.Status.Conditions, "Pulp-Migration-Complete")).Should(BeTrue())
+
+			By("Checking that the jobs from previous migrations are removed")
+			Eventually(func() []string {
+				jobs := &batchv1.JobList{}
+				k8sClient.List(ctx, jobs, client.InNamespace(PulpNamespace), client.MatchingLabels{"app.kubernetes.io/component": "migration"})
+				images := []string{}
+				for _, job := range jobs.Items {
+					images = append(images, job.Spec.Template.Spec.Containers[0].Image)
+				}
+				return images
+			}, timeout*10, interval).Should(Equal([]string{"quay.io/pulp/pulp:stable"}))
+
+			waitPulpOperatorFinish(ctx, createdPulp)
+			createdPulp.Spec.ImageVersion = "latest"
+			objectUpdate(ctx, createdPulp)
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When the pulpcore image is upgraded", func() {
+		It("Should roll out the components in order and roll back if a step fails", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			workerReplicas := *createdPulp.Spec.Worker.Replicas
+			createdPulp.Spec.ImageVersion = "stable"
+			objectUpdate(ctx, createdPulp)
+
+			By("Checking that the upgrade completes")
+			Eventually(func() string {
+				objectGet(ctx, createdPulp, PulpName)
+				if createdPulp.Status.Upgrade != nil {
+					return createdPulp.Status.Upgrade.Phase
+				}
+				return ""
+			}, timeout*20, interval).Should(Equal("Completed"))
+			Expect(createdPulp.Status.Upgrade.FromImage).Should(Equal("quay.io/pulp/pulp:latest"))
+			Expect(createdPulp.Status.Upgrade.ToImage).Should(Equal("quay.io/pulp/pulp:stable"))
+			Expect(v1.IsStatusConditionTrue(createdPulp.Status.Conditions, "Pulp-Upgrade-Complete")).Should(BeTrue())
+			for _, name := range []string{ApiName, ContentName, WorkerName} {
+				deployment := &appsv1.Deployment{}
+				objectGet(ctx, deployment, name)
+				Expect(deployment.Spec.Template.Spec.Containers[0].Image).Should(Equal("quay.io/pulp/pulp:stable"))
+			}
+			workerDeployment := &appsv1.Deployment{}
+			objectGet(ctx, workerDeployment, WorkerName)
+			Expect(*workerDeployment.Spec.Replicas).Should(Equal(workerReplicas))
+
+			By("Checking that a failed rollout is rolled back")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			createdPulp.Spec.ImageVersion = "failed-rollout"
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() string {
+				objectGet(ctx, createdPulp, PulpName)
+				return createdPulp.Status.Upgrade.Phase
+			}, timeout*20, interval).Should(Equal("Failed"))
+			Expect(createdPulp.Status.Upgrade.Message).Should(ContainSubstring(ApiName + " deployment exceeded its progress deadline"))
+			for _, name := range []string{ApiName, ContentName, WorkerName} {
+				deployment := &appsv1.Deployment{}
+				objectGet(ctx, deployment, name)
+				Expect(deployment.Spec.Template.Spec.Containers[0].Image).Should(Equal("quay.io/pulp/pulp:stable"))
+			}
+			objectGet(ctx, workerDeployment, WorkerName)
+			Expect(*workerDeployment.Spec.Replicas).Should(Equal(workerReplicas))
+
+			By("Checking that the rolled back deployments are kept with the previous image")
+			Consistently(func() string {
+				apiDeployment := &appsv1.Deployment{}
+				objectGet(ctx, apiDeployment, ApiName)
+				return apiDeployment.Spec.Template.Spec.Containers[0].Image
+			}, time.Second*3, interval).Should(Equal("quay.io/pulp/pulp:stable"))
+
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.ImageVersion = "latest"
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() string {
+				objectGet(ctx, createdPulp, PulpName)
+				return createdPulp.Status.Upgrade.ToImage + " " + createdPulp.Status.Upgrade.Phase
+			}, timeout*20, interval).Should(Equal("quay.io/pulp/pulp:latest Completed"))
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When api.env and api.env_from are defined in pulp CR", func() {
+		It("Should add the variables after the ones defined by the operator", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			createdPulp.Spec.Api.Env = []corev1.EnvVar{
+				{Name: "HTTP_PROXY", Value: "http://proxy.example.com:3128"},
+				{Name: "PULP_GUNICORN_TIMEOUT", Value: "120"},
+			}
+			createdPulp.Spec.Api.EnvFrom = []corev1.EnvFromSource{{
+				ConfigMapRef: &corev1.ConfigMapEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "pulp-extra-env"},
+				},
+			}}
+			objectUpdate(ctx, createdPulp)
+
+			By("Checking the api deployment env")
+			apiDeployment := &appsv1.Deployment{}
+			Eventually(func() []corev1.EnvFromSource {
+				objectGet(ctx, apiDeployment, PulpName+"-api")
+				return apiDeployment.Spec.Template.Spec.Containers[0].EnvFrom
+			}, timeout, interval).Should(Equal(createdPulp.Spec.Api.EnvFrom))
+			env := apiDeployment.Spec.Template.Spec.Containers[0].Env
+			Expect(env[len(env)-2:]).Should(Equal(createdPulp.Spec.Api.Env))
+
+			By("Removing api.env and api.env_from")
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Api.Env = nil
+			createdPulp.Spec.Api.EnvFrom = nil
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() bool {
+				objectGet(ctx, apiDeployment, PulpName+"-api")
+				return len(apiDeployment.Spec.Template.Spec.Containers[0].EnvFrom) == 0
+			}, timeout, interval).Should(BeTrue())
+			Eventually(func() []corev1.EnvVar {
+				objectGet(ctx, apiDeployment, PulpName+"-api")
+				return apiDeployment.Spec.Template.Spec.Containers[0].Env
+			}, timeout, interval).ShouldNot(ContainElement(HaveField("Name", "HTTP_PROXY")))
+			Expect(apiDeployment.Spec.Template.Spec.Containers[0].Env).ShouldNot(ContainElement(corev1.EnvVar{Name: "PULP_GUNICORN_TIMEOUT", Value: "120"}))
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When worker.volumes and worker.volume_mounts are defined in pulp CR", func() {
+		It("Should add the volumes to the worker pods", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			createdPulp.Spec.Worker.Volumes = []corev1.Volume{{
+				Name: "gpg-keyring",
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{SecretName: "pulp-gpg-keyring"},
+				},
+			}}
+			createdPulp.Spec.Worker.VolumeMounts = []corev1.VolumeMount{{
+				Name:      "gpg-keyring",
+				MountPath: "/var/lib/pulp/.gnupg",
+				ReadOnly:  true,
+			}}
+			objectUpdate(ctx, createdPulp)
+
+			By("Checking the worker deployment volumes")
+			workerDeployment := &appsv1.Deployment{}
+			Eventually(func() bool {
+				objectGet(ctx, workerDeployment, PulpName+"-worker")
+				volumes := workerDeployment.Spec.Template.Spec.Volumes
+				return volumes[len(volumes)-1].Name == "gpg-keyring"
+			}, timeout, interval).Should(BeTrue())
+			volumeMounts := workerDeployment.Spec.Template.Spec.Containers[0].VolumeMounts
+			Expect(volumeMounts[len(volumeMounts)-1]).Should(Equal(createdPulp.Spec.Worker.VolumeMounts[0]))
+
+			By("Checking that the reserved volume names cannot be used")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Worker.Volumes[0].Name = PulpName + "-server"
+			createdPulp.Spec.Worker.VolumeMounts[0].Name = PulpName + "-server"
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() string {
+				objectGet(ctx, createdPulp, PulpName)
+				if condition := v1.FindStatusCondition(createdPulp.Status.Conditions, "Pulp-Worker-Ready"); condition != nil {
+					return condition.Reason
+				}
+				return ""
+			}, timeout, interval).Should(Equal("InvalidVolumes"))
+
+			By("Removing worker.volumes and worker.volume_mounts")
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Worker.Volumes = nil
+			createdPulp.Spec.Worker.VolumeMounts = nil
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() bool {
+				objectGet(ctx, workerDeployment, PulpName+"-worker")
+				volumes := workerDeployment.Spec.Template.Spec.Volumes
+				return volumes[len(volumes)-1].Name != "gpg-keyring"
+			}, timeout, interval).Should(BeTrue())
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When database.volumes and database.volume_mounts are defined in pulp CR", func() {
+		It("Should add the volumes to the database pods", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			createdPulp.Spec.Database.Volumes = []corev1.Volume{{
+				Name: "postgres-certs",
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{SecretName: "postgres-certs"},
+				},
+			}}
+			createdPulp.Spec.Database.VolumeMounts = []corev1.VolumeMount{{
+				Name:      "postgres-certs",
+				MountPath: "/etc/postgres/certs",
+				ReadOnly:  true,
+			}}
+			objectUpdate(ctx, createdPulp)
+
+			By("Checking the database statefulset volumes")
+			databaseSts := &appsv1.StatefulSet{}
+			Eventually(func() bool {
+				objectGet(ctx, databaseSts, PulpName+"-database")
+				volumes := databaseSts.Spec.Template.Spec.Volumes
+				return len(volumes) > 0 && volumes[len(volumes)-1].Name == "postgres-certs"
+			}, timeout, interval).Should(BeTrue())
+			volumeMounts := databaseSts.Spec.Template.Spec.Containers[0].VolumeMounts
+			Expect(volumeMounts[len(volumeMounts)-1]).Should(Equal(createdPulp.Spec.Database.VolumeMounts[0]))
+
+			By("Removing database.volumes and database.volume_mounts")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Database.Volumes = nil
+			createdPulp.Spec.Database.VolumeMounts = nil
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() bool {
+				objectGet(ctx, databaseSts, PulpName+"-database")
+				volumes := databaseSts.Spec.Template.Spec.Volumes
+				return len(volumes) == 0 || volumes[len(volumes)-1].Name != "postgres-certs"
+			}, timeout, interval).Should(BeTrue())
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When content.init_containers and content.sidecar_containers are defined in pulp CR", func() {
+		It("Should add the containers to the content pods", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			createdPulp.Spec.Content.InitContainers = []corev1.Container{{
+				Name:    "plugin-assets",
+				Image:   "quay.io/example/plugin-assets:latest",
+				Command: []string{"/bin/sh", "-c", "cp -r /assets/. /var/lib/pulp/assets"},
+			}}
+			createdPulp.Spec.Content.SidecarContainers = []corev1.Container{{
+				Name:  "log-shipper",
+				Image: "quay.io/example/log-shipper:latest",
+				VolumeMounts: []corev1.VolumeMount{{
+					Name:      PulpName + "-server",
+					MountPath: "/etc/pulp/settings.py",
+					SubPath:   "settings.py",
+					ReadOnly:  true,
+				}},
+			}}
+			objectUpdate(ctx, createdPulp)
+
+			By("Checking the content deployment containers")
+			contentDeployment := &appsv1.Deployment{}
+			Eventually(func() int {
+				objectGet(ctx, contentDeployment, PulpName+"-content")
+				return len(contentDeployment.Spec.Template.Spec.Containers)
+			}, timeout, interval).Should(Equal(2))
+			Expect(contentDeployment.Spec.Template.Spec.Containers[0].Name).Should(Equal("content"))
+			Expect(contentDeployment.Spec.Template.Spec.Containers[1].Name).Should(Equal("log-shipper"))
+			Expect(contentDeployment.Spec.Template.Spec.InitContainers).Should(HaveLen(1))
+			Expect(contentDeployment.Spec.Template.Spec.InitContainers[0].Name).Should(Equal("plugin-assets"))
+
+			By("Checking that the sidecar is restored if removed manually")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			objectGet(ctx, contentDeployment, PulpName+"-content")
+			contentDeployment.Spec.Template.Spec.Containers = contentDeployment.Spec.Template.Spec.Containers[:1]
+			objectUpdate(ctx, contentDeployment)
+			Eventually(func() int {
+				objectGet(ctx, contentDeployment, PulpName+"-content")
+				return len(contentDeployment.Spec.Template.Spec.Containers)
+			}, timeout, interval).Should(Equal(2))
+
+			By("Removing content.init_containers and content.sidecar_containers")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Content.InitContainers = nil
+			createdPulp.Spec.Content.SidecarContainers = nil
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() bool {
+				objectGet(ctx, contentDeployment, PulpName+"-content")
+				return len(contentDeployment.Spec.Template.Spec.Containers) == 1 && len(contentDeployment.Spec.Template.Spec.InitContainers) == 0
+			}, timeout, interval).Should(BeTrue())
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When cache.sidecar_containers is defined in pulp CR", func() {
+		It("Should add the containers to the redis pods", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			createdPulp.Spec.Cache.SidecarContainers = []corev1.Container{{
+				Name:  "log-shipper",
+				Image: "quay.io/example/log-shipper:latest",
+			}}
+			objectUpdate(ctx, createdPulp)
+
+			By("Checking the redis deployment containers")
+			redisDeployment := &appsv1.Deployment{}
+			Eventually(func() int {
+				objectGet(ctx, redisDeployment, PulpName+"-redis")
+				return len(redisDeployment.Spec.Template.Spec.Containers)
+			}, timeout, interval).Should(Equal(2))
+			Expect(redisDeployment.Spec.Template.Spec.Containers[0].Name).Should(Equal("redis"))
+			Expect(redisDeployment.Spec.Template.Spec.Containers[1].Name).Should(Equal("log-shipper"))
+
+			By("Removing cache.sidecar_containers")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Cache.SidecarContainers = nil
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() int {
+				objectGet(ctx, redisDeployment, PulpName+"-redis")
+				return len(redisDeployment.Spec.Template.Spec.Containers)
+			}, timeout, interval).Should(Equal(1))
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When the security contexts are not defined in pulp CR", func() {
+		It("Should deploy the pods with the restricted security contexts", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+
+			By("Checking the default security contexts of the api pods")
+			apiDeployment := &appsv1.Deployment{}
+			objectGet(ctx, apiDeployment, ApiName)
+			podSecurityContext := apiDeployment.Spec.Template.Spec.SecurityContext
+			Expect(*podSecurityContext.RunAsNonRoot).Should(BeTrue())
+			Expect(*podSecurityContext.RunAsUser).Should(Equal(int64(700)))
+			Expect(podSecurityContext.SeccompProfile.Type).Should(Equal(corev1.SeccompProfileTypeRuntimeDefault))
+			securityContext := apiDeployment.Spec.Template.Spec.Containers[0].SecurityContext
+			Expect(*securityContext.AllowPrivilegeEscalation).Should(BeFalse())
+			Expect(securityContext.Capabilities.Drop).Should(Equal([]corev1.Capability{"ALL"}))
+
+			By("Checking that the database pods keep the user from the image")
+			dbSts := &appsv1.StatefulSet{}
+			objectGet(ctx, dbSts, StsName)
+			Expect(dbSts.Spec.Template.Spec.SecurityContext.RunAsUser).Should(BeNil())
+			Expect(dbSts.Spec.Template.Spec.Containers[0].SecurityContext).Should(BeNil())
+
+			By("Defining api.security_context")
+			readOnlyRootFilesystem := true
+			createdPulp.Spec.Api.SecurityContext = &corev1.SecurityContext{ReadOnlyRootFilesystem: &readOnlyRootFilesystem}
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() bool {
+				objectGet(ctx, apiDeployment, ApiName)
+				securityContext := apiDeployment.Spec.Template.Spec.Containers[0].SecurityContext
+				return securityContext.ReadOnlyRootFilesystem != nil && *securityContext.ReadOnlyRootFilesystem
+			}, timeout, interval).Should(BeTrue())
+
+			By("Removing api.security_context")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Api.SecurityContext = nil
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() bool {
+				objectGet(ctx, apiDeployment, ApiName)
+				securityContext := apiDeployment.Spec.Template.Spec.Containers[0].SecurityContext
+				return securityContext.ReadOnlyRootFilesystem != nil && !*securityContext.ReadOnlyRootFilesystem
+			}, timeout, interval).Should(BeTrue())
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When the scheduling settings are defined in pulp CR", func() {
+		It("Should configure the pods with them", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+
+			By("Checking the worker termination defaults")
+			workerDeployment := &appsv1.Deployment{}
+			objectGet(ctx, workerDeployment, WorkerName)
+			Expect(*workerDeployment.Spec.Template.Spec.TerminationGracePeriodSeconds).Should(Equal(int64(600)))
+			Expect(workerDeployment.Spec.Template.Spec.Containers[0].Lifecycle.PreStop.Exec).ShouldNot(BeNil())
+
+			By("Defining the api scheduling settings")
+			gracePeriod := int64(120)
+			createdPulp.Spec.Api.PriorityClassName = "pulp-critical"
+			createdPulp.Spec.Api.TerminationGracePeriodSeconds = &gracePeriod
+			createdPulp.Spec.Api.HostAliases = []corev1.HostAlias{{IP: "10.0.0.10", Hostnames: []string{"registry.example.com"}}}
+			objectUpdate(ctx, createdPulp)
+			apiDeployment := &appsv1.Deployment{}
+			Eventually(func() string {
+				objectGet(ctx, apiDeployment, ApiName)
+				return apiDeployment.Spec.Template.Spec.PriorityClassName
+			}, timeout, interval).Should(Equal("pulp-critical"))
+			Expect(*apiDeployment.Spec.Template.Spec.TerminationGracePeriodSeconds).Should(Equal(int64(120)))
+			Expect(apiDeployment.Spec.Template.Spec.HostAliases).Should(Equal(createdPulp.Spec.Api.HostAliases))
+
+			By("Removing the api scheduling settings")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Api.PriorityClassName = ""
+			createdPulp.Spec.Api.TerminationGracePeriodSeconds = nil
+			createdPulp.Spec.Api.HostAliases = nil
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() bool {
+				objectGet(ctx, apiDeployment, ApiName)
+				return apiDeployment.Spec.Template.Spec.PriorityClassName == "" && len(apiDeployment.Spec.Template.Spec.HostAliases) == 0
+			}, timeout, interval).Should(BeTrue())
+			Expect(*apiDeployment.Spec.Template.Spec.TerminationGracePeriodSeconds).Should(Equal(int64(30)))
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When common_labels, api.pod_labels and api.pod_annotations are defined in pulp CR", func() {
+		It("Should add them to the objects and pods without modifying the selectors", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			apiDeployment := &appsv1.Deployment{}
+			objectGet(ctx, apiDeployment, ApiName)
+			selector := apiDeployment.Spec.Selector.DeepCopy()
+
+			By("Defining the labels and annotations")
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.CommonLabels = map[string]string{"team": "content"}
+			createdPulp.Spec.Api.PodLabels = map[string]string{"tier": "frontend", "app.kubernetes.io/component": "custom"}
+			createdPulp.Spec.Api.PodAnnotations = map[string]string{"example.com/scrape": "true"}
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() bool {
+				objectGet(ctx, apiDeployment, ApiName)
+				return apiDeployment.Labels["team"] == "content" && apiDeployment.Spec.Template.Labels["tier"] == "frontend"
+			}, timeout, interval).Should(BeTrue())
+			Expect(apiDeployment.Spec.Template.Labels["team"]).Should(Equal("content"))
+			Expect(apiDeployment.Spec.Template.Labels["app.kubernetes.io/component"]).Should(Equal("api"))
+			Expect(apiDeployment.Spec.Template.Annotations["example.com/scrape"]).Should(Equal("true"))
+			Expect(apiDeployment.Spec.Selector).Should(Equal(selector))
+			Eventually(func() string {
+				sts := &appsv1.StatefulSet{}
+				objectGet(ctx, sts, StsName)
+				return sts.Labels["team"]
+			}, timeout, interval).Should(Equal("content"))
+
+			By("Removing the labels and annotations")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.CommonLabels = nil
+			createdPulp.Spec.Api.PodLabels = nil
+			createdPulp.Spec.Api.PodAnnotations = nil
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() bool {
+				objectGet(ctx, apiDeployment, ApiName)
+				_, commonLabel := apiDeployment.Labels["team"]
+				_, podLabel := apiDeployment.Spec.Template.Labels["tier"]
+				_, podAnnotation := apiDeployment.Spec.Template.Annotations["example.com/scrape"]
+				return commonLabel || podLabel || podAnnotation
+			}, timeout, interval).Should(BeFalse())
+			Expect(apiDeployment.Spec.Selector).Should(Equal(selector))
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When web.affinity, web.tolerations and web.topology_spread_constraints are defined in pulp CR", func() {
+		It("Should configure the pulp-web pods with them", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+
+			By("Defining the web scheduling settings")
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Web.Affinity.PodAntiAffinity = &corev1.PodAntiAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+					Weight: 100,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app.kubernetes.io/component": "webserver"}},
+						TopologyKey:   "kubernetes.io/hostname",
+					},
+				}},
+			}
+			createdPulp.Spec.Web.Tolerations = []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "pulp", Effect: corev1.TaintEffectNoSchedule}}
+			createdPulp.Spec.Web.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{{
+				MaxSkew:           1,
+				TopologyKey:       "topology.kubernetes.io/zone",
+				WhenUnsatisfiable: corev1.ScheduleAnyway,
+				LabelSelector:     &metav1.LabelSelector{MatchLabels: map[string]string{"app.kubernetes.io/component": "webserver"}},
+			}}
+			objectUpdate(ctx, createdPulp)
+			webDeployment := &appsv1.Deployment{}
+			Eventually(func() []corev1.Toleration {
+				objectGet(ctx, webDeployment, WebName)
+				return webDeployment.Spec.Template.Spec.Tolerations
+			}, timeout, interval).Should(Equal(createdPulp.Spec.Web.Tolerations))
+			Expect(webDeployment.Spec.Template.Spec.Affinity.PodAntiAffinity).Should(Equal(createdPulp.Spec.Web.Affinity.PodAntiAffinity))
+			Expect(webDeployment.Spec.Template.Spec.TopologySpreadConstraints).Should(Equal(createdPulp.Spec.Web.TopologySpreadConstraints))
+
+			By("Removing the web scheduling settings")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Web.Affinity.PodAntiAffinity = nil
+			createdPulp.Spec.Web.Tolerations = nil
+			createdPulp.Spec.Web.TopologySpreadConstraints = nil
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() bool {
+				objectGet(ctx, webDeployment, WebName)
+				podSpec := webDeployment.Spec.Template.Spec
+				return len(podSpec.Tolerations) == 0 && len(podSpec.TopologySpreadConstraints) == 0 &&
+					(podSpec.Affinity == nil || podSpec.Affinity.PodAntiAffinity == nil)
+			}, timeout, interval).Should(BeTrue())
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When content.image is defined with a digest in pulp CR", func() {
+		It("Should deploy pulp-content with it and record it in the status", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			contentDeployment := &appsv1.Deployment{}
+			objectGet(ctx, contentDeployment, ContentName)
+			pulpcoreImage := contentDeployment.Spec.Template.Spec.Containers[0].Image
+
+			By("Defining the content image")
+			image := "quay.io/pulp/pulp@sha256:" + strings.Repeat("a", 64)
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Content.Image = image
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() string {
+				objectGet(ctx, contentDeployment, ContentName)
+				return contentDeployment.Spec.Template.Spec.Containers[0].Image
+			}, timeout, interval).Should(Equal(image))
+
+			apiDeployment := &appsv1.Deployment{}
+			objectGet(ctx, apiDeployment, ApiName)
+			Expect(apiDeployment.Spec.Template.Spec.Containers[0].Image).Should(Equal(pulpcoreImage))
+
+			By("Checking that the content image is rolled out through the upgrade and migrated")
+			Eventually(func() map[string]string {
+				objectGet(ctx, createdPulp, PulpName)
+				if createdPulp.Status.Upgrade == nil || createdPulp.Status.Upgrade.Phase != "Completed" {
+					return nil
+				}
+				return createdPulp.Status.Upgrade.ToComponentImages
+			}, timeout*10, interval).Should(Equal(map[string]string{"content": image}))
+			jobs := &batchv1.JobList{}
+			k8sClient.List(ctx, jobs, client.InNamespace(PulpNamespace), client.MatchingLabels{"app.kubernetes.io/component": "migration"})
+			migrationImages := []string{}
+			for _, job := range jobs.Items {
+				migrationImages = append(migrationImages, job.Spec.Template.Spec.Containers[0].Image)
+			}
+			Expect(migrationImages).Should(ConsistOf(pulpcoreImage, image))
+
+			Eventually(func() string {
+				objectGet(ctx, createdPulp, PulpName)
+				for _, status := range createdPulp.Status.Images {
+					if status.Component == "content" {
+						return status.Image
+					}
+				}
+				return ""
+			}, timeout, interval).Should(Equal(image))
+
+			By("Removing the content image")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Content.Image = ""
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() string {
+				objectGet(ctx, contentDeployment, ContentName)
+				return contentDeployment.Spec.Template.Spec.Containers[0].Image
+			}, timeout, interval).Should(Equal(pulpcoreImage))
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When worker_pools are defined in pulp CR", func() {
+		It("Should create a deployment and a PDB for each pool", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			workerDeployment := &appsv1.Deployment{}
+			objectGet(ctx, workerDeployment, WorkerName)
+			workerSelector := workerDeployment.Spec.Selector.DeepCopy()
+
+			By("Defining a worker pool")
+			minAvailable := intstr.FromInt(1)
+			resources := corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("8Gi")}}
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.WorkerPools = []repomanagerv1alpha1.WorkerPool{{
+				Name:                 "large",
+				Replicas:             2,
+				ResourceRequirements: &resources,
+				NodeSelector:         map[string]string{"node-role": "rpm-sync"},
+				Env:                  []corev1.EnvVar{{Name: "WORKER_POOL", Value: "large"}},
+				PDB:                  &policy.PodDisruptionBudgetSpec{MinAvailable: &minAvailable},
+			}}
+			objectUpdate(ctx, createdPulp)
+			poolDeployment := &appsv1.Deployment{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: WorkerName + "-large", Namespace: PulpNamespace}, poolDeployment)
+			}, timeout, interval).Should(Succeed())
+			Expect(*poolDeployment.Spec.Replicas).Should(Equal(int32(2)))
+			Expect(poolDeployment.Spec.Selector.MatchLabels["repo-manager.pulpproject.org/worker-pool"]).Should(Equal("large"))
+			Expect(poolDeployment.Spec.Template.Spec.NodeSelector).Should(Equal(map[string]string{"node-role": "rpm-sync"}))
+			Expect(poolDeployment.Spec.Template.Spec.Containers[0].Resources.Limits.Memory().String()).Should(Equal("8Gi"))
+			Expect(poolDeployment.Spec.Template.Spec.Containers[0].Env).Should(ContainElement(corev1.EnvVar{Name: "WORKER_POOL", Value: "large"}))
+
+			objectGet(ctx, workerDeployment, WorkerName)
+			Expect(workerDeployment.Spec.Selector).Should(Equal(workerSelector))
+			selector, _ := metav1.LabelSelectorAsSelector(workerSelector)
+			Expect(selector.Matches(labels.Set(poolDeployment.Spec.Template.Labels))).Should(BeFalse())
+
+			Eventually(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: "worker-large-pdb", Namespace: PulpNamespace}, &policy.PodDisruptionBudget{})
+			}, timeout, interval).Should(Succeed())
+
+			By("Removing the worker pool")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.WorkerPools = nil
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, types.NamespacedName{Name: WorkerName + "-large", Namespace: PulpNamespace}, &appsv1.Deployment{})
+				return errors.IsNotFound(err)
+			}, timeout, interval).Should(BeTrue())
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When profile is defined in pulp CR", func() {
+		It("Should use the profile values in the fields not explicitly defined", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+
+			By("Defining the medium profile and the content replicas")
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Profile = "medium"
+			contentReplicas := int32(3)
+			createdPulp.Spec.Api.Replicas = nil
+			createdPulp.Spec.Content.Replicas = &contentReplicas
+			objectUpdate(ctx, createdPulp)
+			apiDeployment := &appsv1.Deployment{}
+			Eventually(func() int32 {
+				objectGet(ctx, apiDeployment, ApiName)
+				return *apiDeployment.Spec.Replicas
+			}, timeout, interval).Should(Equal(int32(2)))
+			Expect(apiDeployment.Spec.Template.Spec.Affinity.PodAntiAffinity).ShouldNot(BeNil())
+			Expect(apiDeployment.Spec.Template.Spec.Containers[0].Resources.Requests.Memory().String()).Should(Equal("1Gi"))
+			Eventually(func() int32 {
+				contentDeployment := &appsv1.Deployment{}
+				objectGet(ctx, contentDeployment, ContentName)
+				return *contentDeployment.Spec.Replicas
+			}, timeout, interval).Should(Equal(int32(3)))
+			Eventually(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: "api-pdb", Namespace: PulpNamespace}, &policy.PodDisruptionBudget{})
+			}, timeout, interval).Should(Succeed())
+
+			By("Checking that the web replicas explicitly defined with the default value are kept")
+			webDeployment := &appsv1.Deployment{}
+			objectGet(ctx, webDeployment, WebName)
+			Expect(*webDeployment.Spec.Replicas).Should(Equal(int32(1)))
+
+			By("Checking the profile status")
+			Eventually(func() bool {
+				objectGet(ctx, createdPulp, PulpName)
+				return createdPulp.Status.Profile != nil
+			}, timeout, interval).Should(BeTrue())
+			Expect(createdPulp.Status.Profile.Name).Should(Equal("medium"))
+			applied := map[string]map[string]interface{}{}
+			Expect(json.Unmarshal(createdPulp.Status.Profile.Applied.Raw, &applied)).Should(Succeed())
+			Expect(applied["api"]).Should(HaveKeyWithValue("replicas", BeEquivalentTo(2)))
+			Expect(applied["content"]).ShouldNot(HaveKey("replicas"))
+			Expect(applied["web"]).ShouldNot(HaveKey("replicas"))
+
+			By("Checking that the pulp CR spec is not modified by the profile")
+			Expect(createdPulp.Spec.Api.Replicas).Should(BeNil())
+
+			By("Removing the profile")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Profile = ""
+			apiReplicas := int32(1)
+			contentReplicas = int32(1)
+			createdPulp.Spec.Api.Replicas = &apiReplicas
+			createdPulp.Spec.Content.Replicas = &contentReplicas
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() int32 {
+				objectGet(ctx, apiDeployment, ApiName)
+				return *apiDeployment.Spec.Replicas
+			}, timeout, interval).Should(Equal(int32(1)))
+			Eventually(func() bool {
+				objectGet(ctx, createdPulp, PulpName)
+				return createdPulp.Status.Profile == nil
+			}, timeout, interval).Should(BeTrue())
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, types.NamespacedName{Name: "api-pdb", Namespace: PulpNamespace}, &policy.PodDisruptionBudget{})
+				return errors.IsNotFound(err)
+			}, timeout, interval).Should(BeTrue())
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When vpa is defined in pulp CR and the autoscaling.k8s.io API is not available", func() {
+		It("Should skip the VerticalPodAutoscalers without failing the reconciliation", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+
+			By("Defining api.vpa and database.vpa")
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Api.VPA = &repomanagerv1alpha1.VPA{
+				UpdateMode: "Auto",
+				MaxAllowed: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+			}
+			createdPulp.Spec.Database.VPA = &repomanagerv1alpha1.VPA{}
+			objectUpdate(ctx, createdPulp)
+			waitPulpOperatorFinish(ctx, createdPulp)
+			Expect(createdPulp.Status.ResourceRecommendations).Should(BeEmpty())
+
+			By("Removing the vpa definitions")
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Api.VPA = nil
+			createdPulp.Spec.Database.VPA = nil
+			objectUpdate(ctx, createdPulp)
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When creating pulp-web deployment", func() {
+		It("Should define readiness and liveness probes", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			webDeployment := &appsv1.Deployment{}
+			objectGet(ctx, webDeployment, WebName)
+			container := webDeployment.Spec.Template.Spec.Containers[0]
+			Expect(container.ReadinessProbe).ShouldNot(BeNil())
+			Expect(container.ReadinessProbe.HTTPGet.Path).Should(Equal("/pulp/api/v3/status/"))
+			Expect(container.ReadinessProbe.HTTPGet.Port.IntVal).Should(Equal(int32(8080)))
+			Expect(container.LivenessProbe).ShouldNot(BeNil())
+			Expect(container.LivenessProbe.TCPSocket.Port.IntVal).Should(Equal(int32(8080)))
+		})
+	})
+
+	Context("When api.startupProbe is defined in pulp CR", func() {
+		It("Should add the startup probe to pulp-api container", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+
+			By("Defining the startup probe")
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Api.StartupProbe = &corev1.Probe{
+				ProbeHandler: corev1.ProbeHandler{
+					HTTPGet: &corev1.HTTPGetAction{
+						Path: "/pulp/api/v3/status/",
+						Port: intstr.FromInt(24817),
+					},
+				},
+				FailureThreshold: 60,
+				PeriodSeconds:    10,
+			}
+			objectUpdate(ctx, createdPulp)
+			apiDeployment := &appsv1.Deployment{}
+			Eventually(func() *corev1.Probe {
+				objectGet(ctx, apiDeployment, ApiName)
+				return apiDeployment.Spec.Template.Spec.Containers[0].StartupProbe
+			}, timeout, interval).ShouldNot(BeNil())
+			Expect(apiDeployment.Spec.Template.Spec.Containers[0].StartupProbe.FailureThreshold).Should(Equal(int32(60)))
+
+			By("Removing the startup probe")
+			waitPulpOperatorFinish(ctx, createdPulp)
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.Api.StartupProbe = nil
+			objectUpdate(ctx, createdPulp)
+			Eventually(func() *corev1.Probe {
+				objectGet(ctx, apiDeployment, ApiName)
+				return apiDeployment.Spec.Template.Spec.Containers[0].StartupProbe
+			}, timeout, interval).Should(BeNil())
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
+	Context("When multiple pulp_settings are defined in pulp CR", func() {
+		It("Should render settings.py in the same order on every reconciliation", func() {
+			waitPulpOperatorFinish(ctx, createdPulp)
+			originalSettings := createdPulp.Spec.PulpSettings.DeepCopy()
+
+			By("Defining multiple pulp_settings")
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.PulpSettings = runtime.RawExtension{
+				Raw: []byte(`{"Api_Root": "/pulp/", "telemetry": false, "analytics": false, "allowed_export_paths": ["/tmp"], "token_server": "http://example.com/token/"}`),
+			}
+			objectUpdate(ctx, createdPulp)
+			serverSecret := &corev1.Secret{}
+			Eventually(func() string {
+				objectGet(ctx, serverSecret, PulpName+"-server")
+				return string(serverSecret.Data["settings.py"])
+			}, timeout, interval).Should(ContainSubstring("TOKEN_SERVER"))
+			settings := string(serverSecret.Data["settings.py"])
+			Expect(strings.Index(settings, "ALLOWED_EXPORT_PATHS")).Should(BeNumerically("<", strings.Index(settings, "ANALYTICS")))
+			Expect(strings.Index(settings, "ANALYTICS")).Should(BeNumerically("<", strings.Index(settings, "TELEMETRY")))
+			waitPulpOperatorFinish(ctx, createdPulp)
+
+			By("Triggering new reconciliations")
+			objectGet(ctx, serverSecret, PulpName+"-server")
+			resourceVersion := serverSecret.ResourceVersion
+			for i := 0; i < 3; i++ {
+				objectGet(ctx, createdPulp, PulpName)
+				if createdPulp.Annotations == nil {
+					createdPulp.Annotations = map[string]string{}
+				}
+				createdPulp.Annotations["example.com/reconcile"] = strconv.Itoa(i)
+				objectUpdate(ctx, createdPulp)
+				waitPulpOperatorFinish(ctx, createdPulp)
+			}
+			Consistently(func() string {
+				objectGet(ctx, serverSecret, PulpName+"-server")
+				return serverSecret.ResourceVersion
+			}, time.Second*5, interval).Should(Equal(resourceVersion))
+
+			By("Restoring the pulp_settings")
+			objectGet(ctx, createdPulp, PulpName)
+			createdPulp.Spec.PulpSettings = *originalSettings
+			delete(createdPulp.Annotations, "example.com/reconcile")
+			objectUpdate(ctx, createdPulp)
+			waitPulpOperatorFinish(ctx, createdPulp)
+		})
+	})
+
 	Context("When ingress_type is defined as route", func() {
 		It("Should not deploy pulp-web resources and still expose services", func() {
 
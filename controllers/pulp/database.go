@@ -47,6 +47,13 @@ func (r *PulpReconciler) databaseController(ctx context.Context, pulp *repomanag
 	// conditionType is used to update .status.conditions with the current resource state
 	conditionType := cases.Title(language.English, cases.Compact).String(pulp.Spec.DeploymentType) + "-Database-Ready"
 
+	if err := validateDatabaseReplicas(pulp); err != nil {
+		log.Error(err, "Invalid database replicas configuration")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "InvalidDatabaseReplicas", err.Error())
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid database replicas configuration: "+err.Error())
+		return ctrl.Result{}, err
+	}
+
 	// Create pulp-postgres-configuration secret
 	pgConfigSecret := &corev1.Secret{}
 	err := r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-postgres-configuration", Namespace: pulp.Namespace}, pgConfigSecret)
@@ -74,17 +81,41 @@ func (r *PulpReconciler) databaseController(ctx context.Context, pulp *repomanag
 		return ctrl.Result{}, err
 	}
 
+	// postgresql.conf with the parameters from pulp CR
+	if pgConfigResult, err := r.postgresConfigController(ctx, pulp, log); err != nil || pgConfigResult.Requeue || pgConfigResult.RequeueAfter > 0 {
+		return pgConfigResult, err
+	}
+
 	// StatefulSet
 	pgSts := &appsv1.StatefulSet{}
 	err = r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-database", Namespace: pulp.Namespace}, pgSts)
 	expected_sts := statefulSetForDatabase(pulp)
 
+	// the volumes from the volumeClaimTemplates are also reserved
+	podSpec := *expected_sts.Spec.Template.Spec.DeepCopy()
+	for _, pvc := range expected_sts.Spec.VolumeClaimTemplates {
+		podSpec.Volumes = append([]corev1.Volume{{Name: pvc.Name}}, podSpec.Volumes...)
+	}
+	if err := validateVolumes("database", podSpec); err != nil {
+		log.Error(err, "Invalid database volumes configuration")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "InvalidVolumes", err.Error())
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid database volumes configuration: "+err.Error())
+		return ctrl.Result{}, err
+	}
+	if err := validateContainers("database", podSpec); err != nil {
+		log.Error(err, "Invalid database containers configuration")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "InvalidContainers", err.Error())
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid database containers configuration: "+err.Error())
+		return ctrl.Result{}, err
+	}
+
 	if err != nil && errors.IsNotFound(err) {
 		log.Info("Creating a new Database StatefulSet", "StatefulSet.Namespace", pgSts.Namespace, "StatefulSet.Name", pgSts.Name)
 		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "CreatingDatabaseSts", "Creating "+pulp.Name+"-database statefulset resource")
 		controllers.CheckEmptyDir(pulp, controllers.DatabaseResource)
 		// Set Pulp instance as the owner and controller
 		ctrl.SetControllerReference(pulp, expected_sts, r.Scheme)
+		r.warnEnvOverrides(pulp, "database", expected_sts.Spec.Template.Spec)
 		err = r.Create(ctx, expected_sts)
 		if err != nil {
 			log.Error(err, "Failed to create new Database StatefulSet", "StatefulSet.Namespace", expected_sts.Namespace, "StatefulSet.Name", expected_sts.Name)
@@ -101,7 +132,7 @@ func (r *PulpReconciler) databaseController(ctx context.Context, pulp *repomanag
 	}
 
 	// Reconcile StatefulSet
-	if !equality.Semantic.DeepDerivative(expected_sts.Spec, pgSts.Spec) {
+	if !equality.Semantic.DeepDerivative(expected_sts.Spec, pgSts.Spec) || podTemplateModified(expected_sts.Spec.Template, pgSts.Spec.Template) {
 		log.Info("The Database StatefulSet has been modified! Reconciling ...")
 		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "UpdatingDatabaseSts", "Reconciling "+pulp.Name+"-database statefulset resource")
 		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updating", "Reconciling database StatefulSet")
@@ -109,6 +140,7 @@ func (r *PulpReconciler) databaseController(ctx context.Context, pulp *repomanag
 		// not sure if this is the best way to do this, but every time that
 		// a reconciliation occurred the object lost the owner reference
 		ctrl.SetControllerReference(pulp, expected_sts, r.Scheme)
+		r.warnEnvOverrides(pulp, "database", expected_sts.Spec.Template.Spec)
 		err = r.Update(ctx, expected_sts)
 		if err != nil {
 			log.Error(err, "Error trying to update the Database StatefulSet object ... ")
@@ -146,7 +178,7 @@ func (r *PulpReconciler) databaseController(ctx context.Context, pulp *repomanag
 	}
 
 	// Reconcile Service
-	if !equality.Semantic.DeepDerivative(expected_svc.Spec, dbSvc.Spec) {
+	if !equality.Semantic.DeepDerivative(expected_svc.Spec, dbSvc.Spec) || !equality.Semantic.DeepDerivative(expected_svc.Labels, dbSvc.Labels) || len(expected_svc.Spec.Ports) != len(dbSvc.Spec.Ports) {
 		log.Info("The Database service has been modified! Reconciling ...")
 		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "UpdatingDatabaseService", "Reconciling "+pulp.Name+"-database-svc service resource")
 		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updating", "Reconciling database service")
@@ -162,6 +194,21 @@ func (r *PulpReconciler) databaseController(ctx context.Context, pulp *repomanag
 		return ctrl.Result{Requeue: true, RequeueAfter: time.Second}, nil
 	}
 
+	// ServiceMonitor
+	if smResult, err := r.databaseServiceMonitorController(ctx, pulp, log); err != nil || smResult.Requeue || smResult.RequeueAfter > 0 {
+		return smResult, err
+	}
+
+	// read-only service and database pods roles
+	if replicationResult, err := r.databaseReplicationController(ctx, pulp, log); err != nil || replicationResult.Requeue || replicationResult.RequeueAfter > 0 {
+		return replicationResult, err
+	}
+
+	// reload postgres configuration in case a parameter that does not need a restart was modified
+	if reloadResult, err := r.reloadPostgresConfig(ctx, pulp, log); err != nil || reloadResult.Requeue || reloadResult.RequeueAfter > 0 {
+		return reloadResult, err
+	}
+
 	// we should only update the status when Database-Ready==false
 	if v1.IsStatusConditionFalse(pulp.Status.Conditions, conditionType) {
 		r.updateStatus(ctx, pulp, metav1.ConditionTrue, conditionType, "DatabaseTasksFinished", "All Database tasks ran successfully")
@@ -172,15 +219,21 @@ func (r *PulpReconciler) databaseController(ctx context.Context, pulp *repomanag
 
 // statefulSetForDatabase returns a postgresql Deployment object
 func statefulSetForDatabase(m *repomanagerv1alpha1.Pulp) *appsv1.StatefulSet {
+	m = effectivePulp(m)
 
 	ls := labelsForDatabase(m)
-	//replicas := m.Spec.Database.Replicas
-	replicas := int32(1)
+	replicas := databaseReplicas(m)
 
 	affinity := &corev1.Affinity{}
 	if m.Spec.Database.Affinity.NodeAffinity != nil {
 		affinity.NodeAffinity = m.Spec.Database.Affinity.NodeAffinity
 	}
+	if m.Spec.Database.Affinity.PodAffinity != nil {
+		affinity.PodAffinity = m.Spec.Database.Affinity.PodAffinity
+	}
+	if m.Spec.Database.Affinity.PodAntiAffinity != nil {
+		affinity.PodAntiAffinity = m.Spec.Database.Affinity.PodAntiAffinity
+	}
 
 	nodeSelector := map[string]string{}
 	if m.Spec.Database.NodeSelector != nil {
@@ -192,9 +245,17 @@ func statefulSetForDatabase(m *repomanagerv1alpha1.Pulp) *appsv1.StatefulSet {
 		toleration = m.Spec.Database.Tolerations
 	}
 
+	topologySpreadConstraint := []corev1.TopologySpreadConstraint{}
+	if m.Spec.Database.TopologySpreadConstraints != nil {
+		topologySpreadConstraint = m.Spec.Database.TopologySpreadConstraints
+	}
+
 	args := []string{}
+	if postgresConfigNeeded(m) {
+		args = append(args, "-c", "config_file="+postgresConfigMountPath+"/"+postgresConfigFile)
+	}
 	if len(m.Spec.Database.PostgresExtraArgs) > 0 {
-		args = m.Spec.Database.PostgresExtraArgs
+		args = append(args, m.Spec.Database.PostgresExtraArgs...)
 	}
 
 	postgresDataPath := ""
@@ -360,6 +421,28 @@ func statefulSetForDatabase(m *repomanagerv1alpha1.Pulp) *appsv1.StatefulSet {
 		},
 	}
 
+	// the configmap is mounted as a directory (and not with subPath) so that
+	// the modifications are propagated to the pod and can be reloaded
+	podAnnotations := map[string]string{}
+	if postgresConfigNeeded(m) {
+		volumes = append(volumes, corev1.Volume{
+			Name: postgresConfigVolume,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: m.Name + "-postgres-config",
+					},
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      postgresConfigVolume,
+			MountPath: postgresConfigMountPath,
+			ReadOnly:  true,
+		})
+		podAnnotations[postgresRestartAnnotation] = postgresRestartChecksum(m.Spec.Database.PostgresParameters)
+	}
+
 	resources := m.Spec.Database.ResourceRequirements
 
 	livenessProbe := m.Spec.Database.LivenessProbe
@@ -418,7 +501,54 @@ func statefulSetForDatabase(m *repomanagerv1alpha1.Pulp) *appsv1.StatefulSet {
 		containerPort = int32(m.Spec.Database.PostgresPort)
 	}
 
-	return &appsv1.StatefulSet{
+	// the variables from pulp CR are added after the ones defined by the operator (so they take precedence)
+	envVars = append(envVars, m.Spec.Database.Env...)
+
+	containers := []corev1.Container{{
+		Image:   postgresImage,
+		Name:    "postgres",
+		Args:    args,
+		Env:     envVars,
+		EnvFrom: m.Spec.Database.EnvFrom,
+		Ports: []corev1.ContainerPort{{
+			ContainerPort: containerPort,
+			Name:          "postgres",
+		}},
+		LivenessProbe:  livenessProbe,
+		ReadinessProbe: readinessProbe,
+		StartupProbe:   m.Spec.Database.StartupProbe,
+		VolumeMounts:   append(append([]corev1.VolumeMount{}, volumeMounts...), m.Spec.Database.VolumeMounts...),
+		Resources:      resources,
+	}}
+
+	// the replicas are cloned from the primary in their first start
+	initContainers := []corev1.Container{}
+	if replicas > 1 {
+		initContainers = append(initContainers, databaseReplicaInitContainer(m, postgresImage, envVars, volumeMounts))
+	}
+
+	// postgres_exporter sidecar
+	if m.Spec.Database.Metrics.Enabled {
+		containers = append(containers, postgresExporterContainer(m))
+	}
+
+	// the containers deployed by the operator run with the security context from pulp CR
+	// or with the restricted security context
+	securityContext := componentSecurityContext("database", m.Spec.Database.SecurityContext)
+	for i := range initContainers {
+		initContainers[i].SecurityContext = securityContext
+	}
+	for i := range containers {
+		containers[i].SecurityContext = securityContext
+	}
+
+	// the volumes and containers from pulp CR are added after the ones defined by the operator
+	// (except for the init containers, which run before the operator ones)
+	volumes = append(volumes, m.Spec.Database.Volumes...)
+	initContainers = append(append([]corev1.Container{}, m.Spec.Database.InitContainers...), initContainers...)
+	containers = append(containers, m.Spec.Database.SidecarContainers...)
+
+	sts := &appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      m.Name + "-database",
 			Namespace: m.Namespace,
@@ -432,39 +562,37 @@ func statefulSetForDatabase(m *repomanagerv1alpha1.Pulp) *appsv1.StatefulSet {
 			},
 		},
 		Spec: appsv1.StatefulSetSpec{
-			Replicas: &replicas,
+			Replicas:       &replicas,
+			UpdateStrategy: m.Spec.Database.Strategy,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: ls,
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: ls,
+					Labels:      ls,
+					Annotations: podAnnotations,
 				},
 				Spec: corev1.PodSpec{
-					Affinity:           affinity,
-					NodeSelector:       nodeSelector,
-					Tolerations:        toleration,
-					ServiceAccountName: m.Name,
-					Containers: []corev1.Container{{
-						Image: postgresImage,
-						Name:  "postgres",
-						Args:  args,
-						Env:   envVars,
-						Ports: []corev1.ContainerPort{{
-							ContainerPort: containerPort,
-							Name:          "postgres",
-						}},
-						LivenessProbe:  livenessProbe,
-						ReadinessProbe: readinessProbe,
-						VolumeMounts:   volumeMounts,
-						Resources:      resources,
-					}},
-					Volumes: volumes,
+					Affinity:                      affinity,
+					NodeSelector:                  nodeSelector,
+					Tolerations:                   toleration,
+					TopologySpreadConstraints:     topologySpreadConstraint,
+					ServiceAccountName:            m.Name,
+					PriorityClassName:             m.Spec.Database.PriorityClassName,
+					RuntimeClassName:              m.Spec.Database.RuntimeClassName,
+					HostAliases:                   m.Spec.Database.HostAliases,
+					TerminationGracePeriodSeconds: terminationGracePeriod(m.Spec.Database.TerminationGracePeriodSeconds, defaultTerminationGracePeriod),
+					SecurityContext:               componentPodSecurityContext("database", m.Spec.Database.PodSecurityContext),
+					InitContainers:                initContainers,
+					Containers:                    containers,
+					Volumes:                       volumes,
 				},
 			},
 			VolumeClaimTemplates: volumeClaimTemplate,
 		},
 	}
+	setPodMetadata(m, &sts.Spec.Template, m.Spec.Database.PodLabels, m.Spec.Database.PodAnnotations)
+	return sts
 }
 
 // labelsForDatabase returns the labels for selecting the resources
@@ -491,11 +619,30 @@ func serviceForDatabase(m *repomanagerv1alpha1.Pulp) *corev1.Service {
 	targetPort := intstr.IntOrString{IntVal: 5432}
 	serviceType := corev1.ServiceType("ClusterIP")
 
+	ports := []corev1.ServicePort{{
+		Port:       5432,
+		Protocol:   servicePortProto,
+		TargetPort: targetPort,
+	}}
+
+	// services with multiple ports need to have all of them named
+	if m.Spec.Database.Metrics.Enabled {
+		ports[0].Name = "postgres"
+		metricsPort := postgresExporterPort(m)
+		ports = append(ports, corev1.ServicePort{
+			Name:       "metrics",
+			Port:       metricsPort,
+			Protocol:   servicePortProto,
+			TargetPort: intstr.IntOrString{IntVal: metricsPort},
+		})
+	}
+
 	return &corev1.Service{
 
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      m.Name + "-database-svc",
 			Namespace: m.Namespace,
+			Labels:    labelsForDatabase(m),
 		},
 		Spec: corev1.ServiceSpec{
 			ClusterIP:             "None",
@@ -503,14 +650,12 @@ func serviceForDatabase(m *repomanagerv1alpha1.Pulp) *corev1.Service {
 			InternalTrafficPolicy: &serviceInternalTrafficPolicyCluster,
 			IPFamilies:            []corev1.IPFamily{"IPv4"},
 			IPFamilyPolicy:        &ipFamilyPolicyType,
-			Ports: []corev1.ServicePort{{
-				Port:       5432,
-				Protocol:   servicePortProto,
-				TargetPort: targetPort,
-			}},
+			Ports:                 ports,
+			// only the primary database pod should receive the read-write connections
 			Selector: map[string]string{
-				"app":     "postgresql",
-				"pulp_cr": m.Name,
+				"app":                                "postgresql",
+				"pulp_cr":                            m.Name,
+				"statefulset.kubernetes.io/pod-name": m.Name + "-database-0",
 			},
 			SessionAffinity: serviceAffinity,
 			Type:            serviceType,
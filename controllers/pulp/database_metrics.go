@@ -0,0 +1,257 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"context"
+	"os"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const serviceMonitorGroupVersion = "monitoring.coreos.com/v1"
+
+// postgresExporterPort returns the port used by postgres_exporter to expose the metrics
+func postgresExporterPort(m *repomanagerv1alpha1.Pulp) int32 {
+	if m.Spec.Database.Metrics.Port == 0 {
+		return int32(9187)
+	}
+	return int32(m.Spec.Database.Metrics.Port)
+}
+
+// postgresExporterContainer returns the postgres_exporter sidecar container for the database pod
+func postgresExporterContainer(m *repomanagerv1alpha1.Pulp) corev1.Container {
+	image := os.Getenv("RELATED_IMAGE_PULP_POSTGRES_EXPORTER")
+	if len(m.Spec.Database.Metrics.Image) > 0 {
+		image = m.Spec.Database.Metrics.Image
+	} else if image == "" {
+		image = "quay.io/prometheuscommunity/postgres-exporter:v0.11.1"
+	}
+
+	postgresPort := "5432"
+	if m.Spec.Database.PostgresPort != 0 {
+		postgresPort = strconv.Itoa(m.Spec.Database.PostgresPort)
+	}
+
+	port := postgresExporterPort(m)
+
+	envVars := []corev1.EnvVar{
+		{
+			Name: "POSTGRES_DB",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: m.Name + "-postgres-configuration",
+					},
+					Key: "database",
+				},
+			},
+		},
+		{
+			Name: "DATA_SOURCE_USER",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: m.Name + "-postgres-configuration",
+					},
+					Key: "username",
+				},
+			},
+		},
+		{
+			Name: "DATA_SOURCE_PASS",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: m.Name + "-postgres-configuration",
+					},
+					Key: "password",
+				},
+			},
+		},
+		// the exporter runs in the same pod, so there is no need to encrypt the connection
+		{Name: "DATA_SOURCE_URI", Value: "127.0.0.1:" + postgresPort + "/$(POSTGRES_DB)?sslmode=disable"},
+		{Name: "PG_EXPORTER_WEB_LISTEN_ADDRESS", Value: ":" + strconv.Itoa(int(port))},
+	}
+
+	probe := &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path: "/",
+				Port: intstr.FromInt(int(port)),
+			},
+		},
+		InitialDelaySeconds: 5,
+		PeriodSeconds:       10,
+		TimeoutSeconds:      5,
+		FailureThreshold:    6,
+		SuccessThreshold:    1,
+	}
+
+	return corev1.Container{
+		Image: image,
+		Name:  "postgres-exporter",
+		Env:   envVars,
+		Ports: []corev1.ContainerPort{{
+			ContainerPort: port,
+			Name:          "metrics",
+		}},
+		LivenessProbe:  probe,
+		ReadinessProbe: probe,
+		Resources:      m.Spec.Database.Metrics.ResourceRequirements,
+	}
+}
+
+// serviceMonitorForDatabase returns a monitoring.coreos.com/v1 ServiceMonitor for the
+// database service. We are using an unstructured object to avoid depending on the
+// prometheus-operator API packages.
+func serviceMonitorForDatabase(m *repomanagerv1alpha1.Pulp) *unstructured.Unstructured {
+	interval := "30s"
+	if len(m.Spec.Database.Metrics.ScrapeInterval) > 0 {
+		interval = m.Spec.Database.Metrics.ScrapeInterval
+	}
+
+	labels := map[string]interface{}{
+		"app.kubernetes.io/name":       "postgres",
+		"app.kubernetes.io/instance":   "postgres-" + m.Name,
+		"app.kubernetes.io/component":  "database",
+		"app.kubernetes.io/part-of":    m.Spec.DeploymentType,
+		"app.kubernetes.io/managed-by": m.Spec.DeploymentType + "-operator",
+	}
+	for k, v := range m.Spec.Database.Metrics.ServiceMonitorLabels {
+		labels[k] = v
+	}
+
+	sm := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      m.Name + "-database-metrics",
+				"namespace": m.Namespace,
+				"labels":    labels,
+			},
+			"spec": map[string]interface{}{
+				"endpoints": []interface{}{
+					map[string]interface{}{
+						"port":     "metrics",
+						"path":     "/metrics",
+						"interval": interval,
+					},
+				},
+				"namespaceSelector": map[string]interface{}{
+					"matchNames": []interface{}{m.Namespace},
+				},
+				"selector": map[string]interface{}{
+					"matchLabels": map[string]interface{}{
+						"app.kubernetes.io/component": "database",
+						"pulp_cr":                     m.Name,
+					},
+				},
+			},
+		},
+	}
+	sm.SetAPIVersion(serviceMonitorGroupVersion)
+	sm.SetKind("ServiceMonitor")
+	return sm
+}
+
+// databaseServiceMonitorController creates the ServiceMonitor for the database metrics in case
+// the monitoring.coreos.com API is available in the cluster
+func (r *PulpReconciler) databaseServiceMonitorController(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) (ctrl.Result, error) {
+	conditionType := cases.Title(language.English, cases.Compact).String(pulp.Spec.DeploymentType) + "-Database-Ready"
+
+	serviceMonitorAvailable, err := r.isAPIAvailable(serviceMonitorGroupVersion)
+	if err != nil {
+		log.Error(err, "Failed to check if "+serviceMonitorGroupVersion+" API is available")
+		return ctrl.Result{}, err
+	}
+	if !serviceMonitorAvailable {
+		if pulp.Spec.Database.Metrics.Enabled {
+			log.V(1).Info("The " + serviceMonitorGroupVersion + " API is not available, skipping the database ServiceMonitor creation")
+		}
+		return ctrl.Result{}, nil
+	}
+
+	expectedSM := serviceMonitorForDatabase(pulp)
+	sm := &unstructured.Unstructured{}
+	sm.SetGroupVersionKind(expectedSM.GroupVersionKind())
+	err = r.Get(ctx, types.NamespacedName{Name: expectedSM.GetName(), Namespace: pulp.Namespace}, sm)
+
+	// remove the ServiceMonitor if the metrics are not enabled anymore
+	if !pulp.Spec.Database.Metrics.Enabled {
+		if err == nil {
+			log.Info("Removing " + expectedSM.GetName() + " ServiceMonitor")
+			if err = r.Delete(ctx, sm); err != nil {
+				log.Error(err, "Failed to remove "+expectedSM.GetName()+" ServiceMonitor")
+				return ctrl.Result{}, err
+			}
+		} else if !errors.IsNotFound(err) {
+			log.Error(err, "Failed to get "+expectedSM.GetName()+" ServiceMonitor")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	ctrl.SetControllerReference(pulp, expectedSM, r.Scheme)
+	if err != nil && errors.IsNotFound(err) {
+		log.Info("Creating a new Database ServiceMonitor", "ServiceMonitor.Namespace", expectedSM.GetNamespace(), "ServiceMonitor.Name", expectedSM.GetName())
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "CreatingDatabaseServiceMonitor", "Creating "+expectedSM.GetName()+" ServiceMonitor resource")
+		err = r.Create(ctx, expectedSM)
+		if err != nil {
+			log.Error(err, "Failed to create new Database ServiceMonitor", "ServiceMonitor.Namespace", expectedSM.GetNamespace(), "ServiceMonitor.Name", expectedSM.GetName())
+			r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "ErrorCreatingDatabaseServiceMonitor", "Failed to create "+expectedSM.GetName()+" ServiceMonitor resource: "+err.Error())
+			r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to create database ServiceMonitor")
+			return ctrl.Result{}, err
+		}
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Created", "Database ServiceMonitor created")
+		return ctrl.Result{Requeue: true}, nil
+	} else if err != nil {
+		log.Error(err, "Failed to get Database ServiceMonitor")
+		return ctrl.Result{}, err
+	}
+
+	// Reconcile ServiceMonitor
+	if !equality.Semantic.DeepDerivative(expectedSM.Object["spec"], sm.Object["spec"]) ||
+		!equality.Semantic.DeepDerivative(expectedSM.GetLabels(), sm.GetLabels()) {
+		log.Info("The Database ServiceMonitor has been modified! Reconciling ...")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "UpdatingDatabaseServiceMonitor", "Reconciling "+expectedSM.GetName()+" ServiceMonitor resource")
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updating", "Reconciling database ServiceMonitor")
+		expectedSM.SetResourceVersion(sm.GetResourceVersion())
+		err = r.Update(ctx, expectedSM)
+		if err != nil {
+			log.Error(err, "Error trying to update the Database ServiceMonitor object ... ")
+			r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "ErrorUpdatingDatabaseServiceMonitor", "Failed to reconcile "+expectedSM.GetName()+" ServiceMonitor resource: "+err.Error())
+			r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to reconcile database ServiceMonitor")
+			return ctrl.Result{}, err
+		}
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updated", "Database ServiceMonitor reconciled")
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
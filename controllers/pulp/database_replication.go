@@ -0,0 +1,278 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	"github.com/pulp/pulp-operator/controllers"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// databaseRoleLabel is set by the operator in each database pod with its replication role
+	databaseRoleLabel = "repo-manager.pulpproject.org/database-role"
+
+	databasePrimaryRole = "primary"
+	databaseReplicaRole = "replica"
+)
+
+// databaseReplicas returns the number of database pods
+func databaseReplicas(m *repomanagerv1alpha1.Pulp) int32 {
+	if m.Spec.Database.Replicas < 1 {
+		return 1
+	}
+	return m.Spec.Database.Replicas
+}
+
+// validateDatabaseReplicas returns an error if pulp CR defines more than one database
+// replica with a storage type that cannot be used by multiple pods
+func validateDatabaseReplicas(m *repomanagerv1alpha1.Pulp) error {
+	if databaseReplicas(m) > 1 && len(m.Spec.Database.PVC) > 0 {
+		return fmt.Errorf("database.replicas cannot be greater than 1 when database.pvc is defined, each database replica needs its own volume (use database.postgres_storage_class instead)")
+	}
+	return nil
+}
+
+// databaseReplicaInitContainer returns the init container that clones the primary database
+// (through pg_basebackup) in the first start of a replica pod.
+// The pod with ordinal 0 is always the primary, so the container does nothing in it.
+func databaseReplicaInitContainer(m *repomanagerv1alpha1.Pulp, image string, envVars []corev1.EnvVar, volumeMounts []corev1.VolumeMount) corev1.Container {
+	primaryHost := m.Name + "-database-svc"
+	script := `set -e
+ordinal="${HOSTNAME##*-}"
+if [ "$ordinal" = "0" ]; then
+  exit 0
+fi
+
+export PGPASSWORD="$POSTGRES_PASSWORD"
+until pg_isready -h ` + primaryHost + ` -p 5432; do
+  echo "waiting for primary database ..."
+  sleep 2
+done
+
+# the replication slot makes the primary keep the WAL files until the replica receives them
+slot="replica_${ordinal}"
+psql -h ` + primaryHost + ` -U "$POSTGRES_USER" -d "$POSTGRES_DB" -tAc "SELECT pg_create_physical_replication_slot('$slot') WHERE NOT EXISTS (SELECT 1 FROM pg_replication_slots WHERE slot_name = '$slot')"
+
+if [ -s "$PGDATA/PG_VERSION" ]; then
+  exit 0
+fi
+
+rm -rf "$PGDATA.tmp"
+pg_basebackup -h ` + primaryHost + ` -p 5432 -U "$POSTGRES_USER" -D "$PGDATA.tmp" -X stream -R -S "$slot"
+rm -rf "$PGDATA"
+mv "$PGDATA.tmp" "$PGDATA"
+chmod 700 "$PGDATA"
+if [ "$(id -u)" = "0" ]; then
+  chown -R postgres:postgres "$PGDATA"
+fi
+`
+
+	return corev1.Container{
+		Image:        image,
+		Name:         "init-replica",
+		Command:      []string{"/bin/sh", "-c", script},
+		Env:          envVars,
+		VolumeMounts: volumeMounts,
+	}
+}
+
+// serviceForDatabaseReadOnly returns a service object for the postgres replica pods
+func serviceForDatabaseReadOnly(m *repomanagerv1alpha1.Pulp) *corev1.Service {
+	servicePortProto := corev1.Protocol("TCP")
+	targetPort := intstr.IntOrString{IntVal: 5432}
+	serviceType := corev1.ServiceType("ClusterIP")
+
+	ports := []corev1.ServicePort{{
+		Name:       "postgres",
+		Port:       5432,
+		Protocol:   servicePortProto,
+		TargetPort: targetPort,
+	}}
+
+	// the replicas metrics are scraped through this service (database-svc only selects the primary)
+	if m.Spec.Database.Metrics.Enabled {
+		metricsPort := postgresExporterPort(m)
+		ports = append(ports, corev1.ServicePort{
+			Name:       "metrics",
+			Port:       metricsPort,
+			Protocol:   servicePortProto,
+			TargetPort: intstr.IntOrString{IntVal: metricsPort},
+		})
+	}
+
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.Name + "-database-ro-svc",
+			Namespace: m.Namespace,
+			Labels:    labelsForDatabase(m),
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: ports,
+			Selector: map[string]string{
+				"app":             "postgresql",
+				"pulp_cr":         m.Name,
+				databaseRoleLabel: databaseReplicaRole,
+			},
+			Type: serviceType,
+		},
+	}
+}
+
+// databaseReplicationController reconciles the read-only service, the role of each database
+// pod and the replication slots of removed replicas
+func (r *PulpReconciler) databaseReplicationController(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) (ctrl.Result, error) {
+	conditionType := cases.Title(language.English, cases.Compact).String(pulp.Spec.DeploymentType) + "-Database-Ready"
+
+	// READ-ONLY SERVICE
+	roSvc := &corev1.Service{}
+	err := r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-database-ro-svc", Namespace: pulp.Namespace}, roSvc)
+	expectedRoSvc := serviceForDatabaseReadOnly(pulp)
+
+	if databaseReplicas(pulp) < 2 {
+		// remove the read-only service if there is no replica anymore
+		if err == nil {
+			log.Info("Removing " + roSvc.Name + " service")
+			if err = r.Delete(ctx, roSvc); err != nil {
+				log.Error(err, "Failed to remove "+roSvc.Name+" service")
+				return ctrl.Result{}, err
+			}
+		} else if !errors.IsNotFound(err) {
+			log.Error(err, "Failed to get Database read-only Service")
+			return ctrl.Result{}, err
+		}
+	} else if err != nil && errors.IsNotFound(err) {
+		log.Info("Creating a new Database read-only Service", "Service.Namespace", expectedRoSvc.Namespace, "Service.Name", expectedRoSvc.Name)
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "CreatingDatabaseReadOnlyService", "Creating "+expectedRoSvc.Name+" service resource")
+		ctrl.SetControllerReference(pulp, expectedRoSvc, r.Scheme)
+		err = r.Create(ctx, expectedRoSvc)
+		if err != nil {
+			log.Error(err, "Failed to create new Database read-only Service", "Service.Namespace", expectedRoSvc.Namespace, "Service.Name", expectedRoSvc.Name)
+			r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "ErrorCreatingDatabaseReadOnlyService", "Failed to create "+expectedRoSvc.Name+" service resource: "+err.Error())
+			r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to create database read-only service")
+			return ctrl.Result{}, err
+		}
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Created", "Database read-only service created")
+		return ctrl.Result{Requeue: true}, nil
+	} else if err != nil {
+		log.Error(err, "Failed to get Database read-only Service")
+		return ctrl.Result{}, err
+	} else if !equality.Semantic.DeepDerivative(expectedRoSvc.Spec, roSvc.Spec) {
+		log.Info("The Database read-only service has been modified! Reconciling ...")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "UpdatingDatabaseReadOnlyService", "Reconciling "+expectedRoSvc.Name+" service resource")
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updating", "Reconciling database read-only service")
+		ctrl.SetControllerReference(pulp, expectedRoSvc, r.Scheme)
+		err = r.Update(ctx, expectedRoSvc)
+		if err != nil {
+			log.Error(err, "Error trying to update the Database read-only Service object ... ")
+			r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "ErrorUpdatingDatabaseReadOnlyService", "Failed to reconcile "+expectedRoSvc.Name+" service resource: "+err.Error())
+			r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to reconcile database read-only service")
+			return ctrl.Result{}, err
+		}
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updated", "Database read-only service reconciled")
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// DATABASE PODS ROLES
+	podList := &corev1.PodList{}
+	if err := r.List(ctx, podList, client.InNamespace(pulp.Namespace), client.MatchingLabels(labelsForDatabase(pulp))); err != nil {
+		log.Error(err, "Failed to list database pods")
+		return ctrl.Result{}, err
+	}
+
+	replicasStatus := []repomanagerv1alpha1.DatabaseReplicaStatus{}
+	var primary *corev1.Pod
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if !strings.HasPrefix(pod.Name, pulp.Name+"-database-") {
+			continue
+		}
+		role := databaseReplicaRole
+		if pod.Name == pulp.Name+"-database-0" {
+			role = databasePrimaryRole
+			primary = pod
+		}
+
+		// the role label is used by the read-only service to select the replicas
+		if pod.Labels[databaseRoleLabel] != role {
+			patch := client.MergeFrom(pod.DeepCopy())
+			pod.Labels[databaseRoleLabel] = role
+			if err := r.Patch(ctx, pod, patch); err != nil {
+				log.Error(err, "Failed to set database role label", "Pod.Name", pod.Name)
+				return ctrl.Result{}, err
+			}
+		}
+
+		ready := false
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+				ready = true
+			}
+		}
+		replicasStatus = append(replicasStatus, repomanagerv1alpha1.DatabaseReplicaStatus{Pod: pod.Name, Role: role, Ready: ready})
+	}
+	sort.Slice(replicasStatus, func(i, j int) bool {
+		return databasePodOrdinal(replicasStatus[i].Pod) < databasePodOrdinal(replicasStatus[j].Pod)
+	})
+
+	if equality.Semantic.DeepEqual(replicasStatus, pulp.Status.DatabaseReplicas) {
+		return ctrl.Result{}, nil
+	}
+
+	// drop the replication slots from the replicas that have been removed, otherwise
+	// the primary would keep the WAL files forever
+	if len(replicasStatus) < len(pulp.Status.DatabaseReplicas) && primary != nil && primary.Status.Phase == corev1.PodRunning {
+		execCmd := []string{
+			"/bin/sh", "-c",
+			`psql -U "$POSTGRES_USER" -d "$POSTGRES_DB" -tAc "SELECT pg_drop_replication_slot(slot_name) FROM pg_replication_slots WHERE NOT active AND slot_name ~ '^replica_[0-9]+$' AND substring(slot_name from 9)::int >= ` + strconv.Itoa(int(databaseReplicas(pulp))) + `"`,
+		}
+		if _, err := controllers.ContainerExec(r, primary, execCmd, "postgres", primary.Namespace); err != nil {
+			log.Error(err, "Failed to remove replication slots from removed database replicas")
+		}
+	}
+
+	log.V(1).Info("Updating database replicas status", "replicas", replicasStatus)
+	pulp.Status.DatabaseReplicas = replicasStatus
+	if err := r.Status().Update(ctx, pulp); err != nil {
+		log.Error(err, "Failed to update database replicas status")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// databasePodOrdinal returns the statefulset ordinal from the pod name
+func databasePodOrdinal(podName string) int {
+	ordinal, _ := strconv.Atoi(podName[strings.LastIndex(podName, "-")+1:])
+	return ordinal
+}
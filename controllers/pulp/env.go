@@ -0,0 +1,49 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"strings"
+
+	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// duplicatedEnvVars returns the names of the environment variables defined more than once in the container.
+// Since the variables from pulp CR are added after the ones defined by the operator, these are the
+// operator variables overridden by the env field of the component.
+func duplicatedEnvVars(container corev1.Container) []string {
+	found := map[string]bool{}
+	duplicated := []string{}
+	for _, env := range container.Env {
+		if found[env.Name] {
+			duplicated = append(duplicated, env.Name)
+		}
+		found[env.Name] = true
+	}
+	return duplicated
+}
+
+// warnEnvOverrides emits a warning event if a variable from the env field of a component
+// overrides a variable defined by the operator
+func (r *PulpReconciler) warnEnvOverrides(pulp *repomanagerv1alpha1.Pulp, component string, podSpec corev1.PodSpec) {
+	for _, container := range podSpec.Containers {
+		if duplicated := duplicatedEnvVars(container); len(duplicated) > 0 {
+			r.recorder.Event(pulp, corev1.EventTypeWarning, "EnvOverride", component+".env overrides the variables defined by the operator in "+container.Name+" container: "+strings.Join(duplicated, ", "))
+		}
+	}
+}
@@ -0,0 +1,264 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// pulpcoreImageAnnotation is set in pulp-api deployment with the pulpcore image (from image and
+// image_version) it was deployed with, which is not the image of its containers if api.image is defined
+const pulpcoreImageAnnotation = "repo-manager.pulpproject.org/pulpcore-image"
+
+// componentImagesAnnotation is set in pulp-api deployment with the images defined for the
+// components ({api,content,worker}.image) the pulpcore deployments were deployed with
+const componentImagesAnnotation = "repo-manager.pulpproject.org/component-images"
+
+// digestReference returns true if the image defined by name and version is pinned by digest
+func digestReference(name, version string) bool {
+	return strings.Contains(name, "@") || strings.HasPrefix(version, "sha256:")
+}
+
+// imageReference returns the reference of an image from its name and version.
+// The version can be a tag or a digest (sha256:...) and is ignored if the name
+// already has a digest.
+func imageReference(name, version string) string {
+	if strings.Contains(name, "@") {
+		return name
+	}
+	if strings.HasPrefix(version, "sha256:") {
+		return name + "@" + version
+	}
+	return name + ":" + version
+}
+
+// pulpcoreImage returns the pulpcore image defined in pulp CR
+func pulpcoreImage(m *repomanagerv1alpha1.Pulp) string {
+	image := os.Getenv("RELATED_IMAGE_PULP")
+	if len(m.Spec.Image) > 0 && (len(m.Spec.ImageVersion) > 0 || strings.Contains(m.Spec.Image, "@")) {
+		image = imageReference(m.Spec.Image, m.Spec.ImageVersion)
+	} else if image == "" {
+		image = "quay.io/pulp/pulp:stable"
+	}
+	return image
+}
+
+// componentImages returns the images defined for the pulpcore components (api, content or worker)
+// in pulp CR, or nil if none is defined
+func componentImages(m *repomanagerv1alpha1.Pulp) map[string]string {
+	var images map[string]string
+	for component, image := range map[string]string{
+		"api":     m.Spec.Api.Image,
+		"content": m.Spec.Content.Image,
+		"worker":  m.Spec.Worker.Image,
+	} {
+		if len(image) == 0 {
+			continue
+		}
+		if images == nil {
+			images = map[string]string{}
+		}
+		images[component] = image
+	}
+	return images
+}
+
+// pulpcoreComponentImage returns the image of a pulpcore component (api, content or worker):
+// the image defined for the component or the given pulpcore image
+func pulpcoreComponentImage(component, image string, componentImages map[string]string) string {
+	if override, found := componentImages[component]; found {
+		return override
+	}
+	return image
+}
+
+// pulpcoreImages returns the images (without duplicates) used by the pulpcore components
+func pulpcoreImages(image string, componentImages map[string]string) []string {
+	images := []string{image}
+	found := map[string]bool{image: true}
+	for _, component := range []string{"api", "content", "worker"} {
+		componentImage := pulpcoreComponentImage(component, image, componentImages)
+		if !found[componentImage] {
+			images = append(images, componentImage)
+			found[componentImage] = true
+		}
+	}
+	return images
+}
+
+// pulpcoreImagesDescription returns the pulpcore image and the images defined for the components,
+// used in the status and events of the upgrades
+func pulpcoreImagesDescription(image string, componentImages map[string]string) string {
+	for _, component := range []string{"api", "content", "worker"} {
+		if componentImage, found := componentImages[component]; found {
+			image = image + ", " + component + ": " + componentImage
+		}
+	}
+	return image
+}
+
+// encodeComponentImages returns the images defined for the components in the format stored in componentImagesAnnotation
+func encodeComponentImages(componentImages map[string]string) string {
+	if len(componentImages) == 0 {
+		return ""
+	}
+	encoded, _ := json.Marshal(componentImages)
+	return string(encoded)
+}
+
+// decodeComponentImages returns the images defined for the components from componentImagesAnnotation
+func decodeComponentImages(encoded string) map[string]string {
+	var images map[string]string
+	if err := json.Unmarshal([]byte(encoded), &images); err != nil || len(images) == 0 {
+		return nil
+	}
+	return images
+}
+
+// webImage returns the pulp-web image defined in pulp CR
+func webImage(m *repomanagerv1alpha1.Pulp) string {
+	image := os.Getenv("RELATED_IMAGE_PULP_WEB")
+	if len(m.Spec.ImageWeb) > 0 && (len(m.Spec.ImageWebVersion) > 0 || strings.Contains(m.Spec.ImageWeb, "@")) {
+		image = imageReference(m.Spec.ImageWeb, m.Spec.ImageWebVersion)
+	} else if image == "" {
+		image = "quay.io/pulp/pulp-web:stable"
+	}
+	return image
+}
+
+// resolvedImage returns the image (with digest) of a container status, without
+// the prefix added by some container runtimes
+func resolvedImage(status corev1.ContainerStatus) string {
+	imageID := status.ImageID
+	if i := strings.Index(imageID, "://"); i >= 0 {
+		imageID = imageID[i+3:]
+	}
+	return imageID
+}
+
+// componentImageStatus returns the image of the container from a workload pod template and
+// the images (with digest) running in the workload pods
+func (r *PulpReconciler) componentImageStatus(ctx context.Context, namespace, component, container string, selector *metav1.LabelSelector, template corev1.PodTemplateSpec) (*repomanagerv1alpha1.ImageStatus, error) {
+	status := &repomanagerv1alpha1.ImageStatus{Component: component}
+	for _, c := range template.Spec.Containers {
+		if c.Name == container {
+			status.Image = c.Image
+		}
+	}
+	if selector == nil {
+		return status, nil
+	}
+
+	podList := &corev1.PodList{}
+	if err := r.List(ctx, podList, client.InNamespace(namespace), client.MatchingLabels(selector.MatchLabels)); err != nil {
+		return nil, err
+	}
+	digests := map[string]bool{}
+	for _, pod := range podList.Items {
+		for _, c := range pod.Status.ContainerStatuses {
+			if c.Name == container && len(c.ImageID) > 0 {
+				digests[resolvedImage(c)] = true
+			}
+		}
+	}
+	for digest := range digests {
+		status.Digests = append(status.Digests, digest)
+	}
+	sort.Strings(status.Digests)
+	return status, nil
+}
+
+// componentWorkload is the deployment (or statefulset) of a component and the name of its main container
+type componentWorkload struct {
+	component, container string
+	obj                  client.Object
+}
+
+// imagesStatusController records in pulp CR status the images of the components and the
+// images (with digest) running in their pods
+func (r *PulpReconciler) imagesStatusController(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) (ctrl.Result, error) {
+	components := []componentWorkload{
+		{"api", "api", &appsv1.Deployment{}},
+		{"content", "content", &appsv1.Deployment{}},
+		{"worker", "worker", &appsv1.Deployment{}},
+		{"web", "web", &appsv1.Deployment{}},
+		{"database", "postgres", &appsv1.StatefulSet{}},
+		{"cache", "redis", &appsv1.Deployment{}},
+	}
+	names := map[string]string{"database": pulp.Name + "-database", "cache": pulp.Name + "-redis"}
+	if redisSentinelMode(pulp) {
+		components[5].obj = &appsv1.StatefulSet{}
+	}
+	for _, pool := range pulp.Spec.WorkerPools {
+		components = append(components, componentWorkload{"worker-" + pool.Name, "worker", &appsv1.Deployment{}})
+	}
+
+	var images []repomanagerv1alpha1.ImageStatus
+	for _, c := range components {
+		name, found := names[c.component]
+		if !found {
+			name = pulp.Name + "-" + c.component
+		}
+		if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: pulp.Namespace}, c.obj); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			log.Error(err, "Failed to get "+name)
+			return ctrl.Result{}, err
+		}
+
+		var status *repomanagerv1alpha1.ImageStatus
+		var err error
+		switch obj := c.obj.(type) {
+		case *appsv1.Deployment:
+			status, err = r.componentImageStatus(ctx, pulp.Namespace, c.component, c.container, obj.Spec.Selector, obj.Spec.Template)
+		case *appsv1.StatefulSet:
+			status, err = r.componentImageStatus(ctx, pulp.Namespace, c.component, c.container, obj.Spec.Selector, obj.Spec.Template)
+		}
+		if err != nil {
+			log.Error(err, "Failed to list "+name+" pods")
+			return ctrl.Result{}, err
+		}
+		images = append(images, *status)
+	}
+
+	// only update the status when it changes to avoid triggering new reconciliations
+	if equality.Semantic.DeepEqual(images, pulp.Status.Images) {
+		return ctrl.Result{}, nil
+	}
+	pulp.Status.Images = images
+	if err := r.Status().Update(ctx, pulp); err != nil {
+		log.Error(err, "Failed to update images status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	routev1 "github.com/openshift/api/route/v1"
+	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	"github.com/pulp/pulp-operator/controllers"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// commonLabelsAnnotation is set in the objects with the keys of the common_labels added by the
+	// operator, so that they can be removed when they are not defined in pulp CR anymore
+	commonLabelsAnnotation = "repo-manager.pulpproject.org/common-labels"
+
+	// podMetadataChecksumAnnotation is set in the pod templates with the checksum of the labels and
+	// annotations from pulp CR, so that removing one of them also updates the pods (see podTemplateModified)
+	podMetadataChecksumAnnotation = "repo-manager.pulpproject.org/pod-metadata-checksum"
+)
+
+// setPodMetadata adds the common_labels, pod_labels and pod_annotations from pulp CR to a pod template.
+// The labels and annotations defined by the operator take precedence, so the selectors are not modified.
+func setPodMetadata(m *repomanagerv1alpha1.Pulp, template *corev1.PodTemplateSpec, podLabels, podAnnotations map[string]string) {
+	if len(m.Spec.CommonLabels) == 0 && len(podLabels) == 0 && len(podAnnotations) == 0 {
+		return
+	}
+
+	// new maps are created because the operator labels are also used in the selectors
+	labels := map[string]string{}
+	for _, source := range []map[string]string{m.Spec.CommonLabels, podLabels, template.Labels} {
+		for k, v := range source {
+			labels[k] = v
+		}
+	}
+	annotations := map[string]string{}
+	for _, source := range []map[string]string{podAnnotations, template.Annotations} {
+		for k, v := range source {
+			annotations[k] = v
+		}
+	}
+
+	// json.Marshal sorts the map keys, so the checksum only changes when the maps are modified
+	metadata, _ := json.Marshal([]map[string]string{m.Spec.CommonLabels, podLabels, podAnnotations})
+	annotations[podMetadataChecksumAnnotation] = checksum(string(metadata))
+
+	template.Labels = labels
+	template.Annotations = annotations
+}
+
+// setCommonLabels adds the common labels to obj and removes the ones previously added by the operator
+// that are not defined anymore. The labels defined by the operator (or by other tools) are not modified.
+// It returns true if obj was modified.
+func setCommonLabels(obj client.Object, commonLabels map[string]string) bool {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	managed := map[string]bool{}
+	for _, key := range strings.Split(annotations[commonLabelsAnnotation], ",") {
+		if len(key) > 0 {
+			managed[key] = true
+		}
+	}
+
+	modified := false
+	for key := range managed {
+		if _, found := commonLabels[key]; !found {
+			delete(labels, key)
+			delete(managed, key)
+			modified = true
+		}
+	}
+	for key, value := range commonLabels {
+		if current, found := labels[key]; found && !managed[key] {
+			continue
+		} else if found && current == value {
+			continue
+		}
+		labels[key] = value
+		managed[key] = true
+		modified = true
+	}
+	if !modified {
+		return false
+	}
+
+	keys := []string{}
+	for key := range managed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		annotations[commonLabelsAnnotation] = strings.Join(keys, ",")
+	} else {
+		delete(annotations, commonLabelsAnnotation)
+	}
+	obj.SetLabels(labels)
+	obj.SetAnnotations(annotations)
+	return true
+}
+
+// operatorObjects returns the objects created by the operator for pulp CR: the objects controlled
+// by pulp CR and its ServiceAccount, Role and RoleBinding
+func (r *PulpReconciler) operatorObjects(ctx context.Context, pulp *repomanagerv1alpha1.Pulp) ([]client.Object, error) {
+	lists := []client.ObjectList{
+		&appsv1.DeploymentList{},
+		&appsv1.StatefulSetList{},
+		&corev1.ServiceList{},
+		&corev1.SecretList{},
+		&corev1.ConfigMapList{},
+		&corev1.PersistentVolumeClaimList{},
+		&policy.PodDisruptionBudgetList{},
+		&autoscalingv2.HorizontalPodAutoscalerList{},
+		&batchv1.JobList{},
+	}
+	if IsOpenShift, _ := controllers.IsOpenShift(); IsOpenShift {
+		lists = append(lists, &routev1.RouteList{})
+	}
+	if pulp.Spec.Database.Metrics.Enabled {
+		if available, _ := r.isAPIAvailable(serviceMonitorGroupVersion); available {
+			smList := &unstructured.UnstructuredList{}
+			smList.SetAPIVersion(serviceMonitorGroupVersion)
+			smList.SetKind("ServiceMonitorList")
+			lists = append(lists, smList)
+		}
+	}
+
+	if vpaDefined(pulp) {
+		if available, _ := r.isAPIAvailable(vpaGroupVersion); available {
+			vpaList := &unstructured.UnstructuredList{}
+			vpaList.SetAPIVersion(vpaGroupVersion)
+			vpaList.SetKind("VerticalPodAutoscalerList")
+			lists = append(lists, vpaList)
+		}
+	}
+
+	objects := []client.Object{}
+	for _, list := range lists {
+		if err := r.List(ctx, list, client.InNamespace(pulp.Namespace)); err != nil {
+			return nil, err
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			obj, ok := item.(client.Object)
+			if !ok {
+				continue
+			}
+			if owner := metav1.GetControllerOf(obj); owner != nil && owner.UID == pulp.UID {
+				objects = append(objects, obj)
+			}
+		}
+	}
+
+	// the ServiceAccount, Role and RoleBinding are not controlled by pulp CR
+	for _, obj := range []client.Object{&corev1.ServiceAccount{}, &rbacv1.Role{}, &rbacv1.RoleBinding{}} {
+		if err := r.Get(ctx, types.NamespacedName{Name: pulp.Name, Namespace: pulp.Namespace}, obj); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// commonLabelsController adds the common_labels from pulp CR to the objects created by the operator
+// and removes the ones that are not defined in pulp CR anymore
+func (r *PulpReconciler) commonLabelsController(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) (ctrl.Result, error) {
+	objects, err := r.operatorObjects(ctx, pulp)
+	if err != nil {
+		log.Error(err, "Failed to list the objects created by the operator")
+		return ctrl.Result{}, err
+	}
+
+	for _, obj := range objects {
+		patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+		if !setCommonLabels(obj, pulp.Spec.CommonLabels) {
+			continue
+		}
+		log.V(1).Info("Updating common labels", "Name", obj.GetName())
+		if err := r.Patch(ctx, obj, patch); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to update the common labels", "Name", obj.GetName())
+			r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to update the common labels of "+obj.GetName())
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
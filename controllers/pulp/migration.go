@@ -0,0 +1,226 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// migrationImageAnnotation is set in the migration Job with the pulpcore image it migrated to
+	migrationImageAnnotation = "repo-manager.pulpproject.org/migration-image"
+
+	// migrationLogsTailLines is the number of lines from the migration pod logs
+	// added to the condition message when the migration fails
+	migrationLogsTailLines = int64(20)
+)
+
+// labelsForMigration returns the labels of the migration Jobs
+func labelsForMigration(m *repomanagerv1alpha1.Pulp) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":       m.Spec.DeploymentType + "-migration",
+		"app.kubernetes.io/instance":   m.Spec.DeploymentType + "-migration-" + m.Name,
+		"app.kubernetes.io/component":  "migration",
+		"app.kubernetes.io/part-of":    m.Spec.DeploymentType,
+		"app.kubernetes.io/managed-by": m.Spec.DeploymentType + "-operator",
+		"pulp_cr":                      m.Name,
+	}
+}
+
+// migrationJob returns the Job that runs the database migrations for a pulpcore image.
+// The Job is named after the image so that a new Job runs every time the image
+// (or its version) is modified.
+func (r *PulpReconciler) migrationJob(m *repomanagerv1alpha1.Pulp, image string) *batchv1.Job {
+
+	// the migration uses the same settings, volumes and secrets from pulp-api pods
+	podSpec := r.deploymentForPulpApi(m, "").Spec.Template.Spec
+	container := podSpec.Containers[0]
+	container.Name = "migration"
+	container.Image = image
+	container.Command = []string{"/bin/sh", "-c"}
+	container.Args = []string{"/usr/bin/wait_on_postgres.py && pulpcore-manager migrate --noinput"}
+	container.Ports = nil
+	container.LivenessProbe = nil
+	container.ReadinessProbe = nil
+	container.StartupProbe = nil
+	podSpec.Containers = []corev1.Container{container}
+	podSpec.RestartPolicy = corev1.RestartPolicyNever
+
+	backoffLimit := int32(2)
+	labels := labelsForMigration(m)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.Name + "-migration-" + checksum(container.Image)[:8],
+			Namespace: m.Namespace,
+			Labels:    labels,
+			Annotations: map[string]string{
+				migrationImageAnnotation: container.Image,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: podSpec,
+			},
+		},
+	}
+
+	ctrl.SetControllerReference(m, job, r.Scheme)
+	return job
+}
+
+// isJobFinished returns if the job finished and the type of its last condition (Complete or Failed)
+func isJobFinished(job *batchv1.Job) (bool, batchv1.JobConditionType) {
+	for _, c := range job.Status.Conditions {
+		if (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) && c.Status == corev1.ConditionTrue {
+			return true, c.Type
+		}
+	}
+	return false, ""
+}
+
+// migrationLogs returns the last lines of the logs from the migration pods
+func (r *PulpReconciler) migrationLogs(ctx context.Context, job *batchv1.Job) string {
+	if r.RESTClient == nil {
+		return ""
+	}
+
+	podList := &corev1.PodList{}
+	if err := r.List(ctx, podList, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name}); err != nil || len(podList.Items) == 0 {
+		return ""
+	}
+
+	// the logs from the most recent attempt are the most relevant
+	pod := podList.Items[0]
+	for _, p := range podList.Items {
+		if p.CreationTimestamp.After(pod.CreationTimestamp.Time) {
+			pod = p
+		}
+	}
+
+	tailLines := migrationLogsTailLines
+	logs, err := r.RESTClient.Get().
+		Namespace(pod.Namespace).
+		Resource("pods").
+		Name(pod.Name).
+		SubResource("log").
+		VersionedParams(&corev1.PodLogOptions{Container: "migration", TailLines: &tailLines}, runtime.NewParameterCodec(r.Scheme)).
+		Do(ctx).
+		Raw()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(logs))
+}
+
+// migrationController runs the database migrations in a Job when the pulpcore image (or the image
+// of a component) is modified and holds the rollout of pulpcore deployments until the migrations finish.
+// The migrations run with each one of the images used by the components (one after the other), so that
+// the database schema has the migrations from all of them: the migrations already applied by an image
+// are skipped by the others.
+func (r *PulpReconciler) migrationController(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) (ctrl.Result, error) {
+
+	// conditionType is used to update .status.conditions with the current resource state
+	conditionType := cases.Title(language.English, cases.Compact).String(pulp.Spec.DeploymentType) + "-Migration-Complete"
+
+	images := pulpcoreImages(pulpcoreImage(pulp), componentImages(pulp))
+	jobs := map[string]bool{}
+	for _, image := range images {
+		expectedJob := r.migrationJob(pulp, image)
+		jobs[expectedJob.Name] = true
+		job := &batchv1.Job{}
+		err := r.Get(ctx, types.NamespacedName{Name: expectedJob.Name, Namespace: pulp.Namespace}, job)
+
+		if err != nil && errors.IsNotFound(err) {
+			log.Info("Creating a new migration Job", "Job.Namespace", expectedJob.Namespace, "Job.Name", expectedJob.Name)
+			r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "RunningMigration", "Running database migrations for "+image)
+			if err = r.Create(ctx, expectedJob); err != nil {
+				log.Error(err, "Failed to create new migration Job", "Job.Namespace", expectedJob.Namespace, "Job.Name", expectedJob.Name)
+				r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "ErrorCreatingMigrationJob", "Failed to create "+expectedJob.Name+" job: "+err.Error())
+				r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to create new migration Job")
+				return ctrl.Result{}, err
+			}
+			r.recorder.Event(pulp, corev1.EventTypeNormal, "Created", "Migration Job created")
+			return ctrl.Result{Requeue: true}, nil
+		} else if err != nil {
+			log.Error(err, "Failed to get migration Job")
+			return ctrl.Result{}, err
+		}
+
+		finished, result := isJobFinished(job)
+		if !finished {
+			log.Info("Waiting for the database migrations to finish", "Job.Name", job.Name)
+			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		}
+
+		if result == batchv1.JobFailed {
+			message := "Database migrations for " + image + " failed (delete the " + job.Name + " job to retry)"
+			if logs := r.migrationLogs(ctx, job); len(logs) > 0 {
+				message = message + ". Logs: " + logs
+			}
+			if condition := v1.FindStatusCondition(pulp.Status.Conditions, conditionType); condition == nil || condition.Reason != "MigrationFailed" || condition.Message != message {
+				r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "MigrationFailed", message)
+				r.recorder.Event(pulp, corev1.EventTypeWarning, "MigrationFailed", "Database migrations failed")
+			}
+			return ctrl.Result{}, fmt.Errorf("migration job %v failed", job.Name)
+		}
+	}
+
+	// remove the jobs from previous migrations
+	jobList := &batchv1.JobList{}
+	if err := r.List(ctx, jobList, client.InNamespace(pulp.Namespace), client.MatchingLabels(labelsForMigration(pulp))); err != nil {
+		log.Error(err, "Failed to list migration Jobs")
+		return ctrl.Result{}, err
+	}
+	for i := range jobList.Items {
+		if jobs[jobList.Items[i].Name] {
+			continue
+		}
+		log.Info("Removing old migration Job", "Job.Name", jobList.Items[i].Name)
+		if err := r.Delete(ctx, &jobList.Items[i], client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to remove old migration Job", "Job.Name", jobList.Items[i].Name)
+			return ctrl.Result{}, err
+		}
+	}
+
+	// we should only update the status when Migration-Complete==false
+	if !v1.IsStatusConditionTrue(pulp.Status.Conditions, conditionType) {
+		r.updateStatus(ctx, pulp, metav1.ConditionTrue, conditionType, "MigrationFinished", "Database migrations for "+strings.Join(images, ", ")+" finished")
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "MigrationFinished", "Database migrations finished")
+	}
+	return ctrl.Result{}, nil
+}
@@ -22,6 +22,7 @@ import (
 
 	"github.com/go-logr/logr"
 	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	k8s_error "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -31,72 +32,105 @@ import (
 	policy "k8s.io/api/policy/v1"
 )
 
-// pdbController creates and reconciles {api,content,worker,web} pdbs
+// pdbController creates, reconciles and removes {api,content,worker,web} pdbs
 func (r *PulpReconciler) pdbController(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) (ctrl.Result, error) {
 
+	// the PDBs can also be defined by the profile
+	effective := effectivePulp(pulp)
 	pdbList := map[string]*policy.PodDisruptionBudgetSpec{
-		"api":       pulp.Spec.Api.PDB,
-		"content":   pulp.Spec.Content.PDB,
-		"worker":    pulp.Spec.Worker.PDB,
-		"webserver": pulp.Spec.Web.PDB,
+		"api":       effective.Spec.Api.PDB,
+		"content":   effective.Spec.Content.PDB,
+		"worker":    effective.Spec.Worker.PDB,
+		"webserver": effective.Spec.Web.PDB,
+	}
+
+	// the pods from the worker pools are also "worker" components, so the worker PDB selects only
+	// the pulp-worker pods and each pool has its own PDB (a pod cannot be selected by more than one PDB)
+	selectors := map[string]map[string]string{
+		"worker": labelsForPulpWorker(pulp),
+	}
+	for _, pool := range pulp.Spec.WorkerPools {
+		component := "worker-" + pool.Name
+		pdbList[component] = pool.PDB
+		selectors[component] = labelsForPulpWorkerPool(pulp, pool.Name)
 	}
 
 	for component, pdb := range pdbList {
 
-		// check if PDB is defined
-		if pdb != nil {
+		// remove the PDB created by the operator if it is not defined anymore
+		// (for example, when it was defined by a profile that is not used anymore)
+		if pdb == nil {
 			pdbFound := &policy.PodDisruptionBudget{}
 			err := r.Get(ctx, types.NamespacedName{Name: component + "-pdb", Namespace: pulp.Namespace}, pdbFound)
-
-			// add label selector to PDBSpec
-			// even though it is possible to pass a selector through PodDisruptionBudgetSpec we will overwrite
-			// any config passed through pulp CR with the following
-			pdb.Selector = &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app.kubernetes.io/component": component,
-				},
-			}
-			expectedPDB := &policy.PodDisruptionBudget{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      component + "-pdb",
-					Namespace: pulp.Namespace,
-				},
-				Spec: *pdb,
-			}
-			ctrl.SetControllerReference(pulp, expectedPDB, r.Scheme)
-
-			// Create PDB if not found
-			if err != nil && k8s_error.IsNotFound(err) {
-				log.Info("Creating a new " + component + " PDB ...")
-				err = r.Create(ctx, expectedPDB)
-				if err != nil {
-					log.Error(err, "Failed to create new "+component+" PDB")
-					return ctrl.Result{}, err
-				}
-				// PDB created successfully - return and requeue
-				return ctrl.Result{Requeue: true}, nil
-			} else if err != nil {
+			if err != nil && !k8s_error.IsNotFound(err) {
 				log.Error(err, "Failed to get "+component+" PDB")
 				return ctrl.Result{}, err
 			}
-
-			// Reconcile PDB
-			if !equality.Semantic.DeepDerivative(expectedPDB.Spec, pdbFound.Spec) {
-				log.Info("The " + component + "PDB has been modified! Reconciling ...")
-
-				// I'm not sure why the error:
-				// "metadata.resourceVersion: Invalid value: 0x0: must be specified for an update"
-				// is happening when trying to update expectedPDB (this is not happening with the other resources)
-				// this will set the pdb resourceversion with the current version
-				expectedPDB.SetResourceVersion(pdbFound.GetResourceVersion())
-				err = r.Update(ctx, expectedPDB)
-				if err != nil {
-					log.Error(err, "Error trying to update the "+component+" PDB object ... ")
+			if err == nil && metav1.IsControlledBy(pdbFound, pulp) {
+				log.Info("Removing " + component + " PDB ...")
+				if err = r.Delete(ctx, pdbFound); err != nil {
+					log.Error(err, "Failed to remove "+component+" PDB")
 					return ctrl.Result{}, err
 				}
-				return ctrl.Result{Requeue: true, RequeueAfter: time.Second}, nil
+				r.recorder.Event(pulp, corev1.EventTypeNormal, "Deleted", component+" PDB removed")
 			}
+			continue
+		}
+
+		pdbFound := &policy.PodDisruptionBudget{}
+		err := r.Get(ctx, types.NamespacedName{Name: component + "-pdb", Namespace: pulp.Namespace}, pdbFound)
 
+		// add label selector to PDBSpec
+		// even though it is possible to pass a selector through PodDisruptionBudgetSpec we will overwrite
+		// any config passed through pulp CR with the following
+		selector, found := selectors[component]
+		if !found {
+			selector = map[string]string{
+				"app.kubernetes.io/component": component,
+			}
+		}
+		pdb.Selector = &metav1.LabelSelector{
+			MatchLabels: selector,
+		}
+		expectedPDB := &policy.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      component + "-pdb",
+				Namespace: pulp.Namespace,
+			},
+			Spec: *pdb,
+		}
+		ctrl.SetControllerReference(pulp, expectedPDB, r.Scheme)
+
+		// Create PDB if not found
+		if err != nil && k8s_error.IsNotFound(err) {
+			log.Info("Creating a new " + component + " PDB ...")
+			err = r.Create(ctx, expectedPDB)
+			if err != nil {
+				log.Error(err, "Failed to create new "+component+" PDB")
+				return ctrl.Result{}, err
+			}
+			// PDB created successfully - return and requeue
+			return ctrl.Result{Requeue: true}, nil
+		} else if err != nil {
+			log.Error(err, "Failed to get "+component+" PDB")
+			return ctrl.Result{}, err
+		}
+
+		// Reconcile PDB
+		if !equality.Semantic.DeepDerivative(expectedPDB.Spec, pdbFound.Spec) {
+			log.Info("The " + component + "PDB has been modified! Reconciling ...")
+
+			// I'm not sure why the error:
+			// "metadata.resourceVersion: Invalid value: 0x0: must be specified for an update"
+			// is happening when trying to update expectedPDB (this is not happening with the other resources)
+			// this will set the pdb resourceversion with the current version
+			expectedPDB.SetResourceVersion(pdbFound.GetResourceVersion())
+			err = r.Update(ctx, expectedPDB)
+			if err != nil {
+				log.Error(err, "Error trying to update the "+component+" PDB object ... ")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{Requeue: true, RequeueAfter: time.Second}, nil
 		}
 	}
 
@@ -0,0 +1,435 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	"github.com/pulp/pulp-operator/controllers"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	postgresConfigVolume    = "postgres-config"
+	postgresConfigMountPath = "/etc/postgresql/conf.d"
+	postgresConfigFile      = "postgresql.conf"
+	postgresHbaFile         = "pg_hba.conf"
+
+	// postgresRestartAnnotation is set in the database pod template with the checksum of the
+	// parameters that can only be changed with a server restart, modifying them will
+	// trigger a rollout of the statefulset
+	postgresRestartAnnotation = "repo-manager.pulpproject.org/postgres-restart-checksum"
+
+	// postgresReloadAnnotation is set in the postgres-config configmap with the checksum of
+	// the postgresql.conf content that has already been loaded by the database
+	postgresReloadAnnotation = "repo-manager.pulpproject.org/postgres-reload-checksum"
+)
+
+type postgresParameterType int
+
+const (
+	postgresBool postgresParameterType = iota
+	postgresInteger
+	postgresReal
+	postgresMemory
+	postgresTime
+	postgresString
+)
+
+// postgresParameter describes a postgresql.conf parameter
+type postgresParameter struct {
+	// the type of the value expected by postgres
+	paramType postgresParameterType
+
+	// restart is true if the parameter can only be modified with a server restart
+	// (parameters with "postmaster" context)
+	restart bool
+}
+
+// knownPostgresParameters contains the postgresql.conf parameters that we can validate.
+// Parameters not listed here are still passed to postgres, but their values are not
+// checked and modifying them will restart the database.
+var knownPostgresParameters = map[string]postgresParameter{
+	// connections and memory
+	"max_connections":                  {postgresInteger, true},
+	"superuser_reserved_connections":   {postgresInteger, true},
+	"shared_buffers":                   {postgresMemory, true},
+	"huge_pages":                       {postgresString, true},
+	"max_prepared_transactions":        {postgresInteger, true},
+	"max_locks_per_transaction":        {postgresInteger, true},
+	"shared_preload_libraries":         {postgresString, true},
+	"max_worker_processes":             {postgresInteger, true},
+	"work_mem":                         {postgresMemory, false},
+	"maintenance_work_mem":             {postgresMemory, false},
+	"temp_buffers":                     {postgresMemory, false},
+	"effective_cache_size":             {postgresMemory, false},
+	"max_parallel_workers":             {postgresInteger, false},
+	"max_parallel_workers_per_gather":  {postgresInteger, false},
+	"max_parallel_maintenance_workers": {postgresInteger, false},
+
+	// planner
+	"random_page_cost":          {postgresReal, false},
+	"seq_page_cost":             {postgresReal, false},
+	"effective_io_concurrency":  {postgresInteger, false},
+	"default_statistics_target": {postgresInteger, false},
+	"jit":                       {postgresBool, false},
+
+	// write ahead log and replication
+	"wal_level":                    {postgresString, true},
+	"wal_buffers":                  {postgresMemory, true},
+	"max_wal_senders":              {postgresInteger, true},
+	"max_replication_slots":        {postgresInteger, true},
+	"hot_standby":                  {postgresBool, true},
+	"wal_compression":              {postgresBool, false},
+	"wal_keep_size":                {postgresMemory, false},
+	"max_wal_size":                 {postgresMemory, false},
+	"min_wal_size":                 {postgresMemory, false},
+	"checkpoint_timeout":           {postgresTime, false},
+	"checkpoint_completion_target": {postgresReal, false},
+	"synchronous_commit":           {postgresString, false},
+
+	// autovacuum
+	"autovacuum":                      {postgresBool, false},
+	"autovacuum_max_workers":          {postgresInteger, true},
+	"autovacuum_naptime":              {postgresTime, false},
+	"autovacuum_vacuum_scale_factor":  {postgresReal, false},
+	"autovacuum_analyze_scale_factor": {postgresReal, false},
+
+	// timeouts
+	"statement_timeout":                   {postgresTime, false},
+	"lock_timeout":                        {postgresTime, false},
+	"idle_in_transaction_session_timeout": {postgresTime, false},
+
+	// logging
+	"logging_collector":           {postgresBool, true},
+	"log_destination":             {postgresString, false},
+	"log_min_messages":            {postgresString, false},
+	"log_min_duration_statement":  {postgresTime, false},
+	"log_autovacuum_min_duration": {postgresTime, false},
+	"log_statement":               {postgresString, false},
+	"log_line_prefix":             {postgresString, false},
+	"log_connections":             {postgresBool, false},
+	"log_disconnections":          {postgresBool, false},
+	"log_checkpoints":             {postgresBool, false},
+	"log_lock_waits":              {postgresBool, false},
+	"log_temp_files":              {postgresMemory, false},
+	"track_io_timing":             {postgresBool, false},
+}
+
+// reservedPostgresParameters are the parameters managed by the operator
+var reservedPostgresParameters = []string{"config_file", "data_directory", "hba_file", "ident_file", "external_pid_file", "listen_addresses", "port"}
+
+var (
+	postgresParameterName = regexp.MustCompile(`^[a-z_][a-z0-9_.]*$`)
+	postgresMemoryValue   = regexp.MustCompile(`^-?[0-9]+\s*(B|kB|MB|GB|TB)?$`)
+	postgresTimeValue     = regexp.MustCompile(`^-?[0-9]+\s*(us|ms|s|min|h|d)?$`)
+)
+
+// validatePostgresParameters returns an error if a parameter from pulp CR is reserved or
+// is known and its value does not match the expected type
+func validatePostgresParameters(parameters map[string]string) error {
+	for name, value := range parameters {
+		if !postgresParameterName.MatchString(name) {
+			return fmt.Errorf("invalid postgres parameter name: %v", name)
+		}
+		for _, reserved := range reservedPostgresParameters {
+			if name == reserved {
+				return fmt.Errorf("postgres parameter %v is managed by the operator and cannot be modified", name)
+			}
+		}
+		if strings.ContainsAny(value, "\n\r") {
+			return fmt.Errorf("invalid value for postgres parameter %v: line breaks are not allowed", name)
+		}
+
+		param, known := knownPostgresParameters[name]
+		if !known {
+			continue
+		}
+
+		valid := true
+		switch param.paramType {
+		case postgresBool:
+			switch strings.ToLower(value) {
+			case "on", "off", "true", "false", "yes", "no", "1", "0":
+			default:
+				valid = false
+			}
+		case postgresInteger:
+			_, err := strconv.ParseInt(value, 10, 64)
+			valid = err == nil
+		case postgresReal:
+			_, err := strconv.ParseFloat(value, 64)
+			valid = err == nil
+		case postgresMemory:
+			valid = postgresMemoryValue.MatchString(value)
+		case postgresTime:
+			valid = postgresTimeValue.MatchString(value)
+		}
+		if !valid {
+			return fmt.Errorf("invalid value for postgres parameter %v: %v", name, value)
+		}
+	}
+	return nil
+}
+
+// postgresRestartChecksum returns the checksum of the parameters that need a server restart
+func postgresRestartChecksum(parameters map[string]string) string {
+	var restartParams []string
+	for name, value := range parameters {
+		if param, known := knownPostgresParameters[name]; known && !param.restart {
+			continue
+		}
+		restartParams = append(restartParams, name+"="+value)
+	}
+	sort.Strings(restartParams)
+	return checksum(strings.Join(restartParams, "\n"))
+}
+
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// postgresConfigNeeded returns true if the database pods should be deployed with
+// the postgresql.conf provided by the operator
+func postgresConfigNeeded(m *repomanagerv1alpha1.Pulp) bool {
+	return len(m.Spec.Database.PostgresParameters) > 0 || databaseReplicas(m) > 1
+}
+
+// postgresHbaConfig returns the pg_hba.conf used when streaming replication is configured.
+// It is the same as the one created by the postgres image entrypoint plus the rule to
+// allow the replicas to connect to the primary.
+func postgresHbaConfig(m *repomanagerv1alpha1.Pulp) string {
+	postgresHostAuthMethod := m.Spec.Database.PostgresHostAuthMethod
+	if postgresHostAuthMethod == "" {
+		postgresHostAuthMethod = "scram-sha-256"
+	}
+
+	return `local   all          all                 trust
+host    all          all   127.0.0.1/32  trust
+host    all          all   ::1/128       trust
+local   replication  all                 trust
+host    replication  all   127.0.0.1/32  trust
+host    replication  all   ::1/128       trust
+host    all          all   all           ` + postgresHostAuthMethod + `
+host    replication  all   all           ` + postgresHostAuthMethod + `
+`
+}
+
+// postgresConfig returns the content of postgresql.conf, pg_hba.conf (only when replicas are
+// configured) and their checksum.
+// The postgresql.conf includes the file created by initdb so that any parameter
+// not defined in pulp CR keeps the default value.
+func postgresConfig(m *repomanagerv1alpha1.Pulp) (string, string, string) {
+	postgresDataPath := m.Spec.Database.PostgresDataPath
+	if postgresDataPath == "" {
+		postgresDataPath = "/var/lib/postgresql/data/pgdata"
+	}
+
+	hbaFile, hbaConfig := postgresDataPath+"/"+postgresHbaFile, ""
+	if databaseReplicas(m) > 1 {
+		hbaFile, hbaConfig = postgresConfigMountPath+"/"+postgresHbaFile, postgresHbaConfig(m)
+	}
+
+	names := make([]string, 0, len(m.Spec.Database.PostgresParameters))
+	for name := range m.Spec.Database.PostgresParameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	config := "include_if_exists = '" + postgresDataPath + "/postgresql.conf'\n"
+	config = config + "hba_file = '" + hbaFile + "'\n"
+	config = config + "ident_file = '" + postgresDataPath + "/pg_ident.conf'\n"
+	config = config + "listen_addresses = '*'\n"
+	for _, name := range names {
+		value := strings.ReplaceAll(m.Spec.Database.PostgresParameters[name], "'", "''")
+		config = config + fmt.Sprintf("%v = '%v'\n", name, value)
+	}
+
+	sum := checksum(config + hbaConfig)
+	return "# managed by " + m.Spec.DeploymentType + "-operator, checksum: " + sum + "\n" + config, hbaConfig, sum
+}
+
+// postgresConfigMap returns the configmap with the postgresql.conf mounted in database pod
+func postgresConfigMap(m *repomanagerv1alpha1.Pulp) *corev1.ConfigMap {
+	config, hbaConfig, sum := postgresConfig(m)
+	data := map[string]string{
+		postgresConfigFile: config,
+	}
+	if len(hbaConfig) > 0 {
+		data[postgresHbaFile] = hbaConfig
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.Name + "-postgres-config",
+			Namespace: m.Namespace,
+			Annotations: map[string]string{
+				postgresReloadAnnotation: sum,
+			},
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "postgres",
+				"app.kubernetes.io/instance":   "postgres-" + m.Name,
+				"app.kubernetes.io/component":  "database",
+				"app.kubernetes.io/part-of":    m.Spec.DeploymentType,
+				"app.kubernetes.io/managed-by": m.Spec.DeploymentType + "-operator",
+			},
+		},
+		Data: data,
+	}
+}
+
+// postgresConfigController creates and reconciles the configmap with postgresql.conf
+func (r *PulpReconciler) postgresConfigController(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) (ctrl.Result, error) {
+	conditionType := cases.Title(language.English, cases.Compact).String(pulp.Spec.DeploymentType) + "-Database-Ready"
+
+	configMap := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-postgres-config", Namespace: pulp.Namespace}, configMap)
+
+	// remove the configmap if there is no parameter nor replica defined anymore
+	if !postgresConfigNeeded(pulp) {
+		if err == nil {
+			log.Info("Removing " + pulp.Name + "-postgres-config configmap")
+			if err = r.Delete(ctx, configMap); err != nil {
+				log.Error(err, "Failed to remove "+pulp.Name+"-postgres-config configmap")
+				return ctrl.Result{}, err
+			}
+		} else if !errors.IsNotFound(err) {
+			log.Error(err, "Failed to get "+pulp.Name+"-postgres-config configmap")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if err := validatePostgresParameters(pulp.Spec.Database.PostgresParameters); err != nil {
+		log.Error(err, "Invalid postgres_parameters")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "InvalidPostgresParameters", err.Error())
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid postgres_parameters: "+err.Error())
+		return ctrl.Result{}, err
+	}
+
+	expectedConfigMap := postgresConfigMap(pulp)
+	ctrl.SetControllerReference(pulp, expectedConfigMap, r.Scheme)
+
+	if err != nil && errors.IsNotFound(err) {
+		log.Info("Creating a new Postgres ConfigMap", "ConfigMap.Namespace", expectedConfigMap.Namespace, "ConfigMap.Name", expectedConfigMap.Name)
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "CreatingPostgresConfigMap", "Creating "+pulp.Name+"-postgres-config configmap resource")
+		err = r.Create(ctx, expectedConfigMap)
+		if err != nil {
+			log.Error(err, "Failed to create new Postgres ConfigMap", "ConfigMap.Namespace", expectedConfigMap.Namespace, "ConfigMap.Name", expectedConfigMap.Name)
+			r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "ErrorCreatingPostgresConfigMap", "Failed to create "+pulp.Name+"-postgres-config configmap resource: "+err.Error())
+			r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to create new Postgres ConfigMap")
+			return ctrl.Result{}, err
+		}
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Created", "Postgres ConfigMap created")
+		return ctrl.Result{Requeue: true}, nil
+	} else if err != nil {
+		log.Error(err, "Failed to get Postgres ConfigMap")
+		return ctrl.Result{}, err
+	}
+
+	// Reconcile ConfigMap
+	if !equality.Semantic.DeepDerivative(expectedConfigMap.Data, configMap.Data) || len(expectedConfigMap.Data) != len(configMap.Data) {
+		log.Info("The Postgres ConfigMap has been modified! Reconciling ...")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "UpdatingPostgresConfigMap", "Reconciling "+pulp.Name+"-postgres-config configmap resource")
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updating", "Reconciling Postgres ConfigMap")
+
+		// keep the checksum of the configuration that is currently loaded so that
+		// we can detect that a reload is needed
+		expectedConfigMap.Annotations[postgresReloadAnnotation] = configMap.Annotations[postgresReloadAnnotation]
+		expectedConfigMap.SetResourceVersion(configMap.GetResourceVersion())
+		err = r.Update(ctx, expectedConfigMap)
+		if err != nil {
+			log.Error(err, "Error trying to update the Postgres ConfigMap object ... ")
+			r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "ErrorUpdatingPostgresConfigMap", "Failed to reconcile "+pulp.Name+"-postgres-config configmap resource: "+err.Error())
+			r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to reconcile Postgres ConfigMap")
+			return ctrl.Result{}, err
+		}
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updated", "Postgres ConfigMap reconciled")
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// reloadPostgresConfig asks postgres to reload postgresql.conf after the configmap has been
+// modified with parameters that do not need a server restart
+func (r *PulpReconciler) reloadPostgresConfig(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) (ctrl.Result, error) {
+	if !postgresConfigNeeded(pulp) {
+		return ctrl.Result{}, nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-postgres-config", Namespace: pulp.Namespace}, configMap); err != nil {
+		log.Error(err, "Failed to get Postgres ConfigMap")
+		return ctrl.Result{}, err
+	}
+
+	_, _, sum := postgresConfig(pulp)
+	if configMap.Annotations[postgresReloadAnnotation] == sum {
+		return ctrl.Result{}, nil
+	}
+
+	// kubelet takes some time to update the files from a configmap volume, so we
+	// only reload the configuration after the new postgresql.conf is found in the pod
+	execCmd := []string{
+		"/bin/sh", "-c",
+		"grep -q 'checksum: " + sum + "' " + postgresConfigMountPath + "/" + postgresConfigFile +
+			` && psql -U "$POSTGRES_USER" -d "$POSTGRES_DB" -tAc 'SELECT pg_reload_conf()'`,
+	}
+	for ordinal := int32(0); ordinal < databaseReplicas(pulp); ordinal++ {
+		pod := &corev1.Pod{}
+		err := r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-database-" + strconv.Itoa(int(ordinal)), Namespace: pulp.Namespace}, pod)
+		if err != nil || pod.Status.Phase != corev1.PodRunning {
+			log.Info("Waiting for database pod to reload postgres configuration ...")
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+		}
+
+		if _, err := controllers.ContainerExec(r, pod, execCmd, "postgres", pod.Namespace); err != nil {
+			log.V(1).Info("Postgres configuration not synchronized in database pod yet ...", "pod", pod.Name, "error", err.Error())
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+		}
+	}
+
+	log.Info("Postgres configuration reloaded")
+	r.recorder.Event(pulp, corev1.EventTypeNormal, "Reloaded", "Postgres configuration reloaded")
+	if configMap.Annotations == nil {
+		configMap.Annotations = map[string]string{}
+	}
+	configMap.Annotations[postgresReloadAnnotation] = sum
+	if err := r.Update(ctx, configMap); err != nil {
+		log.Error(err, "Failed to update Postgres ConfigMap reload checksum")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
@@ -0,0 +1,320 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// deploymentProfile defines the values of a deployment size profile
+type deploymentProfile struct {
+	// replicas of api, content, worker and web
+	replicas map[string]int32
+
+	// minAvailable of the PDB of api, content, worker and web (no PDB is defined if 0)
+	minAvailable map[string]int
+
+	// gunicorn workers of api and content
+	gunicornWorkers int
+
+	// spread the pods of api, content, worker and web across the nodes
+	antiAffinity bool
+
+	// cpu request, memory request and memory limit of api, content, worker, web, database and cache
+	resources map[string][3]string
+}
+
+var deploymentProfiles = map[string]deploymentProfile{
+	"dev": {
+		replicas:        map[string]int32{"api": 1, "content": 1, "worker": 1, "web": 1},
+		gunicornWorkers: 1,
+		resources: map[string][3]string{
+			"api":      {"50m", "256Mi", ""},
+			"content":  {"50m", "128Mi", ""},
+			"worker":   {"50m", "256Mi", ""},
+			"web":      {"10m", "32Mi", ""},
+			"database": {"50m", "128Mi", ""},
+			"cache":    {"10m", "32Mi", ""},
+		},
+	},
+	"small": {
+		replicas:        map[string]int32{"api": 1, "content": 1, "worker": 1, "web": 1},
+		gunicornWorkers: 2,
+		resources: map[string][3]string{
+			"api":      {"250m", "512Mi", "1Gi"},
+			"content":  {"100m", "256Mi", "512Mi"},
+			"worker":   {"250m", "512Mi", "1Gi"},
+			"web":      {"50m", "64Mi", "256Mi"},
+			"database": {"250m", "512Mi", "1Gi"},
+			"cache":    {"50m", "64Mi", "256Mi"},
+		},
+	},
+	"medium": {
+		replicas:        map[string]int32{"api": 2, "content": 2, "worker": 2, "web": 2},
+		minAvailable:    map[string]int{"api": 1, "content": 1, "worker": 1, "web": 1},
+		gunicornWorkers: 2,
+		antiAffinity:    true,
+		resources: map[string][3]string{
+			"api":      {"500m", "1Gi", "2Gi"},
+			"content":  {"250m", "512Mi", "1Gi"},
+			"worker":   {"500m", "1Gi", "2Gi"},
+			"web":      {"100m", "128Mi", "256Mi"},
+			"database": {"1", "2Gi", "4Gi"},
+			"cache":    {"100m", "256Mi", "512Mi"},
+		},
+	},
+	"large": {
+		replicas:        map[string]int32{"api": 3, "content": 3, "worker": 4, "web": 2},
+		minAvailable:    map[string]int{"api": 2, "content": 2, "worker": 2, "web": 1},
+		gunicornWorkers: 4,
+		antiAffinity:    true,
+		resources: map[string][3]string{
+			"api":      {"1", "2Gi", "4Gi"},
+			"content":  {"500m", "1Gi", "2Gi"},
+			"worker":   {"1", "2Gi", "4Gi"},
+			"web":      {"200m", "256Mi", "512Mi"},
+			"database": {"2", "4Gi", "8Gi"},
+			"cache":    {"200m", "512Mi", "1Gi"},
+		},
+	},
+}
+
+// profileField is a field of pulp CR spec that can be defined by a profile
+type profileField struct {
+	// path of the field in pulp CR spec
+	path []string
+
+	// field returns a pointer to the field in a PulpSpec
+	field func(*repomanagerv1alpha1.PulpSpec) interface{}
+}
+
+// explicit returns true if the field is explicitly defined in spec.
+// None of the fields has a default in the CRD, so they are only set when defined in pulp CR.
+func (f profileField) explicit(spec *repomanagerv1alpha1.PulpSpec) bool {
+	return !reflect.ValueOf(f.field(spec)).Elem().IsZero()
+}
+
+var profileFields = []profileField{
+	{[]string{"api", "replicas"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Api.Replicas }},
+	{[]string{"api", "gunicorn_workers"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Api.GunicornWorkers }},
+	{[]string{"api", "resource_requirements"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Api.ResourceRequirements }},
+	{[]string{"api", "pdb"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Api.PDB }},
+	{[]string{"api", "affinity", "podAntiAffinity"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Api.Affinity.PodAntiAffinity }},
+	{[]string{"content", "replicas"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Content.Replicas }},
+	{[]string{"content", "gunicorn_workers"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Content.GunicornWorkers }},
+	{[]string{"content", "resource_requirements"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Content.ResourceRequirements }},
+	{[]string{"content", "pdb"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Content.PDB }},
+	{[]string{"content", "affinity", "podAntiAffinity"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Content.Affinity.PodAntiAffinity }},
+	{[]string{"worker", "replicas"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Worker.Replicas }},
+	{[]string{"worker", "resource_requirements"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Worker.ResourceRequirements }},
+	{[]string{"worker", "pdb"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Worker.PDB }},
+	{[]string{"worker", "affinity", "podAntiAffinity"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Worker.Affinity.PodAntiAffinity }},
+	{[]string{"web", "replicas"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Web.Replicas }},
+	{[]string{"web", "resource_requirements"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Web.ResourceRequirements }},
+	{[]string{"web", "pdb"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Web.PDB }},
+	{[]string{"web", "affinity", "podAntiAffinity"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Web.Affinity.PodAntiAffinity }},
+	{[]string{"database", "postgres_resource_requirements"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Database.ResourceRequirements }},
+	{[]string{"cache", "redis_resource_requirements"}, func(s *repomanagerv1alpha1.PulpSpec) interface{} { return &s.Cache.RedisResourceRequirements }},
+}
+
+// profileResources returns the resource requirements from a cpu request, memory request and memory limit
+func profileResources(values [3]string) corev1.ResourceRequirements {
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(values[0]),
+			corev1.ResourceMemory: resource.MustParse(values[1]),
+		},
+	}
+	if len(values[2]) > 0 {
+		resources.Limits = corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(values[2])}
+	}
+	return resources
+}
+
+// profileAntiAffinity returns the podAntiAffinity that spreads the pods with the
+// given labels across the nodes
+func profileAntiAffinity(labels map[string]string) *corev1.PodAntiAffinity {
+	return &corev1.PodAntiAffinity{
+		PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+			Weight: 100,
+			PodAffinityTerm: corev1.PodAffinityTerm{
+				LabelSelector: &metav1.LabelSelector{MatchLabels: labels},
+				TopologyKey:   "kubernetes.io/hostname",
+			},
+		}},
+	}
+}
+
+// profileSpec returns a PulpSpec with the values defined by a profile
+func profileSpec(m *repomanagerv1alpha1.Pulp, profile deploymentProfile) *repomanagerv1alpha1.PulpSpec {
+	spec := &repomanagerv1alpha1.PulpSpec{}
+
+	replicas := map[string]**int32{
+		"api":     &spec.Api.Replicas,
+		"content": &spec.Content.Replicas,
+		"worker":  &spec.Worker.Replicas,
+		"web":     &spec.Web.Replicas,
+	}
+	for component, r := range replicas {
+		value := profile.replicas[component]
+		*r = &value
+	}
+
+	apiGunicornWorkers, contentGunicornWorkers := profile.gunicornWorkers, profile.gunicornWorkers
+	spec.Api.GunicornWorkers = &apiGunicornWorkers
+	spec.Content.GunicornWorkers = &contentGunicornWorkers
+
+	spec.Api.ResourceRequirements = profileResources(profile.resources["api"])
+	spec.Content.ResourceRequirements = profileResources(profile.resources["content"])
+	spec.Worker.ResourceRequirements = profileResources(profile.resources["worker"])
+	spec.Web.ResourceRequirements = profileResources(profile.resources["web"])
+	spec.Database.ResourceRequirements = profileResources(profile.resources["database"])
+	spec.Cache.RedisResourceRequirements = profileResources(profile.resources["cache"])
+
+	pdbs := map[string]**policy.PodDisruptionBudgetSpec{
+		"api":     &spec.Api.PDB,
+		"content": &spec.Content.PDB,
+		"worker":  &spec.Worker.PDB,
+		"web":     &spec.Web.PDB,
+	}
+	for component, pdb := range pdbs {
+		if minAvailable, found := profile.minAvailable[component]; found {
+			value := intstr.FromInt(minAvailable)
+			*pdb = &policy.PodDisruptionBudgetSpec{MinAvailable: &value}
+		}
+	}
+
+	if profile.antiAffinity {
+		spec.Api.Affinity.PodAntiAffinity = profileAntiAffinity(labelsForPulpApi(m))
+		spec.Content.Affinity.PodAntiAffinity = profileAntiAffinity(labelsForPulpContent(m))
+		spec.Worker.Affinity.PodAntiAffinity = profileAntiAffinity(labelsForPulpWorker(m))
+		spec.Web.Affinity.PodAntiAffinity = profileAntiAffinity(labelsForPulpWeb(m))
+	}
+
+	return spec
+}
+
+// setPath sets the value in the nested map from path
+func setPath(fields map[string]interface{}, path []string, value interface{}) {
+	for _, name := range path[:len(path)-1] {
+		if _, found := fields[name]; !found {
+			fields[name] = map[string]interface{}{}
+		}
+		fields = fields[name].(map[string]interface{})
+	}
+	fields[path[len(path)-1]] = value
+}
+
+// applyProfile sets the values of pulp CR profile in the fields of its spec that are not
+// explicitly defined and returns the profile status with the values applied.
+func applyProfile(m *repomanagerv1alpha1.Pulp) *repomanagerv1alpha1.ProfileStatus {
+	profile, found := deploymentProfiles[m.Spec.Profile]
+	if !found {
+		return nil
+	}
+
+	values := profileSpec(m, profile)
+	applied := map[string]interface{}{}
+	for _, f := range profileFields {
+		src := reflect.ValueOf(f.field(values)).Elem()
+		if src.IsZero() || f.explicit(&m.Spec) {
+			continue
+		}
+		reflect.ValueOf(f.field(&m.Spec)).Elem().Set(src)
+		setPath(applied, f.path, src.Interface())
+	}
+
+	status := &repomanagerv1alpha1.ProfileStatus{Name: m.Spec.Profile}
+	status.Applied.Raw, _ = json.Marshal(applied)
+	return status
+}
+
+// effectivePulp returns a copy of pulp CR with the values of its profile, or the defaults, in
+// the fields of spec that are not defined. The profile is expanded in every reconciliation, so
+// that changes in pulp CR (or in the profiles) are reflected in the components, and only in
+// the copy, so that the object fetched from the cluster keeps the spec as defined by the users.
+func effectivePulp(m *repomanagerv1alpha1.Pulp) *repomanagerv1alpha1.Pulp {
+	effective := m.DeepCopy()
+	applyProfile(effective)
+
+	// defaults of the fields that can be defined by a profile
+	apiReplicas, contentReplicas, workerReplicas, webReplicas := int32(1), int32(2), int32(2), int32(1)
+	apiGunicornWorkers, contentGunicornWorkers := 2, 2
+	spec := &effective.Spec
+	if spec.Api.Replicas == nil {
+		spec.Api.Replicas = &apiReplicas
+	}
+	if spec.Content.Replicas == nil {
+		spec.Content.Replicas = &contentReplicas
+	}
+	if spec.Worker.Replicas == nil {
+		spec.Worker.Replicas = &workerReplicas
+	}
+	if spec.Web.Replicas == nil {
+		spec.Web.Replicas = &webReplicas
+	}
+	if spec.Api.GunicornWorkers == nil {
+		spec.Api.GunicornWorkers = &apiGunicornWorkers
+	}
+	if spec.Content.GunicornWorkers == nil {
+		spec.Content.GunicornWorkers = &contentGunicornWorkers
+	}
+	return effective
+}
+
+// profileStatusModified returns true if the profile status differs from the one in pulp CR
+func profileStatusModified(expected, found *repomanagerv1alpha1.ProfileStatus) bool {
+	if expected == nil || found == nil {
+		return expected != found
+	}
+	if expected.Name != found.Name {
+		return true
+	}
+
+	// compare the decoded values because the API server can encode them differently
+	var expectedApplied, foundApplied interface{}
+	json.Unmarshal(expected.Applied.Raw, &expectedApplied)
+	json.Unmarshal(found.Applied.Raw, &foundApplied)
+	return !equality.Semantic.DeepEqual(expectedApplied, foundApplied)
+}
+
+// profileController records in pulp CR status the profile applied and the values it defined
+func (r *PulpReconciler) profileController(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) (ctrl.Result, error) {
+	status := applyProfile(pulp.DeepCopy())
+	if !profileStatusModified(status, pulp.Status.Profile) {
+		return ctrl.Result{}, nil
+	}
+	log.Info("Updating profile status", "Profile", pulp.Spec.Profile)
+	pulp.Status.Profile = status
+	if err := r.Status().Update(ctx, pulp); err != nil {
+		log.Error(err, "Failed to update profile status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
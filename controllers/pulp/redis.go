@@ -19,11 +19,55 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
+const (
+	// redisPasswordChecksumAnnotation is set in redis pod template with the checksum of the password
+	// so that redis is restarted when the password is rotated
+	redisPasswordChecksumAnnotation = "repo-manager.pulpproject.org/redis-password-checksum"
+)
+
 func (r *PulpReconciler) pulpCacheController(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) (ctrl.Result, error) {
 
+	if err := validateRedisMode(pulp); err != nil {
+		log.Error(err, "Invalid cache configuration")
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid cache configuration: "+err.Error())
+		return ctrl.Result{}, err
+	}
+	if err := validateVolumes("cache", redisPodSpec(pulp)); err != nil {
+		log.Error(err, "Invalid cache volumes configuration")
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid cache volumes configuration: "+err.Error())
+		return ctrl.Result{}, err
+	}
+	if err := validateContainers("cache", redisPodSpec(pulp)); err != nil {
+		log.Error(err, "Invalid cache containers configuration")
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid cache containers configuration: "+err.Error())
+		return ctrl.Result{}, err
+	}
+
+	// pulp-redis-password secret
+	redisSecret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: redisPasswordSecretName(pulp), Namespace: pulp.Namespace}, redisSecret)
+	if err != nil && errors.IsNotFound(err) {
+		sec := redisPasswordSecret(pulp)
+		ctrl.SetControllerReference(pulp, sec, r.Scheme)
+		log.Info("Creating a new Redis password secret", "Secret.Namespace", sec.Namespace, "Secret.Name", sec.Name)
+		err = r.Create(ctx, sec)
+		if err != nil {
+			log.Error(err, "Failed to create new Redis password secret", "Secret.Namespace", sec.Namespace, "Secret.Name", sec.Name)
+			r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to create new Redis password secret")
+			return ctrl.Result{}, err
+		}
+		// Secret created successfully - return and requeue
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Created", "Redis password secret created")
+		return ctrl.Result{Requeue: true}, nil
+	} else if err != nil {
+		log.Error(err, "Failed to get Redis password secret")
+		return ctrl.Result{}, err
+	}
+
 	// pulp-redis-data PVC
 	// the PVC will be created only if a StorageClassName is provided
-	if _, storageType := controllers.MultiStorageConfigured(pulp, "Cache"); storageType[0] == controllers.SCNameType {
+	// in sentinel mode each pod gets its own PVC from the statefulset volumeClaimTemplates
+	if _, storageType := controllers.MultiStorageConfigured(pulp, "Cache"); storageType[0] == controllers.SCNameType && !redisSentinelMode(pulp) {
 		pvcFound := &corev1.PersistentVolumeClaim{}
 		err := r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-redis-data", Namespace: pulp.Namespace}, pvcFound)
 		pvc := redisDataPVC(pulp)
@@ -60,9 +104,50 @@ func (r *PulpReconciler) pulpCacheController(ctx context.Context, pulp *repomana
 		}
 	}
 
+	podAnnotations := map[string]string{
+		redisPasswordChecksumAnnotation: checksum(string(redisSecret.Data["password"])),
+	}
+
+	// pulp-redis-tls secret
+	if managedRedisTLS(pulp) {
+		tlsSecret := &corev1.Secret{}
+		err = r.Get(ctx, types.NamespacedName{Name: redisTLSSecretName(pulp), Namespace: pulp.Namespace}, tlsSecret)
+		if err != nil && errors.IsNotFound(err) {
+			sec, err := redisTLSSecret(pulp)
+			if err != nil {
+				log.Error(err, "Failed to generate Redis certificates")
+				r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to generate Redis certificates")
+				return ctrl.Result{}, err
+			}
+			ctrl.SetControllerReference(pulp, sec, r.Scheme)
+			log.Info("Creating a new Redis TLS secret", "Secret.Namespace", sec.Namespace, "Secret.Name", sec.Name)
+			err = r.Create(ctx, sec)
+			if err != nil {
+				log.Error(err, "Failed to create new Redis TLS secret", "Secret.Namespace", sec.Namespace, "Secret.Name", sec.Name)
+				r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to create new Redis TLS secret")
+				return ctrl.Result{}, err
+			}
+			// Secret created successfully - return and requeue
+			r.recorder.Event(pulp, corev1.EventTypeNormal, "Created", "Redis TLS secret created")
+			return ctrl.Result{Requeue: true}, nil
+		} else if err != nil {
+			log.Error(err, "Failed to get Redis TLS secret")
+			return ctrl.Result{}, err
+		}
+		podAnnotations[redisTLSChecksumAnnotation] = checksum(string(tlsSecret.Data["ca.crt"]) + string(tlsSecret.Data["tls.crt"]) + string(tlsSecret.Data["tls.key"]))
+	}
+
+	// pulp-redis-config configmap
+	if res, err := r.redisConfigController(ctx, pulp, log); err != nil || res.Requeue || res.RequeueAfter > 0 {
+		return res, err
+	}
+	if pulp.Spec.Cache.RedisConfig != nil {
+		podAnnotations[redisConfigChecksumAnnotation] = checksum(redisConfig(pulp))
+	}
+
 	// redis-svc Service
 	svcFound := &corev1.Service{}
-	err := r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-redis-svc", Namespace: pulp.Namespace}, svcFound)
+	err = r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-redis-svc", Namespace: pulp.Namespace}, svcFound)
 	svc := redisSvc(pulp)
 	if err != nil && errors.IsNotFound(err) {
 		ctrl.SetControllerReference(pulp, svc, r.Scheme)
@@ -82,7 +167,7 @@ func (r *PulpReconciler) pulpCacheController(ctx context.Context, pulp *repomana
 	}
 
 	// Reconcile Service
-	if !equality.Semantic.DeepDerivative(svc.Spec, svcFound.Spec) {
+	if !equality.Semantic.DeepDerivative(svc.Spec, svcFound.Spec) || len(svc.Spec.Selector) != len(svcFound.Spec.Selector) {
 		log.Info("The Redis Service has been modified! Reconciling ...")
 		ctrl.SetControllerReference(pulp, svc, r.Scheme)
 		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updating", "Reconciling Redis Service")
@@ -96,13 +181,28 @@ func (r *PulpReconciler) pulpCacheController(ctx context.Context, pulp *repomana
 		return ctrl.Result{Requeue: true, RequeueAfter: time.Second}, nil
 	}
 
+	if redisSentinelMode(pulp) {
+		res, err := r.redisSentinelController(ctx, pulp, podAnnotations, log)
+		if err != nil || res.Requeue || res.RequeueAfter > 0 {
+			return res, err
+		}
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "RedisReady", "All Redis tasks ran successfully")
+		return ctrl.Result{}, nil
+	}
+
+	// remove the sentinel resources in case cache.mode has been modified to standalone
+	if res, err := r.removeRedisSentinelResources(ctx, pulp, log); err != nil || res.Requeue {
+		return res, err
+	}
+
 	// redis Deployment
 	deploymentFound := &appsv1.Deployment{}
 	err = r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-redis", Namespace: pulp.Namespace}, deploymentFound)
-	dep := redisDeployment(pulp)
+	dep := redisDeployment(pulp, podAnnotations)
 	if err != nil && errors.IsNotFound(err) {
 		ctrl.SetControllerReference(pulp, dep, r.Scheme)
 		log.Info("Creating a new Pulp Redis Deployment", "Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
+		r.warnEnvOverrides(pulp, "cache", dep.Spec.Template.Spec)
 		err = r.Create(ctx, dep)
 		if err != nil {
 			log.Error(err, "Failed to create new Pulp Redis Deployment", "Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
@@ -118,10 +218,11 @@ func (r *PulpReconciler) pulpCacheController(ctx context.Context, pulp *repomana
 	}
 
 	// Reconcile Deployment
-	if !equality.Semantic.DeepDerivative(dep.Spec, deploymentFound.Spec) {
+	if !equality.Semantic.DeepDerivative(dep.Spec, deploymentFound.Spec) || podTemplateModified(dep.Spec.Template, deploymentFound.Spec.Template) {
 		log.Info("The Redis Deployment has been modified! Reconciling ...")
 		ctrl.SetControllerReference(pulp, dep, r.Scheme)
 		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updating", "Reconciling Redis Deployment")
+		r.warnEnvOverrides(pulp, "cache", dep.Spec.Template.Spec)
 		err = r.Update(ctx, dep)
 		if err != nil {
 			log.Error(err, "Error trying to update the Redis Deployment object ... ")
@@ -169,6 +270,12 @@ func redisDataPVC(m *repomanagerv1alpha1.Pulp) *corev1.PersistentVolumeClaim {
 
 // redis-svc Service
 func redisSvc(m *repomanagerv1alpha1.Pulp) *corev1.Service {
+	// in sentinel mode only the current master (labeled by the operator) receives the connections
+	selector := labelsForRedis(m)
+	if redisSentinelMode(m) {
+		selector[redisRoleLabel] = redisMasterRole
+	}
+
 	servicePortProto := corev1.Protocol("TCP")
 	targetPort := intstr.IntOrString{IntVal: 6379}
 
@@ -185,13 +292,7 @@ func redisSvc(m *repomanagerv1alpha1.Pulp) *corev1.Service {
 			},
 		},
 		Spec: corev1.ServiceSpec{
-			Selector: map[string]string{
-				"app.kubernetes.io/name":       "redis",
-				"app.kubernetes.io/instance":   "redis-" + m.Name,
-				"app.kubernetes.io/component":  "cache",
-				"app.kubernetes.io/part-of":    m.Spec.DeploymentType,
-				"app.kubernetes.io/managed-by": m.Spec.DeploymentType + "-operator",
-			},
+			Selector: selector,
 			Ports: []corev1.ServicePort{{
 				Port:       6379,
 				Protocol:   servicePortProto,
@@ -202,8 +303,50 @@ func redisSvc(m *repomanagerv1alpha1.Pulp) *corev1.Service {
 	}
 }
 
+// redisPasswordSecretName returns the name of the secret with the password of the managed redis
+func redisPasswordSecretName(m *repomanagerv1alpha1.Pulp) string {
+	return m.Name + "-redis-password"
+}
+
+// pulp-redis-password secret
+func redisPasswordSecret(m *repomanagerv1alpha1.Pulp) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      redisPasswordSecretName(m),
+			Namespace: m.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "redis",
+				"app.kubernetes.io/instance":   "redis-" + m.Name,
+				"app.kubernetes.io/component":  "cache",
+				"app.kubernetes.io/part-of":    m.Spec.DeploymentType,
+				"app.kubernetes.io/managed-by": m.Spec.DeploymentType + "-operator",
+			},
+		},
+		StringData: map[string]string{
+			"password": createPwd(32),
+		},
+	}
+}
+
+// redisPasswordEnvVar returns the REDIS_SERVICE_PASSWORD env var for pulpcore pods
+// using the managed redis instance
+func redisPasswordEnvVar(m *repomanagerv1alpha1.Pulp) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: "REDIS_SERVICE_PASSWORD",
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: redisPasswordSecretName(m),
+				},
+				Key: "password",
+			},
+		},
+	}
+}
+
 // redisDeployment returns a Redis Deployment object
-func redisDeployment(m *repomanagerv1alpha1.Pulp) *appsv1.Deployment {
+func redisDeployment(m *repomanagerv1alpha1.Pulp, podAnnotations map[string]string) *appsv1.Deployment {
+	m = effectivePulp(m)
 
 	replicas := int32(1)
 
@@ -211,6 +354,12 @@ func redisDeployment(m *repomanagerv1alpha1.Pulp) *appsv1.Deployment {
 	if m.Spec.Cache.Affinity.NodeAffinity != nil {
 		affinity.NodeAffinity = m.Spec.Cache.Affinity.NodeAffinity
 	}
+	if m.Spec.Cache.Affinity.PodAffinity != nil {
+		affinity.PodAffinity = m.Spec.Cache.Affinity.PodAffinity
+	}
+	if m.Spec.Cache.Affinity.PodAntiAffinity != nil {
+		affinity.PodAntiAffinity = m.Spec.Cache.Affinity.PodAntiAffinity
+	}
 
 	nodeSelector := map[string]string{}
 	if m.Spec.Cache.NodeSelector != nil {
@@ -222,6 +371,11 @@ func redisDeployment(m *repomanagerv1alpha1.Pulp) *appsv1.Deployment {
 		toleration = m.Spec.Cache.Tolerations
 	}
 
+	topologySpreadConstraint := []corev1.TopologySpreadConstraint{}
+	if m.Spec.Cache.TopologySpreadConstraints != nil {
+		topologySpreadConstraint = m.Spec.Cache.TopologySpreadConstraints
+	}
+
 	_, storageType := controllers.MultiStorageConfigured(m, "Cache")
 	volumeSource := corev1.VolumeSource{}
 	// if SC defined, we should use the PVC provisioned by the operator
@@ -252,6 +406,10 @@ func redisDeployment(m *repomanagerv1alpha1.Pulp) *appsv1.Deployment {
 			Name:         m.Name + "-redis-data",
 			VolumeSource: volumeSource,
 		},
+		{
+			Name:         redisGeneratedConfigVolume,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		},
 	}
 
 	volumeMounts := []corev1.VolumeMount{
@@ -260,59 +418,42 @@ func redisDeployment(m *repomanagerv1alpha1.Pulp) *appsv1.Deployment {
 			MountPath: "/data",
 			Name:      m.Name + "-redis-data",
 		},
+		{
+			MountPath: redisGeneratedConfigPath,
+			Name:      redisGeneratedConfigVolume,
+		},
 	}
 
-	readinessProbe := m.Spec.Cache.ReadinessProbe
-	if readinessProbe == nil {
-		readinessProbe = &corev1.Probe{
-			ProbeHandler: corev1.ProbeHandler{
-				Exec: &corev1.ExecAction{
-					Command: []string{
-						"/bin/sh",
-						"-i",
-						"-c",
-						"redis-cli -h 127.0.0.1 -p 6379",
-					},
-				},
-			},
-			InitialDelaySeconds: 5,
-			PeriodSeconds:       5,
-			TimeoutSeconds:      5,
-			FailureThreshold:    5,
-			SuccessThreshold:    1,
-		}
+	// the configuration file should be the first argument of redis-server
+	args := []string{"redis-server", redisGeneratedConfigPath + "/redis.conf"}
+	if m.Spec.Cache.RedisConfig != nil {
+		configVolume, configVolumeMount := redisConfigVolumes(m)
+		volumes = append(volumes, configVolume)
+		volumeMounts = append(volumeMounts, configVolumeMount)
 	}
-
-	livenessProbe := m.Spec.Cache.LivenessProbe
-	if livenessProbe == nil {
-		livenessProbe = &corev1.Probe{
-			ProbeHandler: corev1.ProbeHandler{
-				Exec: &corev1.ExecAction{
-					Command: []string{
-						"/bin/sh",
-						"-i",
-						"-c",
-						"redis-cli -h 127.0.0.1 -p 6379",
-					},
+	redisCli := "redis-cli -h 127.0.0.1 -p 6379"
+	if managedRedisTLS(m) {
+		args = append(args, redisTLSArgs()...)
+		redisCli = redisCli + " --tls --cacert " + redisTLSMountPath + "/ca.crt"
+		volumes = append(volumes, corev1.Volume{
+			Name: "redis-tls",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: redisTLSSecretName(m),
 				},
 			},
-			InitialDelaySeconds: 5,
-			PeriodSeconds:       5,
-			SuccessThreshold:    1,
-			FailureThreshold:    5,
-			TimeoutSeconds:      5,
-		}
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      "redis-tls",
+			MountPath: redisTLSMountPath,
+			ReadOnly:  true,
+		})
 	}
 
-	RedisImage := os.Getenv("RELATED_IMAGE_PULP_REDIS")
-	if len(m.Spec.Cache.RedisImage) > 0 {
-		RedisImage = m.Spec.Cache.RedisImage
-	} else if RedisImage == "" {
-		RedisImage = "redis:latest"
-	}
+	readinessProbe, livenessProbe := redisProbes(m, redisCli)
 
 	// deployment definition
-	return &appsv1.Deployment{
+	dep := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      m.Name + "-redis",
 			Namespace: m.Namespace,
@@ -351,28 +492,159 @@ func redisDeployment(m *repomanagerv1alpha1.Pulp) *appsv1.Deployment {
 						"app.kubernetes.io/part-of":    m.Spec.DeploymentType,
 						"app.kubernetes.io/managed-by": m.Spec.DeploymentType + "-operator",
 					},
+					Annotations: podAnnotations,
 				},
 				Spec: corev1.PodSpec{
-					Affinity:           affinity,
-					NodeSelector:       nodeSelector,
-					Tolerations:        toleration,
-					ServiceAccountName: m.Name,
-					Containers: []corev1.Container{{
+					Affinity:                      affinity,
+					NodeSelector:                  nodeSelector,
+					Tolerations:                   toleration,
+					TopologySpreadConstraints:     topologySpreadConstraint,
+					ServiceAccountName:            m.Name,
+					PriorityClassName:             m.Spec.Cache.PriorityClassName,
+					RuntimeClassName:              m.Spec.Cache.RuntimeClassName,
+					HostAliases:                   m.Spec.Cache.HostAliases,
+					TerminationGracePeriodSeconds: terminationGracePeriod(m.Spec.Cache.TerminationGracePeriodSeconds, defaultTerminationGracePeriod),
+					SecurityContext:               componentPodSecurityContext("cache", m.Spec.Cache.PodSecurityContext),
+					InitContainers: append(append([]corev1.Container{}, m.Spec.Cache.InitContainers...), corev1.Container{
+						Name:            "config",
+						Image:           redisImage(m),
+						Command:         []string{"/bin/sh", "-c", redisStandaloneConfigScript(m)},
+						Env:             redisEnvVars(m),
+						VolumeMounts:    volumeMounts,
+						SecurityContext: componentSecurityContext("cache", m.Spec.Cache.SecurityContext),
+					}),
+					Containers: append([]corev1.Container{{
 						Name:            "redis",
-						Image:           RedisImage,
+						Image:           redisImage(m),
 						ImagePullPolicy: corev1.PullPolicy("IfNotPresent"),
-						VolumeMounts:    volumeMounts,
+						Args:            args,
+						Env:             redisEnvVars(m),
+						EnvFrom:         m.Spec.Cache.EnvFrom,
+						VolumeMounts:    append(append([]corev1.VolumeMount{}, volumeMounts...), m.Spec.Cache.VolumeMounts...),
 						Ports: []corev1.ContainerPort{{
 							ContainerPort: 6379,
 							Protocol:      "TCP",
 						}},
-						LivenessProbe:  livenessProbe,
-						ReadinessProbe: readinessProbe,
-						Resources:      m.Spec.Cache.RedisResourceRequirements,
-					}},
-					Volumes: volumes,
+						LivenessProbe:   livenessProbe,
+						StartupProbe:    m.Spec.Cache.StartupProbe,
+						ReadinessProbe:  readinessProbe,
+						Resources:       m.Spec.Cache.RedisResourceRequirements,
+						SecurityContext: componentSecurityContext("cache", m.Spec.Cache.SecurityContext),
+					}}, m.Spec.Cache.SidecarContainers...),
+					Volumes: append(volumes, m.Spec.Cache.Volumes...),
 				},
 			},
 		},
 	}
+	setPodMetadata(m, &dep.Spec.Template, m.Spec.Cache.PodLabels, m.Spec.Cache.PodAnnotations)
+	return dep
+}
+
+// redisStandaloneConfigScript returns the script run by the init container to write redis.conf,
+// so that the password is not exposed in the redis-server arguments
+func redisStandaloneConfigScript(m *repomanagerv1alpha1.Pulp) string {
+	include := ""
+	if m.Spec.Cache.RedisConfig != nil {
+		include = "include " + redisConfigMountPath + "/" + redisConfigFile + "\n"
+	}
+	return `set -e
+cat > ` + redisGeneratedConfigPath + `/redis.conf <<EOF
+requirepass ${REDIS_PASSWORD}
+` + include + `EOF
+`
+}
+
+// redisProbes returns the readiness and liveness probes of the redis container
+// (the ones defined in pulp CR or the default probes running redisCli)
+func redisProbes(m *repomanagerv1alpha1.Pulp, redisCli string) (*corev1.Probe, *corev1.Probe) {
+	readinessProbe := m.Spec.Cache.ReadinessProbe
+	if readinessProbe == nil {
+		readinessProbe = &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				Exec: &corev1.ExecAction{
+					Command: []string{
+						"/bin/sh",
+						"-i",
+						"-c",
+						redisCli,
+					},
+				},
+			},
+			InitialDelaySeconds: 5,
+			PeriodSeconds:       5,
+			TimeoutSeconds:      5,
+			FailureThreshold:    5,
+			SuccessThreshold:    1,
+		}
+	}
+
+	livenessProbe := m.Spec.Cache.LivenessProbe
+	if livenessProbe == nil {
+		livenessProbe = &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				Exec: &corev1.ExecAction{
+					Command: []string{
+						"/bin/sh",
+						"-i",
+						"-c",
+						redisCli,
+					},
+				},
+			},
+			InitialDelaySeconds: 5,
+			PeriodSeconds:       5,
+			SuccessThreshold:    1,
+			FailureThreshold:    5,
+			TimeoutSeconds:      5,
+		}
+	}
+	return readinessProbe, livenessProbe
+}
+
+// redisEnvVars returns the env vars with the redis password
+func redisEnvVars(m *repomanagerv1alpha1.Pulp) []corev1.EnvVar {
+	// REDISCLI_AUTH is used by redis-cli (probes) to authenticate
+	envVars := []corev1.EnvVar{}
+	for _, name := range []string{"REDIS_PASSWORD", "REDISCLI_AUTH"} {
+		envVars = append(envVars, corev1.EnvVar{
+			Name: name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: redisPasswordSecretName(m),
+					},
+					Key: "password",
+				},
+			},
+		})
+	}
+
+	// the variables from pulp CR are added after the ones defined by the operator (so they take precedence)
+	return append(envVars, m.Spec.Cache.Env...)
+}
+
+// redisImage returns the redis image defined in pulp CR, RELATED_IMAGE_PULP_REDIS or the default one
+func redisImage(m *repomanagerv1alpha1.Pulp) string {
+	RedisImage := os.Getenv("RELATED_IMAGE_PULP_REDIS")
+	if len(m.Spec.Cache.RedisImage) > 0 {
+		RedisImage = m.Spec.Cache.RedisImage
+	} else if RedisImage == "" {
+		RedisImage = "redis:latest"
+	}
+	return RedisImage
+}
+
+// redisPodSpec returns the spec of the redis pods (from the sentinel statefulset or the
+// standalone deployment), including the volumes from the volumeClaimTemplates, to validate
+// the volumes and containers defined in pulp CR
+func redisPodSpec(m *repomanagerv1alpha1.Pulp) corev1.PodSpec {
+	if !redisSentinelMode(m) {
+		return redisDeployment(m, nil).Spec.Template.Spec
+	}
+	sts := redisStatefulSet(m, nil)
+	podSpec := sts.Spec.Template.Spec
+	for _, pvc := range sts.Spec.VolumeClaimTemplates {
+		podSpec.Volumes = append([]corev1.Volume{{Name: pvc.Name}}, podSpec.Volumes...)
+	}
+	return podSpec
 }
@@ -0,0 +1,236 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	redisConfigVolume    = "redis-config"
+	redisConfigMountPath = "/etc/redis/conf.d"
+	redisConfigFile      = "redis.conf"
+
+	// redisGeneratedConfigPath is where the config init container writes the configuration files
+	// with the password (redis.conf and, in sentinel mode, sentinel.conf)
+	redisGeneratedConfigVolume = "redis-conf"
+	redisGeneratedConfigPath   = "/redis-conf"
+
+	// redisConfigChecksumAnnotation is set in redis pod template with the checksum of redis.conf
+	// so that redis is restarted when the configuration is modified
+	redisConfigChecksumAnnotation = "repo-manager.pulpproject.org/redis-config-checksum"
+
+	// redisMaxMemoryLimitPercent is the maximum percentage of the redis container memory limit
+	// that can be used as maxmemory. The remaining memory is kept for the memory used by redis
+	// outside of the dataset (buffers, fragmentation, the fork for the persistence, etc), otherwise
+	// the container would be OOM killed before the eviction policy takes effect.
+	redisMaxMemoryLimitPercent = 80
+)
+
+// reservedRedisDirectives are the directives managed by the operator (or through the other
+// redis_config fields) that cannot be defined in redis_config.extra_config
+var reservedRedisDirectives = []string{
+	"port", "tls-port", "requirepass", "masterauth", "dir", "include", "replicaof", "slaveof",
+	"replica-announce-ip", "daemonize", "maxmemory", "maxmemory-policy", "appendonly", "appendfsync", "save",
+}
+
+var redisDirectiveName = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// validateRedisConfig returns an error if an extra_config directive is reserved or invalid
+// or if maxmemory is greater than redisMaxMemoryLimitPercent of the memory limit of the redis container
+func validateRedisConfig(m *repomanagerv1alpha1.Pulp) error {
+	m = effectivePulp(m)
+	config := m.Spec.Cache.RedisConfig
+	if config == nil {
+		return nil
+	}
+
+	if config.MaxMemory != nil {
+		if config.MaxMemory.Sign() <= 0 {
+			return fmt.Errorf("invalid redis_config.maxmemory: %v", config.MaxMemory.String())
+		}
+		if limit, found := m.Spec.Cache.RedisResourceRequirements.Limits[corev1.ResourceMemory]; found {
+			if config.MaxMemory.Value() > limit.Value()*redisMaxMemoryLimitPercent/100 {
+				return fmt.Errorf("redis_config.maxmemory (%v) cannot be greater than %v%% of the memory limit from redis_resource_requirements (%v)", config.MaxMemory.String(), redisMaxMemoryLimitPercent, limit.String())
+			}
+		}
+	}
+
+	if config.Save != nil && strings.ContainsAny(*config.Save, "\n\r\"") {
+		return fmt.Errorf("invalid value for redis_config.save: %v", *config.Save)
+	}
+
+	for name, value := range config.ExtraConfig {
+		if !redisDirectiveName.MatchString(name) || strings.HasPrefix(name, "tls-") {
+			return fmt.Errorf("invalid redis directive name: %v", name)
+		}
+		for _, reserved := range reservedRedisDirectives {
+			if name == reserved {
+				return fmt.Errorf("redis directive %v is managed by the operator and cannot be defined in redis_config.extra_config", name)
+			}
+		}
+		if strings.ContainsAny(value, "\n\r") {
+			return fmt.Errorf("invalid value for redis directive %v: line breaks are not allowed", name)
+		}
+	}
+	return nil
+}
+
+// redisConfig returns the content of the redis.conf defined in pulp CR
+func redisConfig(m *repomanagerv1alpha1.Pulp) string {
+	config := m.Spec.Cache.RedisConfig
+	content := "# managed by " + m.Spec.DeploymentType + "-operator\n"
+	if config.MaxMemory != nil {
+		content = content + "maxmemory " + strconv.FormatInt(config.MaxMemory.Value(), 10) + "\n"
+	}
+	if len(config.MaxMemoryPolicy) > 0 {
+		content = content + "maxmemory-policy " + config.MaxMemoryPolicy + "\n"
+	}
+	if config.AppendOnly != nil {
+		appendOnly := "no"
+		if *config.AppendOnly {
+			appendOnly = "yes"
+		}
+		content = content + "appendonly " + appendOnly + "\n"
+	}
+	if len(config.AppendFsync) > 0 {
+		content = content + "appendfsync " + config.AppendFsync + "\n"
+	}
+	if config.Save != nil {
+		content = content + "save \"" + *config.Save + "\"\n"
+	}
+
+	names := make([]string, 0, len(config.ExtraConfig))
+	for name := range config.ExtraConfig {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		content = content + name + " " + config.ExtraConfig[name] + "\n"
+	}
+	return content
+}
+
+// redisConfigMap returns the configmap with the redis.conf mounted in redis pods
+func redisConfigMap(m *repomanagerv1alpha1.Pulp) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.Name + "-redis-config",
+			Namespace: m.Namespace,
+			Labels:    labelsForRedis(m),
+		},
+		Data: map[string]string{
+			redisConfigFile: redisConfig(m),
+		},
+	}
+}
+
+// redisConfigVolumes returns the volume and the volume mount with the redis.conf from the configmap
+func redisConfigVolumes(m *repomanagerv1alpha1.Pulp) (corev1.Volume, corev1.VolumeMount) {
+	volume := corev1.Volume{
+		Name: redisConfigVolume,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: m.Name + "-redis-config",
+				},
+			},
+		},
+	}
+	volumeMount := corev1.VolumeMount{
+		Name:      redisConfigVolume,
+		MountPath: redisConfigMountPath,
+		ReadOnly:  true,
+	}
+	return volume, volumeMount
+}
+
+// redisConfigController creates and reconciles the configmap with redis.conf
+func (r *PulpReconciler) redisConfigController(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) (ctrl.Result, error) {
+	configMap := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-redis-config", Namespace: pulp.Namespace}, configMap)
+
+	// remove the configmap if redis_config is not defined anymore
+	if pulp.Spec.Cache.RedisConfig == nil {
+		if err == nil {
+			log.Info("Removing " + pulp.Name + "-redis-config configmap")
+			if err = r.Delete(ctx, configMap); err != nil {
+				log.Error(err, "Failed to remove "+pulp.Name+"-redis-config configmap")
+				return ctrl.Result{}, err
+			}
+		} else if !errors.IsNotFound(err) {
+			log.Error(err, "Failed to get "+pulp.Name+"-redis-config configmap")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if err := validateRedisConfig(pulp); err != nil {
+		log.Error(err, "Invalid redis_config")
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid redis_config: "+err.Error())
+		return ctrl.Result{}, err
+	}
+
+	expectedConfigMap := redisConfigMap(pulp)
+	ctrl.SetControllerReference(pulp, expectedConfigMap, r.Scheme)
+
+	if err != nil && errors.IsNotFound(err) {
+		log.Info("Creating a new Redis ConfigMap", "ConfigMap.Namespace", expectedConfigMap.Namespace, "ConfigMap.Name", expectedConfigMap.Name)
+		err = r.Create(ctx, expectedConfigMap)
+		if err != nil {
+			log.Error(err, "Failed to create new Redis ConfigMap", "ConfigMap.Namespace", expectedConfigMap.Namespace, "ConfigMap.Name", expectedConfigMap.Name)
+			r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to create new Redis ConfigMap")
+			return ctrl.Result{}, err
+		}
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Created", "Redis ConfigMap created")
+		return ctrl.Result{Requeue: true}, nil
+	} else if err != nil {
+		log.Error(err, "Failed to get Redis ConfigMap")
+		return ctrl.Result{}, err
+	}
+
+	// Reconcile ConfigMap
+	if !equality.Semantic.DeepDerivative(expectedConfigMap.Data, configMap.Data) || len(expectedConfigMap.Data) != len(configMap.Data) {
+		log.Info("The Redis ConfigMap has been modified! Reconciling ...")
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updating", "Reconciling Redis ConfigMap")
+		err = r.Update(ctx, expectedConfigMap)
+		if err != nil {
+			log.Error(err, "Error trying to update the Redis ConfigMap object ... ")
+			r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to reconcile Redis ConfigMap")
+			return ctrl.Result{}, err
+		}
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updated", "Redis ConfigMap reconciled")
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,617 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	"github.com/pulp/pulp-operator/controllers"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	redisModeSentinel = "sentinel"
+
+	// redisSentinelMaster is the name of the master monitored by Sentinel
+	redisSentinelMaster = "pulp"
+	redisSentinelPort   = 26379
+
+	// redisRoleLabel is set by the operator in each redis pod with its replication role
+	redisRoleLabel = "repo-manager.pulpproject.org/redis-role"
+
+	redisMasterRole  = "master"
+	redisReplicaRole = "replica"
+
+	// redisTopologyInterval is the interval to check the master elected by Sentinel, because
+	// a failover does not change any object watched by the operator
+	redisTopologyInterval = 30 * time.Second
+)
+
+// redisSentinelMode returns true if the redis instance deployed by the operator should run with Sentinel
+func redisSentinelMode(m *repomanagerv1alpha1.Pulp) bool {
+	return m.Spec.Cache.Mode == redisModeSentinel
+}
+
+// redisReplicas returns the number of redis pods in sentinel mode
+func redisReplicas(m *repomanagerv1alpha1.Pulp) int32 {
+	if m.Spec.Cache.Replicas < 1 {
+		return 3
+	}
+	return m.Spec.Cache.Replicas
+}
+
+// validateRedisMode returns an error if pulp CR defines a cache configuration
+// that cannot be used in sentinel mode
+func validateRedisMode(m *repomanagerv1alpha1.Pulp) error {
+	if !redisSentinelMode(m) {
+		return nil
+	}
+	if redisReplicas(m) < 3 {
+		return fmt.Errorf("cache.replicas should be at least 3 when cache.mode is sentinel, so that the sentinels can agree on a failover")
+	}
+	if len(m.Spec.Cache.PVC) > 0 {
+		return fmt.Errorf("cache.pvc cannot be used when cache.mode is sentinel, each redis pod needs its own volume (use cache.redis_storage_class instead)")
+	}
+	if m.Spec.Cache.TLS {
+		return fmt.Errorf("cache.tls is not supported when cache.mode is sentinel")
+	}
+	return nil
+}
+
+// labelsForRedis returns the labels for selecting the resources
+// belonging to the given pulp CR name.
+func labelsForRedis(m *repomanagerv1alpha1.Pulp) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":       "redis",
+		"app.kubernetes.io/instance":   "redis-" + m.Name,
+		"app.kubernetes.io/component":  "cache",
+		"app.kubernetes.io/part-of":    m.Spec.DeploymentType,
+		"app.kubernetes.io/managed-by": m.Spec.DeploymentType + "-operator",
+	}
+}
+
+// redisHeadlessSvc returns the headless service that gives each redis pod a stable DNS name
+func redisHeadlessSvc(m *repomanagerv1alpha1.Pulp) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.Name + "-redis-headless",
+			Namespace: m.Namespace,
+			Labels:    labelsForRedis(m),
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP:                "None",
+			PublishNotReadyAddresses: true,
+			Selector:                 labelsForRedis(m),
+			Ports: []corev1.ServicePort{{
+				Name:       "redis",
+				Port:       6379,
+				Protocol:   corev1.ProtocolTCP,
+				TargetPort: intstr.IntOrString{IntVal: 6379},
+			}, {
+				Name:       "sentinel",
+				Port:       redisSentinelPort,
+				Protocol:   corev1.ProtocolTCP,
+				TargetPort: intstr.IntOrString{IntVal: redisSentinelPort},
+			}},
+		},
+	}
+}
+
+// redisSentinelSvc returns the service used to query the sentinels
+func redisSentinelSvc(m *repomanagerv1alpha1.Pulp) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.Name + "-redis-sentinel-svc",
+			Namespace: m.Namespace,
+			Labels:    labelsForRedis(m),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: labelsForRedis(m),
+			Ports: []corev1.ServicePort{{
+				Name:       "sentinel",
+				Port:       redisSentinelPort,
+				Protocol:   corev1.ProtocolTCP,
+				TargetPort: intstr.IntOrString{IntVal: redisSentinelPort},
+			}},
+		},
+	}
+}
+
+// redisPDB returns the PodDisruptionBudget that keeps the sentinels quorum during voluntary disruptions
+func redisPDB(m *repomanagerv1alpha1.Pulp) *policy.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(1)
+	return &policy.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.Name + "-redis-pdb",
+			Namespace: m.Namespace,
+			Labels:    labelsForRedis(m),
+		},
+		Spec: policy.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labelsForRedis(m),
+			},
+		},
+	}
+}
+
+// redisSentinelConfigScript returns the script run by the init container to write redis.conf and
+// sentinel.conf. The current master is retrieved from the running sentinels, if there is none
+// (first deployment) the pod with ordinal 0 is the master.
+func redisSentinelConfigScript(m *repomanagerv1alpha1.Pulp) string {
+	headless := m.Name + "-redis-headless." + m.Namespace + ".svc"
+	quorum := strconv.Itoa(int(redisReplicas(m)/2 + 1))
+	return `set -e
+MY_HOST="${HOSTNAME}.` + headless + `"
+MASTER=$(redis-cli -h ` + m.Name + `-redis-sentinel-svc -p ` + strconv.Itoa(redisSentinelPort) + ` sentinel get-master-addr-by-name ` + redisSentinelMaster + ` 2>/dev/null | head -n 1 || true)
+if [ -z "$MASTER" ]; then
+  MASTER="` + m.Name + `-redis-0.` + headless + `"
+fi
+
+cat > ` + redisGeneratedConfigPath + `/redis.conf <<EOF
+port 6379
+dir /data
+requirepass ${REDIS_PASSWORD}
+masterauth ${REDIS_PASSWORD}
+replica-announce-ip ${MY_HOST}
+EOF
+if [ -f ` + redisConfigMountPath + `/` + redisConfigFile + ` ]; then
+  echo "include ` + redisConfigMountPath + `/` + redisConfigFile + `" >> ` + redisGeneratedConfigPath + `/redis.conf
+fi
+if [ "$MASTER" != "$MY_HOST" ]; then
+  echo "replicaof ${MASTER} 6379" >> ` + redisGeneratedConfigPath + `/redis.conf
+fi
+
+cat > ` + redisGeneratedConfigPath + `/sentinel.conf <<EOF
+port ` + strconv.Itoa(redisSentinelPort) + `
+requirepass ${REDIS_PASSWORD}
+sentinel resolve-hostnames yes
+sentinel announce-hostnames yes
+sentinel announce-ip ${MY_HOST}
+sentinel monitor ` + redisSentinelMaster + ` ${MASTER} 6379 ` + quorum + `
+sentinel auth-pass ` + redisSentinelMaster + ` ${REDIS_PASSWORD}
+sentinel sentinel-pass ${REDIS_PASSWORD}
+sentinel down-after-milliseconds ` + redisSentinelMaster + ` 5000
+sentinel failover-timeout ` + redisSentinelMaster + ` 60000
+sentinel parallel-syncs ` + redisSentinelMaster + ` 1
+EOF
+`
+}
+
+// redisStatefulSet returns the Redis StatefulSet used in sentinel mode
+func redisStatefulSet(m *repomanagerv1alpha1.Pulp, podAnnotations map[string]string) *appsv1.StatefulSet {
+	m = effectivePulp(m)
+	replicas := redisReplicas(m)
+	labels := labelsForRedis(m)
+
+	// spread the redis pods across the nodes, so that losing a node does not take down the master and its replicas
+	// (unless a pod anti-affinity is defined in pulp CR)
+	affinity := &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+				Weight: 100,
+				PodAffinityTerm: corev1.PodAffinityTerm{
+					LabelSelector: &metav1.LabelSelector{MatchLabels: labels},
+					TopologyKey:   "kubernetes.io/hostname",
+				},
+			}},
+		},
+	}
+	if m.Spec.Cache.Affinity.NodeAffinity != nil {
+		affinity.NodeAffinity = m.Spec.Cache.Affinity.NodeAffinity
+	}
+	if m.Spec.Cache.Affinity.PodAffinity != nil {
+		affinity.PodAffinity = m.Spec.Cache.Affinity.PodAffinity
+	}
+	if m.Spec.Cache.Affinity.PodAntiAffinity != nil {
+		affinity.PodAntiAffinity = m.Spec.Cache.Affinity.PodAntiAffinity
+	}
+
+	nodeSelector := map[string]string{}
+	if m.Spec.Cache.NodeSelector != nil {
+		nodeSelector = m.Spec.Cache.NodeSelector
+	}
+
+	toleration := []corev1.Toleration{}
+	if m.Spec.Cache.Tolerations != nil {
+		toleration = m.Spec.Cache.Tolerations
+	}
+
+	topologySpreadConstraint := []corev1.TopologySpreadConstraint{}
+	if m.Spec.Cache.TopologySpreadConstraints != nil {
+		topologySpreadConstraint = m.Spec.Cache.TopologySpreadConstraints
+	}
+
+	volumes := []corev1.Volume{{
+		Name:         redisGeneratedConfigVolume,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	}}
+	volumeClaimTemplates := []corev1.PersistentVolumeClaim{}
+
+	// if SC defined, each pod gets its own PVC, otherwise we will mount an emptyDir
+	if _, storageType := controllers.MultiStorageConfigured(m, "Cache"); storageType[0] == controllers.SCNameType {
+		volumeClaimTemplates = append(volumeClaimTemplates, corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: m.Name + "-redis-data",
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceStorage: resource.MustParse("1Gi"),
+					},
+				},
+				StorageClassName: &m.Spec.Cache.RedisStorageClass,
+			},
+		})
+	} else {
+		volumes = append(volumes, corev1.Volume{
+			Name:         m.Name + "-redis-data",
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+	}
+
+	volumeMounts := []corev1.VolumeMount{
+		{Name: m.Name + "-redis-data", MountPath: "/data"},
+		{Name: redisGeneratedConfigVolume, MountPath: redisGeneratedConfigPath},
+	}
+	if m.Spec.Cache.RedisConfig != nil {
+		configVolume, configVolumeMount := redisConfigVolumes(m)
+		volumes = append(volumes, configVolume)
+		volumeMounts = append(volumeMounts, configVolumeMount)
+	}
+
+	readinessProbe, livenessProbe := redisProbes(m, "redis-cli -h 127.0.0.1 -p 6379 ping")
+	sentinelProbe := &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			Exec: &corev1.ExecAction{
+				Command: []string{"/bin/sh", "-c", "redis-cli -h 127.0.0.1 -p " + strconv.Itoa(redisSentinelPort) + " ping"},
+			},
+		},
+		InitialDelaySeconds: 5,
+		PeriodSeconds:       5,
+		TimeoutSeconds:      5,
+		FailureThreshold:    5,
+		SuccessThreshold:    1,
+	}
+
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.Name + "-redis",
+			Namespace: m.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:            &replicas,
+			ServiceName:         m.Name + "-redis-headless",
+			PodManagementPolicy: appsv1.OrderedReadyPodManagement,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      labels,
+					Annotations: podAnnotations,
+				},
+				Spec: corev1.PodSpec{
+					Affinity:                      affinity,
+					NodeSelector:                  nodeSelector,
+					Tolerations:                   toleration,
+					TopologySpreadConstraints:     topologySpreadConstraint,
+					ServiceAccountName:            m.Name,
+					PriorityClassName:             m.Spec.Cache.PriorityClassName,
+					RuntimeClassName:              m.Spec.Cache.RuntimeClassName,
+					HostAliases:                   m.Spec.Cache.HostAliases,
+					TerminationGracePeriodSeconds: terminationGracePeriod(m.Spec.Cache.TerminationGracePeriodSeconds, defaultTerminationGracePeriod),
+					SecurityContext:               componentPodSecurityContext("cache", m.Spec.Cache.PodSecurityContext),
+					InitContainers: append(append([]corev1.Container{}, m.Spec.Cache.InitContainers...), corev1.Container{
+						Name:            "config",
+						Image:           redisImage(m),
+						Command:         []string{"/bin/sh", "-c", redisSentinelConfigScript(m)},
+						Env:             redisEnvVars(m),
+						VolumeMounts:    volumeMounts,
+						SecurityContext: componentSecurityContext("cache", m.Spec.Cache.SecurityContext),
+					}),
+					Containers: append([]corev1.Container{{
+						Name:            "redis",
+						Image:           redisImage(m),
+						ImagePullPolicy: corev1.PullIfNotPresent,
+						Args:            []string{"redis-server", redisGeneratedConfigPath + "/redis.conf"},
+						Env:             redisEnvVars(m),
+						EnvFrom:         m.Spec.Cache.EnvFrom,
+						VolumeMounts:    append(append([]corev1.VolumeMount{}, volumeMounts...), m.Spec.Cache.VolumeMounts...),
+						Ports: []corev1.ContainerPort{{
+							Name:          "redis",
+							ContainerPort: 6379,
+							Protocol:      corev1.ProtocolTCP,
+						}},
+						LivenessProbe:   livenessProbe,
+						StartupProbe:    m.Spec.Cache.StartupProbe,
+						ReadinessProbe:  readinessProbe,
+						Resources:       m.Spec.Cache.RedisResourceRequirements,
+						SecurityContext: componentSecurityContext("cache", m.Spec.Cache.SecurityContext),
+					}, {
+						Name:            "sentinel",
+						Image:           redisImage(m),
+						ImagePullPolicy: corev1.PullIfNotPresent,
+						Args:            []string{"redis-sentinel", redisGeneratedConfigPath + "/sentinel.conf"},
+						Env:             redisEnvVars(m),
+						EnvFrom:         m.Spec.Cache.EnvFrom,
+						VolumeMounts:    volumeMounts,
+						Ports: []corev1.ContainerPort{{
+							Name:          "sentinel",
+							ContainerPort: redisSentinelPort,
+							Protocol:      corev1.ProtocolTCP,
+						}},
+						LivenessProbe:   sentinelProbe,
+						ReadinessProbe:  sentinelProbe,
+						SecurityContext: componentSecurityContext("cache", m.Spec.Cache.SecurityContext),
+					}}, m.Spec.Cache.SidecarContainers...),
+					Volumes: append(volumes, m.Spec.Cache.Volumes...),
+				},
+			},
+			VolumeClaimTemplates: volumeClaimTemplates,
+		},
+	}
+	setPodMetadata(m, &sts.Spec.Template, m.Spec.Cache.PodLabels, m.Spec.Cache.PodAnnotations)
+	return sts
+}
+
+// redisSentinelController reconciles the Redis StatefulSet, the Sentinel service, the PDB
+// and the role of each redis pod
+func (r *PulpReconciler) redisSentinelController(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, podAnnotations map[string]string, log logr.Logger) (ctrl.Result, error) {
+
+	// remove the standalone redis Deployment
+	if res, err := r.deleteRedisObject(ctx, pulp, &appsv1.Deployment{}, pulp.Name+"-redis", log); err != nil || res.Requeue {
+		return res, err
+	}
+
+	// HEADLESS AND SENTINEL SERVICES
+	for _, svc := range []*corev1.Service{redisHeadlessSvc(pulp), redisSentinelSvc(pulp)} {
+		svcFound := &corev1.Service{}
+		err := r.Get(ctx, types.NamespacedName{Name: svc.Name, Namespace: pulp.Namespace}, svcFound)
+		if err != nil && errors.IsNotFound(err) {
+			ctrl.SetControllerReference(pulp, svc, r.Scheme)
+			log.Info("Creating a new Redis Service", "Service.Namespace", svc.Namespace, "Service.Name", svc.Name)
+			if err = r.Create(ctx, svc); err != nil {
+				log.Error(err, "Failed to create new Redis Service", "Service.Namespace", svc.Namespace, "Service.Name", svc.Name)
+				r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to create new Redis Service "+svc.Name)
+				return ctrl.Result{}, err
+			}
+			r.recorder.Event(pulp, corev1.EventTypeNormal, "Created", "Redis Service "+svc.Name+" created")
+			return ctrl.Result{Requeue: true}, nil
+		} else if err != nil {
+			log.Error(err, "Failed to get Redis Service "+svc.Name)
+			return ctrl.Result{}, err
+		}
+
+		if !equality.Semantic.DeepDerivative(svc.Spec, svcFound.Spec) {
+			log.Info("The Redis Service " + svc.Name + " has been modified! Reconciling ...")
+			ctrl.SetControllerReference(pulp, svc, r.Scheme)
+			r.recorder.Event(pulp, corev1.EventTypeNormal, "Updating", "Reconciling Redis Service "+svc.Name)
+			if err = r.Update(ctx, svc); err != nil {
+				log.Error(err, "Error trying to update the Redis Service object ... ")
+				r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to reconcile Redis Service "+svc.Name)
+				return ctrl.Result{}, err
+			}
+			r.recorder.Event(pulp, corev1.EventTypeNormal, "Updated", "Redis Service "+svc.Name+" reconciled")
+			return ctrl.Result{Requeue: true, RequeueAfter: time.Second}, nil
+		}
+	}
+
+	// REDIS STATEFULSET
+	sts := redisStatefulSet(pulp, podAnnotations)
+	stsFound := &appsv1.StatefulSet{}
+	err := r.Get(ctx, types.NamespacedName{Name: sts.Name, Namespace: pulp.Namespace}, stsFound)
+	if err != nil && errors.IsNotFound(err) {
+		ctrl.SetControllerReference(pulp, sts, r.Scheme)
+		log.Info("Creating a new Pulp Redis StatefulSet", "StatefulSet.Namespace", sts.Namespace, "StatefulSet.Name", sts.Name)
+		r.warnEnvOverrides(pulp, "cache", sts.Spec.Template.Spec)
+		if err = r.Create(ctx, sts); err != nil {
+			log.Error(err, "Failed to create new Pulp Redis StatefulSet", "StatefulSet.Namespace", sts.Namespace, "StatefulSet.Name", sts.Name)
+			r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to create new Redis StatefulSet")
+			return ctrl.Result{}, err
+		}
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Created", "Redis StatefulSet created")
+		return ctrl.Result{Requeue: true}, nil
+	} else if err != nil {
+		log.Error(err, "Failed to get Pulp Redis StatefulSet")
+		return ctrl.Result{}, err
+	}
+
+	// volumeClaimTemplates cannot be modified, so they are not reconciled
+	sts.Spec.VolumeClaimTemplates = stsFound.Spec.VolumeClaimTemplates
+	if !equality.Semantic.DeepDerivative(sts.Spec, stsFound.Spec) || podTemplateModified(sts.Spec.Template, stsFound.Spec.Template) {
+		log.Info("The Redis StatefulSet has been modified! Reconciling ...")
+		ctrl.SetControllerReference(pulp, sts, r.Scheme)
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updating", "Reconciling Redis StatefulSet")
+		r.warnEnvOverrides(pulp, "cache", sts.Spec.Template.Spec)
+		if err = r.Update(ctx, sts); err != nil {
+			log.Error(err, "Error trying to update the Redis StatefulSet object ... ")
+			r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to reconcile Redis StatefulSet")
+			return ctrl.Result{}, err
+		}
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updated", "Redis StatefulSet reconciled")
+		return ctrl.Result{Requeue: true, RequeueAfter: time.Second}, nil
+	}
+
+	// REDIS PDB
+	pdb := redisPDB(pulp)
+	pdbFound := &policy.PodDisruptionBudget{}
+	err = r.Get(ctx, types.NamespacedName{Name: pdb.Name, Namespace: pulp.Namespace}, pdbFound)
+	if err != nil && errors.IsNotFound(err) {
+		ctrl.SetControllerReference(pulp, pdb, r.Scheme)
+		log.Info("Creating a new Redis PDB", "PDB.Namespace", pdb.Namespace, "PDB.Name", pdb.Name)
+		if err = r.Create(ctx, pdb); err != nil {
+			log.Error(err, "Failed to create new Redis PDB")
+			r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to create new Redis PDB")
+			return ctrl.Result{}, err
+		}
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Created", "Redis PDB created")
+		return ctrl.Result{Requeue: true}, nil
+	} else if err != nil {
+		log.Error(err, "Failed to get Redis PDB")
+		return ctrl.Result{}, err
+	}
+
+	return r.redisTopologyController(ctx, pulp, log)
+}
+
+// redisTopologyController retrieves the current master from Sentinel, sets the role label
+// of each redis pod (used by <pulp>-redis-svc to select the master) and updates the status
+func (r *PulpReconciler) redisTopologyController(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) (ctrl.Result, error) {
+	podList := &corev1.PodList{}
+	if err := r.List(ctx, podList, client.InNamespace(pulp.Namespace), client.MatchingLabels(labelsForRedis(pulp))); err != nil {
+		log.Error(err, "Failed to list redis pods")
+		return ctrl.Result{}, err
+	}
+
+	// ask the sentinel from any running pod which one is the current master
+	master := ""
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Status.Phase != corev1.PodRunning || !strings.HasPrefix(pod.Name, pulp.Name+"-redis-") {
+			continue
+		}
+		execCmd := []string{"redis-cli", "-p", strconv.Itoa(redisSentinelPort), "sentinel", "get-master-addr-by-name", redisSentinelMaster}
+		output, err := controllers.ContainerExec(r, pod, execCmd, "sentinel", pod.Namespace)
+		if err != nil || len(output) == 0 {
+			continue
+		}
+		master = strings.SplitN(strings.TrimSpace(output), ".", 2)[0]
+		break
+	}
+
+	topology := []repomanagerv1alpha1.CacheNodeStatus{}
+	masterReady := false
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if !strings.HasPrefix(pod.Name, pulp.Name+"-redis-") {
+			continue
+		}
+
+		// keep the current label if the sentinels could not be reached
+		role := pod.Labels[redisRoleLabel]
+		if len(master) > 0 {
+			role = redisReplicaRole
+			if pod.Name == master {
+				role = redisMasterRole
+			}
+		}
+		if len(role) > 0 && pod.Labels[redisRoleLabel] != role {
+			patch := client.MergeFrom(pod.DeepCopy())
+			pod.Labels[redisRoleLabel] = role
+			if err := r.Patch(ctx, pod, patch); err != nil {
+				log.Error(err, "Failed to set redis role label", "Pod.Name", pod.Name)
+				return ctrl.Result{}, err
+			}
+			if role == redisMasterRole {
+				r.recorder.Event(pulp, corev1.EventTypeNormal, "RedisMaster", pod.Name+" is the Redis master")
+			}
+		}
+
+		ready := false
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+				ready = true
+			}
+		}
+		if role == redisMasterRole && ready {
+			masterReady = true
+		}
+		topology = append(topology, repomanagerv1alpha1.CacheNodeStatus{Pod: pod.Name, Role: role, Ready: ready})
+	}
+	sort.Slice(topology, func(i, j int) bool {
+		return databasePodOrdinal(topology[i].Pod) < databasePodOrdinal(topology[j].Pod)
+	})
+
+	if !equality.Semantic.DeepEqual(topology, pulp.Status.CacheTopology) {
+		log.V(1).Info("Updating cache topology status", "topology", topology)
+		pulp.Status.CacheTopology = topology
+		if err := r.Status().Update(ctx, pulp); err != nil {
+			log.Error(err, "Failed to update cache topology status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// a failover is in progress, check again until sentinel promotes a new master
+	if len(topology) > 0 && !masterReady {
+		log.Info("Waiting for a Redis master to be elected ...")
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// removeRedisSentinelResources removes the resources created in sentinel mode
+func (r *PulpReconciler) removeRedisSentinelResources(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) (ctrl.Result, error) {
+	for name, obj := range map[string]client.Object{
+		pulp.Name + "-redis":              &appsv1.StatefulSet{},
+		pulp.Name + "-redis-headless":     &corev1.Service{},
+		pulp.Name + "-redis-sentinel-svc": &corev1.Service{},
+		pulp.Name + "-redis-pdb":          &policy.PodDisruptionBudget{},
+	} {
+		if res, err := r.deleteRedisObject(ctx, pulp, obj, name, log); err != nil || res.Requeue {
+			return res, err
+		}
+	}
+
+	if len(pulp.Status.CacheTopology) > 0 {
+		pulp.Status.CacheTopology = nil
+		if err := r.Status().Update(ctx, pulp); err != nil {
+			log.Error(err, "Failed to update cache topology status")
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// deleteRedisObject removes obj, if it exists, and requeues the request
+func (r *PulpReconciler) deleteRedisObject(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, obj client.Object, name string, log logr.Logger) (ctrl.Result, error) {
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: pulp.Namespace}, obj)
+	if err != nil && errors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		log.Error(err, "Failed to get "+name)
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Removing " + name + " (cache.mode modified)")
+	if err = r.Delete(ctx, obj); err != nil {
+		log.Error(err, "Failed to remove "+name)
+		return ctrl.Result{}, err
+	}
+	r.recorder.Event(pulp, corev1.EventTypeNormal, "Deleted", name+" removed")
+	return ctrl.Result{Requeue: true}, nil
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	crypt_rand "crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// redisCertsMountPath is where the CA bundle used to verify the redis server
+	// certificate is mounted in pulpcore pods
+	redisCertsMountPath = "/etc/pulp/redis-certs"
+
+	// redisTLSMountPath is where the server certificates are mounted in the managed redis pod
+	redisTLSMountPath = "/etc/redis/tls"
+
+	// redisTLSChecksumAnnotation is set in redis pod template with the checksum of the server
+	// certificate so that redis is restarted when the certificate is modified
+	redisTLSChecksumAnnotation = "repo-manager.pulpproject.org/redis-tls-checksum"
+)
+
+// redisTLSSecretName returns the name of the secret with the certificates of the managed redis
+func redisTLSSecretName(m *repomanagerv1alpha1.Pulp) string {
+	return m.Name + "-redis-tls"
+}
+
+// managedRedisTLS returns true if the redis instance deployed by the operator should use TLS
+func managedRedisTLS(m *repomanagerv1alpha1.Pulp) bool {
+	return m.Spec.Cache.Enabled && len(m.Spec.Cache.ExternalCacheSecret) == 0 && m.Spec.Cache.TLS
+}
+
+// externalRedisTLS returns true if the external cache secret enables TLS through
+// REDIS_SSL or a rediss:// REDIS_URL
+func externalRedisTLS(secretData map[string]string) bool {
+	if ssl, err := strconv.ParseBool(secretData["REDIS_SSL"]); err == nil && ssl {
+		return true
+	}
+	return strings.HasPrefix(secretData["REDIS_URL"], "rediss://")
+}
+
+// redisTLSSecret returns a secret with a self-signed CA and a server certificate
+// for the managed redis service
+func redisTLSSecret(m *repomanagerv1alpha1.Pulp) (*corev1.Secret, error) {
+	notBefore := time.Now()
+	notAfter := notBefore.AddDate(10, 0, 0)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), crypt_rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: m.Name + "-redis-ca"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(crypt_rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), crypt_rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	svcName := m.Name + "-redis-svc"
+	serverTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: svcName},
+		DNSNames: []string{
+			svcName,
+			svcName + "." + m.Namespace,
+			svcName + "." + m.Namespace + ".svc",
+			svcName + "." + m.Namespace + ".svc.cluster.local",
+			"localhost",
+		},
+		NotBefore:   notBefore,
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	serverDER, err := x509.CreateCertificate(crypt_rand.Reader, serverTemplate, caTemplate, &serverKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	serverKeyDER, err := x509.MarshalECPrivateKey(serverKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      redisTLSSecretName(m),
+			Namespace: m.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "redis",
+				"app.kubernetes.io/instance":   "redis-" + m.Name,
+				"app.kubernetes.io/component":  "cache",
+				"app.kubernetes.io/part-of":    m.Spec.DeploymentType,
+				"app.kubernetes.io/managed-by": m.Spec.DeploymentType + "-operator",
+			},
+		},
+		Type: corev1.SecretTypeTLS,
+		StringData: map[string]string{
+			"ca.crt":  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})),
+			"tls.crt": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: serverDER})),
+			"tls.key": string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: serverKeyDER})),
+		},
+	}, nil
+}
+
+// redisTLSArgs returns the redis-server arguments to accept only TLS connections
+func redisTLSArgs() []string {
+	return []string{
+		"--port", "0",
+		"--tls-port", "6379",
+		"--tls-cert-file", redisTLSMountPath + "/tls.crt",
+		"--tls-key-file", redisTLSMountPath + "/tls.key",
+		"--tls-ca-cert-file", redisTLSMountPath + "/ca.crt",
+		"--tls-auth-clients", "no",
+	}
+}
+
+// redisCertsVolume returns the volume with the CA bundle used by pulpcore to verify the
+// redis server certificate: REDIS_CA_CERT from the external cache secret or ca.crt from
+// the certificates generated for the managed redis
+func redisCertsVolume(m *repomanagerv1alpha1.Pulp) corev1.Volume {
+	optional := true
+	secretName, key := redisTLSSecretName(m), "ca.crt"
+	if len(m.Spec.Cache.ExternalCacheSecret) > 0 {
+		secretName, key = m.Spec.Cache.ExternalCacheSecret, "REDIS_CA_CERT"
+	}
+	return corev1.Volume{
+		Name: "redis-certs",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: secretName,
+				Items:      []corev1.KeyToPath{{Key: key, Path: "ca.crt"}},
+				Optional:   &optional,
+			},
+		},
+	}
+}
+
+// mountRedisCerts adds the volume with the redis CA bundle to pulpcore pods
+func mountRedisCerts(m *repomanagerv1alpha1.Pulp, volumes []corev1.Volume, volumeMounts []corev1.VolumeMount) ([]corev1.Volume, []corev1.VolumeMount) {
+	if !m.Spec.Cache.Enabled || (len(m.Spec.Cache.ExternalCacheSecret) == 0 && !m.Spec.Cache.TLS) {
+		return volumes, volumeMounts
+	}
+	volumes = append(volumes, redisCertsVolume(m))
+	volumeMounts = append(volumeMounts, corev1.VolumeMount{
+		Name:      "redis-certs",
+		MountPath: redisCertsMountPath,
+		ReadOnly:  true,
+	})
+	return volumes, volumeMounts
+}
+
+// redisTLSEnvVars returns the REDIS_SERVICE_SSL env var for pulpcore pods
+func redisTLSEnvVars(m *repomanagerv1alpha1.Pulp) []corev1.EnvVar {
+	if len(m.Spec.Cache.ExternalCacheSecret) == 0 {
+		if !m.Spec.Cache.TLS {
+			return []corev1.EnvVar{}
+		}
+		return []corev1.EnvVar{{Name: "REDIS_SERVICE_SSL", Value: "true"}}
+	}
+	optional := true
+	return []corev1.EnvVar{{
+		Name: "REDIS_SERVICE_SSL",
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: m.Spec.Cache.ExternalCacheSecret,
+				},
+				Key:      "REDIS_SSL",
+				Optional: &optional,
+			},
+		},
+	}}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"context"
+	"sort"
+
+	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// referencedSecretsIndexKey is the field index used to find the Pulp CRs referencing a secret
+	referencedSecretsIndexKey = ".spec.referencedSecrets"
+
+	// referencedSecretsAnnotation is set in the <pulp>-server secret with the checksum of the
+	// secrets used to render settings.py
+	referencedSecretsAnnotation = "repo-manager.pulpproject.org/referenced-secrets-checksum"
+
+	// settingsChecksumAnnotation is set in pulpcore pod templates with the checksum of settings.py
+	// so that the pods are redeployed when it is modified
+	settingsChecksumAnnotation = "repo-manager.pulpproject.org/settings-checksum"
+)
+
+// referencedSecrets returns the name of the secrets (user provided or the managed redis password and
+// certificates) used to render settings.py
+func referencedSecrets(pulp *repomanagerv1alpha1.Pulp) []string {
+	secrets := []string{}
+
+	// the password and the certificates of the managed redis are also used in settings.py
+	if pulp.Spec.Cache.Enabled && len(pulp.Spec.Cache.ExternalCacheSecret) == 0 {
+		secrets = append(secrets, redisPasswordSecretName(pulp))
+	}
+	if managedRedisTLS(pulp) {
+		secrets = append(secrets, redisTLSSecretName(pulp))
+	}
+
+	for _, secret := range []string{
+		pulp.Spec.Database.ExternalDBSecret,
+		pulp.Spec.Cache.ExternalCacheSecret,
+		pulp.Spec.ObjectStorageAzureSecret,
+		pulp.Spec.ObjectStorageS3Secret,
+		pulp.Spec.SSOSecret,
+	} {
+		if len(secret) > 0 {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+// indexReferencedSecrets is the IndexerFunc for referencedSecretsIndexKey
+func indexReferencedSecrets(obj client.Object) []string {
+	return referencedSecrets(obj.(*repomanagerv1alpha1.Pulp))
+}
+
+// findPulpForSecret returns a reconcile request for each Pulp CR that references the secret
+func (r *PulpReconciler) findPulpForSecret(secret client.Object) []reconcile.Request {
+	pulpList := &repomanagerv1alpha1.PulpList{}
+	if err := r.List(context.TODO(), pulpList, client.InNamespace(secret.GetNamespace()), client.MatchingFields{referencedSecretsIndexKey: secret.GetName()}); err != nil {
+		r.RawLogger.Error(err, "Failed to list Pulp CRs referencing secret "+secret.GetName())
+		return []reconcile.Request{}
+	}
+
+	requests := make([]reconcile.Request, len(pulpList.Items))
+	for i, pulp := range pulpList.Items {
+		requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{Name: pulp.Name, Namespace: pulp.Namespace}}
+	}
+	return requests
+}
+
+// referencedSecretsChecksum returns the checksum of the content of the secrets referenced in pulp CR
+func (r *PulpReconciler) referencedSecretsChecksum(ctx context.Context, pulp *repomanagerv1alpha1.Pulp) string {
+	content := ""
+	for _, secretName := range referencedSecrets(pulp) {
+		content = content + "secret: " + secretName + "\n"
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: secretName, Namespace: pulp.Namespace}, secret); err != nil {
+			continue
+		}
+
+		keys := make([]string, 0, len(secret.Data))
+		for key := range secret.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			content = content + key + ": " + string(secret.Data[key]) + "\n"
+		}
+	}
+	return checksum(content)
+}
+
+// settingsChecksum returns the checksum of settings.py from <pulp>-server secret
+func (r *PulpReconciler) settingsChecksum(ctx context.Context, m *repomanagerv1alpha1.Pulp) (string, error) {
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: m.Name + "-server", Namespace: m.Namespace}, secret); err != nil {
+		return "", err
+	}
+	return checksum(string(secret.Data["settings.py"])), nil
+}
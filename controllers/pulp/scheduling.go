@@ -0,0 +1,59 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// defaultTerminationGracePeriod is the termination grace period of the pods (same as the k8s default)
+	defaultTerminationGracePeriod = int64(30)
+
+	// workerTerminationGracePeriod is the termination grace period of pulp-worker pods.
+	// It is longer than the default one to give the running tasks the time to finish.
+	workerTerminationGracePeriod = int64(600)
+
+	// workerPreStopScript asks the worker to shut down gracefully before the container receives the
+	// SIGTERM (older pulpcore workers abort the running task on SIGTERM). On SIGHUP, pulpcore workers
+	// stop picking up new tasks and exit once the task they are running is finished, so the hook
+	// only needs to wait for the container to stop (PID 1 is the worker or the init process
+	// forwarding it the signal). The hook is interrupted by the termination grace period.
+	workerPreStopScript = `kill -HUP 1
+while kill -0 1 2>/dev/null; do
+  sleep 1
+done`
+)
+
+// terminationGracePeriod returns the termination_grace_period_seconds from pulp CR or the default value
+func terminationGracePeriod(gracePeriod *int64, defaultGracePeriod int64) *int64 {
+	if gracePeriod != nil {
+		return gracePeriod
+	}
+	return &defaultGracePeriod
+}
+
+// workerLifecycle returns the preStop hook of pulp-worker containers
+func workerLifecycle() *corev1.Lifecycle {
+	return &corev1.Lifecycle{
+		PreStop: &corev1.LifecycleHandler{
+			Exec: &corev1.ExecAction{
+				Command: []string{"/bin/sh", "-c", workerPreStopScript},
+			},
+		},
+	}
+}
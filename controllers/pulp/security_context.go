@@ -0,0 +1,75 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"github.com/pulp/pulp-operator/controllers"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// componentUser is the user (and fsGroup) used by default in the pods of each component
+// on non-OpenShift clusters:
+// - pulp image is built to run with user 0, we are enforcing the containers to run as 700
+// - pulp-web image already runs as a non-root user (defined in the image)
+var componentUser = map[string]*int64{
+	"api":     int64Ptr(700),
+	"content": int64Ptr(700),
+	"worker":  int64Ptr(700),
+	"web":     nil,
+}
+
+// componentReadOnlyRootFilesystem defines if the containers of each component run with a
+// read-only root filesystem by default:
+// - pulpcore writes temporary files and python caches outside of the volumes mounted by the operator
+// - nginx writes its temporary files and pid in the root filesystem
+var componentReadOnlyRootFilesystem = map[string]bool{
+	"api":     false,
+	"content": false,
+	"worker":  false,
+	"web":     false,
+}
+
+// imageUserComponents are the components that keep the user from their images when the security
+// contexts are not defined in pulp CR: the postgres and redis images start as root to fix the
+// ownership of the data volumes before dropping privileges, so running them as another user by
+// default could make them unable to use the data from the existing volumes
+var imageUserComponents = map[string]bool{
+	"database": true,
+	"cache":    true,
+}
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
+
+// componentPodSecurityContext returns the pod_security_context from pulp CR or the
+// restricted pod security context used by default in the component pods (if any)
+func componentPodSecurityContext(component string, podSecurityContext *corev1.PodSecurityContext) *corev1.PodSecurityContext {
+	if podSecurityContext != nil || imageUserComponents[component] {
+		return podSecurityContext
+	}
+	return controllers.RestrictedPodSecurityContext(componentUser[component])
+}
+
+// componentSecurityContext returns the security_context from pulp CR or the
+// restricted security context used by default in the component containers (if any)
+func componentSecurityContext(component string, securityContext *corev1.SecurityContext) *corev1.SecurityContext {
+	if securityContext != nil || imageUserComponents[component] {
+		return securityContext
+	}
+	return controllers.RestrictedSecurityContext(componentReadOnlyRootFilesystem[component])
+}
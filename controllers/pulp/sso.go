@@ -3,6 +3,7 @@ package pulp
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
@@ -53,8 +54,13 @@ func (r *PulpReconciler) ssoConfig(ctx context.Context, pulp *repomanagerv1alpha
 		settings[key] = value
 	}
 
-	// Inject SSO settings into pulp_settings
+	// Inject SSO settings into pulp_settings (sorted, to render the same settings.py on every reconciliation)
+	keys := make([]string, 0, len(settings))
 	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
 		*pulpSettings = *pulpSettings + fmt.Sprintf("%v = \"%v\"\n", strings.ToUpper(key), settings[key])
 	}
 
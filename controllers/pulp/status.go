@@ -79,6 +79,20 @@ func (r *PulpReconciler) pulpStatus(ctx context.Context, pulp *repomanagerv1alph
 		return ctrl.Result{Requeue: true, RequeueAfter: 10 * time.Second}, nil
 	}
 
+	// check if Worker pods (including the ones from the worker pools) are READY
+	workerConditionType := cases.Title(language.English, cases.Compact).String(pulp.Spec.DeploymentType) + "-Worker-Ready"
+	if notReady, err := r.workerDeploymentsNotReady(ctx, pulp); err != nil {
+		log.Error(err, "Failed to get Pulp Worker Deployments")
+		return ctrl.Result{Requeue: true, RequeueAfter: 10 * time.Second}, nil
+	} else if len(notReady) > 0 {
+		log.Info(pulp.Spec.DeploymentType + " workers not ready yet ...")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, workerConditionType, "UpdatingWorkerDeployment", "Worker deployments not ready yet: "+strings.Join(notReady, ", "))
+		return ctrl.Result{Requeue: true, RequeueAfter: 30 * time.Second}, nil
+	} else if v1.IsStatusConditionFalse(pulp.Status.Conditions, workerConditionType) {
+		r.updateStatus(ctx, pulp, metav1.ConditionTrue, workerConditionType, "WorkerTasksFinished", "All Worker tasks ran successfully")
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "WorkerReady", "All Worker tasks ran successfully")
+	}
+
 	// check web pods are READY
 	if strings.ToLower(pulp.Spec.IngressType) != "route" {
 		webConditionType := cases.Title(language.English, cases.Compact).String(pulp.Spec.DeploymentType) + "-Web-Ready"
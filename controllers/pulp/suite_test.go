@@ -18,14 +18,23 @@ package pulp_test
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	routev1 "github.com/openshift/api/route/v1"
 	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
 	"github.com/pulp/pulp-operator/controllers/pulp"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -45,8 +54,111 @@ var (
 	testEnv   *envtest.Environment
 	ctx       context.Context
 	cancel    context.CancelFunc
+
+	// fakePulpAPI is a fake Pulp API server used by the worker autoscaling
+	fakePulpAPI      *httptest.Server
+	fakePulpTasks    = map[string]int32{"waiting": 0, "running": 0}
+	fakePulpTasksMux sync.Mutex
 )
 
+// setFakePulpTasks defines the number of tasks returned by the fake Pulp API
+func setFakePulpTasks(waiting, running int32) {
+	fakePulpTasksMux.Lock()
+	defer fakePulpTasksMux.Unlock()
+	fakePulpTasks["waiting"] = waiting
+	fakePulpTasks["running"] = running
+}
+
+// fakeMigrationJobs emulates the job controller (not available in envtest) by marking the
+// migration jobs as complete, or as failed if the image version is "failed-migration"
+func fakeMigrationJobs(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Millisecond * 250):
+		}
+
+		jobList := &batchv1.JobList{}
+		if err := k8sClient.List(ctx, jobList, client.MatchingLabels{"app.kubernetes.io/component": "migration"}); err != nil {
+			continue
+		}
+		for i := range jobList.Items {
+			job := &jobList.Items[i]
+			if len(job.Status.Conditions) > 0 {
+				continue
+			}
+			condition := batchv1.JobCondition{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}
+			if strings.HasSuffix(job.Spec.Template.Spec.Containers[0].Image, ":failed-migration") {
+				condition.Type = batchv1.JobFailed
+				job.Status.Failed = 1
+			} else {
+				job.Status.Succeeded = 1
+			}
+			job.Status.Conditions = []batchv1.JobCondition{condition}
+			k8sClient.Status().Update(ctx, job)
+		}
+	}
+}
+
+// fakeDeploymentsRollout emulates the deployment controller (not available in envtest) by updating
+// the status of the deployments as if all the pods were available, or as if the rollout exceeded
+// its progress deadline if the image version is "failed-rollout"
+func fakeDeploymentsRollout(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Millisecond * 250):
+		}
+
+		deploymentList := &appsv1.DeploymentList{}
+		if err := k8sClient.List(ctx, deploymentList); err != nil {
+			continue
+		}
+		for i := range deploymentList.Items {
+			deployment := &deploymentList.Items[i]
+			if deployment.Status.ObservedGeneration == deployment.Generation {
+				continue
+			}
+			replicas := int32(1)
+			if deployment.Spec.Replicas != nil {
+				replicas = *deployment.Spec.Replicas
+			}
+			status := appsv1.DeploymentStatus{
+				ObservedGeneration: deployment.Generation,
+				Replicas:           replicas,
+				UpdatedReplicas:    replicas,
+				ReadyReplicas:      replicas,
+				AvailableReplicas:  replicas,
+			}
+			for _, container := range deployment.Spec.Template.Spec.Containers {
+				if strings.HasSuffix(container.Image, ":failed-rollout") {
+					status.ReadyReplicas, status.AvailableReplicas, status.UnavailableReplicas = 0, 0, replicas
+					status.Conditions = []appsv1.DeploymentCondition{{
+						Type:   appsv1.DeploymentProgressing,
+						Status: corev1.ConditionFalse,
+						Reason: "ProgressDeadlineExceeded",
+					}}
+				}
+			}
+			deployment.Status = status
+			k8sClient.Status().Update(ctx, deployment)
+		}
+	}
+}
+
+// fakePulpTasksHandler answers the requests to Pulp tasks API with the number of tasks in the requested state
+func fakePulpTasksHandler(w http.ResponseWriter, req *http.Request) {
+	if user, _, ok := req.BasicAuth(); !ok || user != "pulp-operator-tasks-viewer" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	fakePulpTasksMux.Lock()
+	defer fakePulpTasksMux.Unlock()
+	fmt.Fprintf(w, `{"count": %d, "next": null, "previous": null, "results": []}`, fakePulpTasks[req.URL.Query().Get("state")])
+}
+
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Controller Suite")
@@ -89,13 +201,23 @@ var _ = BeforeSuite(func() {
 	err = routev1.AddToScheme(k8sManager.GetScheme())
 	Expect(err).NotTo(HaveOccurred())
 
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pulp/api/v3/tasks/", fakePulpTasksHandler)
+	fakePulpAPI = httptest.NewServer(mux)
+
 	err = (&pulp.PulpReconciler{
 		Client:    k8sManager.GetClient(),
 		RawLogger: k8sManager.GetLogger(),
 		Scheme:    k8sManager.GetScheme(),
+		PulpAPIURL: func(*repomanagerv1alpha1.Pulp) string {
+			return fakePulpAPI.URL
+		},
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
+	go fakeMigrationJobs(ctx)
+	go fakeDeploymentsRollout(ctx)
+
 	go func() {
 		defer GinkgoRecover()
 		err = k8sManager.Start(ctx)
@@ -106,6 +228,9 @@ var _ = BeforeSuite(func() {
 
 var _ = AfterSuite(func() {
 	cancel()
+	if fakePulpAPI != nil {
+		fakePulpAPI.Close()
+	}
 	By("tearing down the test environment")
 	err := testEnv.Stop()
 	Expect(err).NotTo(HaveOccurred())
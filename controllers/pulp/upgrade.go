@@ -0,0 +1,379 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/go-logr/logr"
+	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// steps of the upgrade of pulpcore image
+const (
+	upgradePhaseDraining       = "Draining"
+	upgradePhaseMigrating      = "Migrating"
+	upgradePhaseRollingAPI     = "RollingAPI"
+	upgradePhaseRollingContent = "RollingContent"
+	upgradePhaseRollingWorkers = "RollingWorkers"
+	upgradePhaseCompleted      = "Completed"
+	upgradePhaseRollingBack    = "RollingBack"
+	upgradePhaseFailed         = "Failed"
+)
+
+// upgradeInProgress returns true if an upgrade started and did not complete nor fail yet
+func upgradeInProgress(upgrade *repomanagerv1alpha1.UpgradeStatus) bool {
+	return upgrade != nil && upgrade.Phase != upgradePhaseCompleted && upgrade.Phase != upgradePhaseFailed
+}
+
+// upgradeTarget returns true if the upgrade is to the pulpcore image and component images from pulp CR
+func upgradeTarget(m *repomanagerv1alpha1.Pulp, upgrade *repomanagerv1alpha1.UpgradeStatus) bool {
+	return upgrade.ToImage == pulpcoreImage(m) && reflect.DeepEqual(upgrade.ToComponentImages, componentImages(m))
+}
+
+// upgradeRolledBack returns true if the upgrade to the images from pulp CR failed and was rolled back
+func upgradeRolledBack(m *repomanagerv1alpha1.Pulp) bool {
+	upgrade := m.Status.Upgrade
+	return upgrade != nil && upgrade.Phase == upgradePhaseFailed && upgradeTarget(m, upgrade)
+}
+
+// deployedPulpcoreImages returns the pulpcore image and the component images of pulpcore deployments:
+// the images from pulp CR or, if the upgrade to these images failed and was rolled back, the images
+// from before the upgrade
+func deployedPulpcoreImages(m *repomanagerv1alpha1.Pulp) (string, map[string]string) {
+	if upgradeRolledBack(m) {
+		return m.Status.Upgrade.FromImage, m.Status.Upgrade.FromComponentImages
+	}
+	return pulpcoreImage(m), componentImages(m)
+}
+
+// deployedComponentImage returns the image of the deployment of a pulpcore component (api, content or worker)
+func deployedComponentImage(m *repomanagerv1alpha1.Pulp, component string) string {
+	image, componentImages := deployedPulpcoreImages(m)
+	return pulpcoreComponentImage(component, image, componentImages)
+}
+
+// pulpcoreDeploymentImages returns the pulpcore image and the component images pulp-api deployment was deployed with
+func pulpcoreDeploymentImages(apiDeployment *appsv1.Deployment) (string, map[string]string) {
+	componentImages := decodeComponentImages(apiDeployment.Annotations[componentImagesAnnotation])
+	if image, found := apiDeployment.Annotations[pulpcoreImageAnnotation]; found {
+		return image, componentImages
+	}
+
+	// deployments created by previous versions of the operator do not have the annotation
+	for _, container := range apiDeployment.Spec.Template.Spec.Containers {
+		if container.Name == "api" {
+			return container.Image, componentImages
+		}
+	}
+	return "", componentImages
+}
+
+// deploymentRollout returns if the last modification of the deployment was rolled out
+// and if the rollout failed (the deployment exceeded its progress deadline)
+func deploymentRollout(deployment *appsv1.Deployment) (done bool, failed bool) {
+	for _, c := range deployment.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Status == corev1.ConditionFalse && c.Reason == "ProgressDeadlineExceeded" {
+			return false, true
+		}
+	}
+
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	return deployment.Status.ObservedGeneration >= deployment.Generation &&
+		deployment.Status.Replicas == replicas &&
+		deployment.Status.UpdatedReplicas == replicas &&
+		deployment.Status.AvailableReplicas == replicas, false
+}
+
+// rolloutPulpcoreDeployment sets the pulpcore image (and the number of replicas, if provided) of a pulpcore
+// deployment and returns if the rollout finished or failed. The image defined for the component in
+// componentImages (if any) takes precedence over the pulpcore image.
+func (r *PulpReconciler) rolloutPulpcoreDeployment(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, component, image string, componentImages map[string]string, replicas *int32) (done bool, failed bool, err error) {
+	return r.rolloutDeployment(ctx, pulp, pulp.Name+"-"+component, component, image, componentImages, replicas)
+}
+
+// rolloutWorkerPools sets the pulpcore image of the worker pools deployments and scales them to 0 (if drain
+// is true) or to the number of replicas of each pool. It returns if the rollout of all pools finished or if any failed.
+func (r *PulpReconciler) rolloutWorkerPools(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, image string, componentImages map[string]string, drain bool) (done bool, failed bool, err error) {
+	done = true
+	for _, pool := range pulp.Spec.WorkerPools {
+		replicas := pool.Replicas
+		if drain {
+			replicas = 0
+		}
+		poolDone, poolFailed, err := r.rolloutDeployment(ctx, pulp, workerPoolDeploymentName(pulp, pool.Name), "worker", image, componentImages, &replicas)
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return false, false, err
+		}
+		done = done && poolDone
+		failed = failed || poolFailed
+	}
+	return done, failed, nil
+}
+
+// rolloutDeployment sets the pulpcore image (and the number of replicas, if provided) of the deployment
+// of a pulpcore component and returns if the rollout finished or failed
+func (r *PulpReconciler) rolloutDeployment(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, name, component, image string, componentImages map[string]string, replicas *int32) (done bool, failed bool, err error) {
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: pulp.Namespace}, deployment); err != nil {
+		return false, false, err
+	}
+
+	modified := false
+	componentImage := pulpcoreComponentImage(component, image, componentImages)
+	for i := range deployment.Spec.Template.Spec.Containers {
+		if container := &deployment.Spec.Template.Spec.Containers[i]; container.Name == component && container.Image != componentImage {
+			container.Image = componentImage
+			modified = true
+		}
+	}
+	if component == "api" && (deployment.Annotations[pulpcoreImageAnnotation] != image ||
+		deployment.Annotations[componentImagesAnnotation] != encodeComponentImages(componentImages)) {
+		if deployment.Annotations == nil {
+			deployment.Annotations = map[string]string{}
+		}
+		deployment.Annotations[pulpcoreImageAnnotation] = image
+		deployment.Annotations[componentImagesAnnotation] = encodeComponentImages(componentImages)
+		modified = true
+	}
+	if replicas != nil && (deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != *replicas) {
+		deployment.Spec.Replicas = replicas
+		modified = true
+	}
+	if modified {
+		return false, false, r.Update(ctx, deployment)
+	}
+
+	done, failed = deploymentRollout(deployment)
+	return done, failed, nil
+}
+
+// setUpgradePhase moves the upgrade to the next step and updates pulp CR status
+func (r *PulpReconciler) setUpgradePhase(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger, phase, message string) (ctrl.Result, error) {
+	conditionType := cases.Title(language.English, cases.Compact).String(pulp.Spec.DeploymentType) + "-Upgrade-Complete"
+	upgrade := pulp.Status.Upgrade
+	upgrade.Phase = phase
+	upgrade.Message = message
+	log.Info("Upgrade "+phase, "from", pulpcoreImagesDescription(upgrade.FromImage, upgrade.FromComponentImages), "to", pulpcoreImagesDescription(upgrade.ToImage, upgrade.ToComponentImages), "message", message)
+
+	switch phase {
+	case upgradePhaseCompleted:
+		now := metav1.Now()
+		upgrade.CompletionTime = &now
+		r.updateStatus(ctx, pulp, metav1.ConditionTrue, conditionType, "UpgradeCompleted", message)
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "UpgradeCompleted", message)
+	case upgradePhaseFailed:
+		now := metav1.Now()
+		upgrade.CompletionTime = &now
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "UpgradeFailed", message)
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "UpgradeFailed", message)
+	case upgradePhaseRollingBack:
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "RollingBack", message)
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "RollingBack", message)
+	default:
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, phase, message)
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Upgrading", message)
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// upgradeController orchestrates the upgrade of pulpcore image (and of the images defined for the
+// components): it stops the workers, runs the database migrations and rolls out api, content and
+// workers (in this order) waiting for each of them to be ready before moving to the next one.
+// If any step fails, the deployments are rolled back to the previous images.
+func (r *PulpReconciler) upgradeController(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) (ctrl.Result, error) {
+	conditionType := cases.Title(language.English, cases.Compact).String(pulp.Spec.DeploymentType) + "-Upgrade-Complete"
+
+	// there is nothing to upgrade in a new installation
+	apiDeployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-api", Namespace: pulp.Namespace}, apiDeployment); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get Pulp API Deployment")
+		return ctrl.Result{}, err
+	}
+
+	image, images := pulpcoreImage(pulp), componentImages(pulp)
+	upgrade := pulp.Status.Upgrade
+
+	if !upgradeInProgress(upgrade) {
+		currentImage, currentImages := pulpcoreDeploymentImages(apiDeployment)
+		if currentImage == image && reflect.DeepEqual(currentImages, images) {
+			return ctrl.Result{}, nil
+		}
+
+		// a failed upgrade is only retried after one of its migration jobs is removed
+		if upgradeRolledBack(pulp) {
+			retry := false
+			for _, jobImage := range pulpcoreImages(image, images) {
+				err := r.Get(ctx, types.NamespacedName{Name: r.migrationJob(pulp, jobImage).Name, Namespace: pulp.Namespace}, &batchv1.Job{})
+				if errors.IsNotFound(err) {
+					retry = true
+				} else if err != nil {
+					log.Error(err, "Failed to get migration Job")
+					return ctrl.Result{}, err
+				}
+			}
+			if !retry {
+				return ctrl.Result{}, nil
+			}
+		}
+
+		workerReplicas := *effectivePulp(pulp).Spec.Worker.Replicas
+		workerDeployment := &appsv1.Deployment{}
+		if err := r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-worker", Namespace: pulp.Namespace}, workerDeployment); err == nil && workerDeployment.Spec.Replicas != nil {
+			workerReplicas = *workerDeployment.Spec.Replicas
+		}
+
+		now := metav1.Now()
+		pulp.Status.Upgrade = &repomanagerv1alpha1.UpgradeStatus{
+			Phase:               upgradePhaseDraining,
+			FromImage:           currentImage,
+			ToImage:             image,
+			FromComponentImages: currentImages,
+			ToComponentImages:   images,
+			WorkerReplicas:      workerReplicas,
+			StartTime:           &now,
+			Message:             "Waiting for the workers to finish the running tasks",
+		}
+		from, to := pulpcoreImagesDescription(currentImage, currentImages), pulpcoreImagesDescription(image, images)
+		log.Info("Upgrading pulpcore", "from", from, "to", to)
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "UpgradeStarted", "Upgrading from "+from+" to "+to)
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "UpgradeStarted", "Upgrading from "+from+" to "+to)
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// the images were modified in pulp CR during the upgrade
+	if upgrade.Phase != upgradePhaseRollingBack && !upgradeTarget(pulp, upgrade) {
+		return r.setUpgradePhase(ctx, pulp, log, upgradePhaseRollingBack, "Image modified to "+pulpcoreImagesDescription(image, images)+
+			" during the upgrade to "+pulpcoreImagesDescription(upgrade.ToImage, upgrade.ToComponentImages))
+	}
+
+	requeue := ctrl.Result{RequeueAfter: 5 * time.Second}
+	switch upgrade.Phase {
+
+	// scaling the workers down stops the pickup of new tasks and the workers
+	// finish the running tasks before their pods are terminated
+	case upgradePhaseDraining:
+		noWorkers := int32(0)
+		done, _, err := r.rolloutPulpcoreDeployment(ctx, pulp, "worker", upgrade.FromImage, upgrade.FromComponentImages, &noWorkers)
+		if err != nil {
+			log.Error(err, "Failed to scale down Pulp Worker Deployment")
+			return ctrl.Result{}, err
+		}
+		poolsDone, _, err := r.rolloutWorkerPools(ctx, pulp, upgrade.FromImage, upgrade.FromComponentImages, true)
+		if err != nil {
+			log.Error(err, "Failed to scale down Pulp Worker Pool Deployments")
+			return ctrl.Result{}, err
+		}
+		if !done || !poolsDone {
+			return requeue, nil
+		}
+		return r.setUpgradePhase(ctx, pulp, log, upgradePhaseMigrating, "Running database migrations")
+
+	case upgradePhaseMigrating:
+		res, err := r.migrationController(ctx, pulp, log)
+		migrationCondition := v1.FindStatusCondition(pulp.Status.Conditions, cases.Title(language.English, cases.Compact).String(pulp.Spec.DeploymentType)+"-Migration-Complete")
+		if migrationCondition != nil && migrationCondition.Reason == "MigrationFailed" {
+			return r.setUpgradePhase(ctx, pulp, log, upgradePhaseRollingBack, "Database migrations failed")
+		}
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if res.Requeue || res.RequeueAfter > 0 {
+			return res, nil
+		}
+		return r.setUpgradePhase(ctx, pulp, log, upgradePhaseRollingAPI, "Rolling out "+pulp.Name+"-api deployment")
+
+	case upgradePhaseRollingAPI, upgradePhaseRollingContent, upgradePhaseRollingWorkers:
+		component, next, message := "api", upgradePhaseRollingContent, "Rolling out "+pulp.Name+"-content deployment"
+		var replicas *int32
+		if upgrade.Phase == upgradePhaseRollingContent {
+			component, next, message = "content", upgradePhaseRollingWorkers, "Rolling out "+pulp.Name+"-worker deployment"
+		} else if upgrade.Phase == upgradePhaseRollingWorkers {
+			component, next, message = "worker", upgradePhaseCompleted, "Upgraded from "+pulpcoreImagesDescription(upgrade.FromImage, upgrade.FromComponentImages)+
+				" to "+pulpcoreImagesDescription(upgrade.ToImage, upgrade.ToComponentImages)
+			replicas = &upgrade.WorkerReplicas
+		}
+
+		done, failed, err := r.rolloutPulpcoreDeployment(ctx, pulp, component, upgrade.ToImage, upgrade.ToComponentImages, replicas)
+		if err != nil {
+			log.Error(err, "Failed to roll out "+pulp.Name+"-"+component+" deployment")
+			return ctrl.Result{}, err
+		}
+		if component == "worker" {
+			poolsDone, poolsFailed, err := r.rolloutWorkerPools(ctx, pulp, upgrade.ToImage, upgrade.ToComponentImages, false)
+			if err != nil {
+				log.Error(err, "Failed to roll out Pulp Worker Pool Deployments")
+				return ctrl.Result{}, err
+			}
+			done, failed = done && poolsDone, failed || poolsFailed
+		}
+		if failed {
+			return r.setUpgradePhase(ctx, pulp, log, upgradePhaseRollingBack, pulp.Name+"-"+component+" deployment exceeded its progress deadline")
+		}
+		if !done {
+			return requeue, nil
+		}
+		return r.setUpgradePhase(ctx, pulp, log, next, message)
+
+	case upgradePhaseRollingBack:
+		rolledBack := true
+		for _, component := range []string{"api", "content", "worker"} {
+			var replicas *int32
+			if component == "worker" {
+				replicas = &upgrade.WorkerReplicas
+			}
+			done, failed, err := r.rolloutPulpcoreDeployment(ctx, pulp, component, upgrade.FromImage, upgrade.FromComponentImages, replicas)
+			if err != nil && !errors.IsNotFound(err) {
+				log.Error(err, "Failed to roll back "+pulp.Name+"-"+component+" deployment")
+				return ctrl.Result{}, err
+			}
+			rolledBack = rolledBack && (done || failed || errors.IsNotFound(err))
+		}
+		done, failed, err := r.rolloutWorkerPools(ctx, pulp, upgrade.FromImage, upgrade.FromComponentImages, false)
+		if err != nil {
+			log.Error(err, "Failed to roll back Pulp Worker Pool Deployments")
+			return ctrl.Result{}, err
+		}
+		rolledBack = rolledBack && (done || failed)
+		if !rolledBack {
+			return requeue, nil
+		}
+		return r.setUpgradePhase(ctx, pulp, log, upgradePhaseFailed, "Rolled back to "+pulpcoreImagesDescription(upgrade.FromImage, upgrade.FromComponentImages)+". "+upgrade.Message)
+	}
+
+	return ctrl.Result{}, nil
+}
@@ -12,14 +12,18 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	"github.com/pulp/pulp-operator/controllers"
 	"golang.org/x/crypto/openpgp"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -29,8 +33,40 @@ import (
 
 const (
 	caConfigMapName = "user-ca-bundle"
+
+	// apiAvailabilityTTL is how long the availability of an optional API is cached.
+	// The APIs (like the VerticalPodAutoscaler or ServiceMonitor CRDs) can be installed
+	// or removed while the operator is running, so they are checked again after it.
+	apiAvailabilityTTL = 5 * time.Minute
 )
 
+// apiAvailability is the cached result of the discovery of an API
+type apiAvailability struct {
+	available bool
+	checkedAt time.Time
+}
+
+// isAPIAvailable returns true if the groupVersion is served by the cluster.
+// The result is cached for apiAvailabilityTTL to avoid querying the discovery API
+// in every reconciliation (the errors are not cached).
+func (r *PulpReconciler) isAPIAvailable(groupVersion string) (bool, error) {
+	r.apisLock.Lock()
+	defer r.apisLock.Unlock()
+	if cached, found := r.apis[groupVersion]; found && time.Since(cached.checkedAt) < apiAvailabilityTTL {
+		return cached.available, nil
+	}
+
+	available, err := controllers.IsAPIAvailable(groupVersion)
+	if err != nil {
+		return false, err
+	}
+	if r.apis == nil {
+		r.apis = map[string]apiAvailability{}
+	}
+	r.apis[groupVersion] = apiAvailability{available: available, checkedAt: time.Now()}
+	return available, nil
+}
+
 // Generate a random string with length pwdSize
 func createPwd(pwdSize int) string {
 	const chars = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -124,8 +160,17 @@ func addCustomPulpSettings(pulp *repomanagerv1alpha1.Pulp, current_settings stri
 	var settingsJson map[string]interface{}
 	json.Unmarshal(settings, &settingsJson)
 
+	// the settings are rendered in the same order on every reconciliation, so that
+	// settings.py is only modified (and the pods restarted) when pulp CR changes
+	keys := make([]string, 0, len(settingsJson))
+	for k := range settingsJson {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
 	var convertedSettings string
-	for k, v := range settingsJson {
+	for _, k := range keys {
+		v := settingsJson[k]
 		if strings.Contains(current_settings, strings.ToUpper(k)) {
 			lines := strings.Split(current_settings, strings.ToUpper(k))
 			current_settings = lines[0] + strings.Join(strings.Split(lines[1], "\n")[1:], "\n")
@@ -245,3 +290,97 @@ func mountCASpec(pulp *repomanagerv1alpha1.Pulp, volumes []corev1.Volume, volume
 
 	return volumes, volumeMounts
 }
+
+// mountExternalDBCerts adds the volume with the client certificates (POSTGRES_SSLROOTCERT,
+// POSTGRES_SSLCERT and POSTGRES_SSLKEY keys) from the external database secret
+func mountExternalDBCerts(pulp *repomanagerv1alpha1.Pulp, volumes []corev1.Volume, volumeMounts []corev1.VolumeMount) ([]corev1.Volume, []corev1.VolumeMount) {
+	if len(pulp.Spec.Database.ExternalDBSecret) > 0 {
+		volumes = append(volumes, controllers.PostgresCertsVolume(pulp.Spec.Database.ExternalDBSecret))
+		volumeMounts = append(volumeMounts, controllers.PostgresCertsVolumeMount())
+	}
+	return volumes, volumeMounts
+}
+
+// operatorAnnotationPrefix is the prefix of the annotations set by the operator in the pod templates
+const operatorAnnotationPrefix = "repo-manager.pulpproject.org/"
+
+// containerFields is the subset of a container compared by podTemplateModified
+type containerFields struct {
+	Name         string
+	Args         []string
+	Env          []string
+	EnvFrom      []corev1.EnvFromSource
+	VolumeMounts []corev1.VolumeMount
+	Probes       [3]bool
+}
+
+// podTemplateFields is the subset of a pod template compared by podTemplateModified
+type podTemplateFields struct {
+	Annotations               map[string]string
+	Volumes                   []string
+	InitContainers            []containerFields
+	Containers                []containerFields
+	PriorityClassName         string
+	RuntimeClassName          *string
+	HostAliases               []corev1.HostAlias
+	NodeSelector              map[string]string
+	Tolerations               []corev1.Toleration
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint
+	Affinity                  corev1.Affinity
+}
+
+// operatorPodTemplateFields returns the fields of a pod template defined by the operator (or from pulp CR).
+// Only the names (or the presence) are kept for the fields with values defaulted by the API server.
+func operatorPodTemplateFields(template corev1.PodTemplateSpec) podTemplateFields {
+	fields := podTemplateFields{
+		Annotations:               map[string]string{},
+		PriorityClassName:         template.Spec.PriorityClassName,
+		RuntimeClassName:          template.Spec.RuntimeClassName,
+		HostAliases:               template.Spec.HostAliases,
+		NodeSelector:              template.Spec.NodeSelector,
+		Tolerations:               template.Spec.Tolerations,
+		TopologySpreadConstraints: template.Spec.TopologySpreadConstraints,
+	}
+	for key, value := range template.Annotations {
+		if strings.HasPrefix(key, operatorAnnotationPrefix) {
+			fields.Annotations[key] = value
+		}
+	}
+	for _, volume := range template.Spec.Volumes {
+		fields.Volumes = append(fields.Volumes, volume.Name)
+	}
+	if template.Spec.Affinity != nil {
+		fields.Affinity = *template.Spec.Affinity
+	}
+
+	containers := func(containers []corev1.Container) []containerFields {
+		result := []containerFields{}
+		for _, container := range containers {
+			envNames := []string{}
+			for _, env := range container.Env {
+				envNames = append(envNames, env.Name)
+			}
+			result = append(result, containerFields{
+				Name:         container.Name,
+				Args:         container.Args,
+				Env:          envNames,
+				EnvFrom:      container.EnvFrom,
+				VolumeMounts: container.VolumeMounts,
+				Probes:       [3]bool{container.StartupProbe != nil, container.LivenessProbe != nil, container.ReadinessProbe != nil},
+			})
+		}
+		return result
+	}
+	fields.InitContainers = containers(template.Spec.InitContainers)
+	fields.Containers = containers(template.Spec.Containers)
+	return fields
+}
+
+// podTemplateModified returns true if the fields of found pod template defined by the operator are different
+// from expected. DeepDerivative ignores the fields unset in expected and the extra elements of found slices and
+// maps, so without this check removing a field (like volumes, env, init_containers, probes, tolerations or
+// pod_labels) from pulp CR, or disabling a feature that adds a container, volume or argument, would not
+// update the pods.
+func podTemplateModified(expected, found corev1.PodTemplateSpec) bool {
+	return !equality.Semantic.DeepEqual(operatorPodTemplateFields(expected), operatorPodTemplateFields(found))
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// validateVolumes returns an error if the volumes or volume_mounts of a component use a
+// volume name or a mount path already defined (by the operator or by pulp CR) in the pod.
+// Since the volumes from pulp CR are added after the ones defined by the operator, the
+// duplicated entries are always the ones from pulp CR.
+func validateVolumes(component string, podSpec corev1.PodSpec) error {
+	volumeNames := map[string]bool{}
+	for _, volume := range podSpec.Volumes {
+		if volumeNames[volume.Name] {
+			return fmt.Errorf("%v.volumes: the volume name %v is reserved or already in use", component, volume.Name)
+		}
+		volumeNames[volume.Name] = true
+	}
+
+	for _, container := range podSpec.Containers {
+		mountPaths := map[string]bool{}
+		for _, volumeMount := range container.VolumeMounts {
+			if mountPaths[volumeMount.MountPath] {
+				return fmt.Errorf("%v.volume_mounts: the mount path %v is reserved or already in use", component, volumeMount.MountPath)
+			}
+			mountPaths[volumeMount.MountPath] = true
+		}
+	}
+	return nil
+}
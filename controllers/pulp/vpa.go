@@ -0,0 +1,320 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const vpaGroupVersion = "autoscaling.k8s.io/v1"
+
+// vpaRecommendationsInterval is the interval to refresh the recommendations of the
+// VerticalPodAutoscalers in pulp CR status, because they are not watched by the operator
+const vpaRecommendationsInterval = 5 * time.Minute
+
+// vpaTarget is the workload (and its container) of a component that can be autoscaled by a VerticalPodAutoscaler
+type vpaTarget struct {
+	component, kind, name, container string
+	vpa                              *repomanagerv1alpha1.VPA
+}
+
+// vpaTargets returns the workloads of the components that can be autoscaled by a VerticalPodAutoscaler
+func vpaTargets(pulp *repomanagerv1alpha1.Pulp) []vpaTarget {
+	targets := []vpaTarget{
+		{"api", "Deployment", pulp.Name + "-api", "api", pulp.Spec.Api.VPA},
+		{"content", "Deployment", pulp.Name + "-content", "content", pulp.Spec.Content.VPA},
+		{"worker", "Deployment", pulp.Name + "-worker", "worker", pulp.Spec.Worker.VPA},
+		{"database", "StatefulSet", pulp.Name + "-database", "postgres", pulp.Spec.Database.VPA},
+		{"cache", "Deployment", pulp.Name + "-redis", "redis", pulp.Spec.Cache.VPA},
+	}
+
+	// the database and cache are not deployed by the operator when external ones are used
+	if len(pulp.Spec.Database.ExternalDBSecret) > 0 {
+		targets[3].vpa = nil
+	}
+	if len(pulp.Spec.Cache.ExternalCacheSecret) > 0 || !pulp.Spec.Cache.Enabled {
+		targets[4].vpa = nil
+	}
+	if redisSentinelMode(pulp) {
+		targets[4].kind = "StatefulSet"
+	}
+	return targets
+}
+
+// vpaDefined returns true if vpa is defined for any component
+func vpaDefined(pulp *repomanagerv1alpha1.Pulp) bool {
+	for _, target := range vpaTargets(pulp) {
+		if target.vpa != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceListObject converts a ResourceList into the map used in unstructured objects
+func resourceListObject(resources corev1.ResourceList) map[string]interface{} {
+	obj := map[string]interface{}{}
+	for name, quantity := range resources {
+		obj[string(name)] = quantity.String()
+	}
+	return obj
+}
+
+// vpaForComponent returns an autoscaling.k8s.io/v1 VerticalPodAutoscaler for the container of a
+// component. We are using an unstructured object to avoid depending on the autoscaler API packages.
+func vpaForComponent(m *repomanagerv1alpha1.Pulp, target vpaTarget) *unstructured.Unstructured {
+	updateMode := "Off"
+	if len(target.vpa.UpdateMode) > 0 {
+		updateMode = target.vpa.UpdateMode
+	}
+
+	containerPolicy := map[string]interface{}{
+		"containerName": target.container,
+	}
+	if len(target.vpa.MinAllowed) > 0 {
+		containerPolicy["minAllowed"] = resourceListObject(target.vpa.MinAllowed)
+	}
+	if len(target.vpa.MaxAllowed) > 0 {
+		containerPolicy["maxAllowed"] = resourceListObject(target.vpa.MaxAllowed)
+	}
+
+	vpa := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      m.Name + "-" + target.component,
+				"namespace": m.Namespace,
+				"labels": map[string]interface{}{
+					"app.kubernetes.io/name":       m.Spec.DeploymentType + "-" + target.component,
+					"app.kubernetes.io/instance":   m.Spec.DeploymentType + "-" + target.component + "-" + m.Name,
+					"app.kubernetes.io/component":  target.component,
+					"app.kubernetes.io/part-of":    m.Spec.DeploymentType,
+					"app.kubernetes.io/managed-by": m.Spec.DeploymentType + "-operator",
+				},
+			},
+			"spec": map[string]interface{}{
+				"targetRef": map[string]interface{}{
+					"apiVersion": "apps/v1",
+					"kind":       target.kind,
+					"name":       target.name,
+				},
+				"updatePolicy": map[string]interface{}{
+					"updateMode": updateMode,
+				},
+				// only the main container is autoscaled, the resources of the sidecars
+				// (like the metrics exporters) are kept as defined in pulp CR
+				"resourcePolicy": map[string]interface{}{
+					"containerPolicies": []interface{}{
+						containerPolicy,
+						map[string]interface{}{
+							"containerName": "*",
+							"mode":          "Off",
+						},
+					},
+				},
+			},
+		},
+	}
+	vpa.SetAPIVersion(vpaGroupVersion)
+	vpa.SetKind("VerticalPodAutoscaler")
+	return vpa
+}
+
+// vpaRecommendation returns the resources recommended by a VerticalPodAutoscaler for a container
+func vpaRecommendation(vpa *unstructured.Unstructured, component, container string) *repomanagerv1alpha1.ResourceRecommendationStatus {
+	recommendations, _, _ := unstructured.NestedSlice(vpa.Object, "status", "recommendation", "containerRecommendations")
+	for _, r := range recommendations {
+		recommendation, ok := r.(map[string]interface{})
+		if !ok || recommendation["containerName"] != container {
+			continue
+		}
+		return &repomanagerv1alpha1.ResourceRecommendationStatus{
+			Component:  component,
+			Target:     resourceListFromObject(recommendation["target"]),
+			LowerBound: resourceListFromObject(recommendation["lowerBound"]),
+			UpperBound: resourceListFromObject(recommendation["upperBound"]),
+		}
+	}
+	return nil
+}
+
+// resourceListFromObject converts the resources from an unstructured object into a ResourceList
+func resourceListFromObject(obj interface{}) corev1.ResourceList {
+	resources, ok := obj.(map[string]interface{})
+	if !ok || len(resources) == 0 {
+		return nil
+	}
+	list := corev1.ResourceList{}
+	for name, value := range resources {
+		value, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if quantity, err := resource.ParseQuantity(value); err == nil {
+			list[corev1.ResourceName(name)] = quantity
+		}
+	}
+	return list
+}
+
+// vpaController creates, reconciles and removes the VerticalPodAutoscalers of {api,content,worker,database,cache}
+// in case the autoscaling.k8s.io API is available in the cluster and records their recommendations in pulp CR status
+func (r *PulpReconciler) vpaController(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) (ctrl.Result, error) {
+	vpaAvailable, err := r.isAPIAvailable(vpaGroupVersion)
+	if err != nil {
+		log.Error(err, "Failed to check if "+vpaGroupVersion+" API is available")
+		return ctrl.Result{}, err
+	}
+	if !vpaAvailable {
+		if vpaDefined(pulp) {
+			log.V(1).Info("The " + vpaGroupVersion + " API is not available, skipping the VerticalPodAutoscalers creation")
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.autoscalersConflict(ctx, pulp, log); err != nil {
+		log.Error(err, "Failed to update autoscalers conflict status")
+		return ctrl.Result{}, err
+	}
+
+	var recommendations []repomanagerv1alpha1.ResourceRecommendationStatus
+	for _, target := range vpaTargets(pulp) {
+		vpaFound := &unstructured.Unstructured{}
+		vpaFound.SetAPIVersion(vpaGroupVersion)
+		vpaFound.SetKind("VerticalPodAutoscaler")
+		err := r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-" + target.component, Namespace: pulp.Namespace}, vpaFound)
+
+		// remove the VPA if vpa is not defined anymore
+		if target.vpa == nil {
+			if err == nil {
+				log.Info("Removing " + target.component + " VPA ...")
+				if err = r.Delete(ctx, vpaFound); err != nil {
+					log.Error(err, "Failed to remove "+target.component+" VPA")
+					return ctrl.Result{}, err
+				}
+				r.recorder.Event(pulp, corev1.EventTypeNormal, "Deleted", target.component+" VPA removed")
+			} else if !errors.IsNotFound(err) {
+				log.Error(err, "Failed to get "+target.component+" VPA")
+				return ctrl.Result{}, err
+			}
+			continue
+		}
+
+		expectedVPA := vpaForComponent(pulp, target)
+		ctrl.SetControllerReference(pulp, expectedVPA, r.Scheme)
+
+		// Create VPA if not found
+		if err != nil && errors.IsNotFound(err) {
+			log.Info("Creating a new " + target.component + " VPA ...")
+			if err = r.Create(ctx, expectedVPA); err != nil {
+				log.Error(err, "Failed to create new "+target.component+" VPA")
+				r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to create new "+target.component+" VPA")
+				return ctrl.Result{}, err
+			}
+			// VPA created successfully - return and requeue
+			r.recorder.Event(pulp, corev1.EventTypeNormal, "Created", target.component+" VPA created")
+			return ctrl.Result{Requeue: true}, nil
+		} else if err != nil {
+			log.Error(err, "Failed to get "+target.component+" VPA")
+			return ctrl.Result{}, err
+		}
+
+		// Reconcile VPA
+		if !equality.Semantic.DeepEqual(expectedVPA.Object["spec"], vpaFound.Object["spec"]) {
+			log.Info("The " + target.component + " VPA has been modified! Reconciling ...")
+			r.recorder.Event(pulp, corev1.EventTypeNormal, "Updating", "Reconciling "+target.component+" VPA")
+			expectedVPA.SetResourceVersion(vpaFound.GetResourceVersion())
+			if err = r.Update(ctx, expectedVPA); err != nil {
+				log.Error(err, "Error trying to update the "+target.component+" VPA object ... ")
+				r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to reconcile "+target.component+" VPA")
+				return ctrl.Result{}, err
+			}
+			r.recorder.Event(pulp, corev1.EventTypeNormal, "Updated", target.component+" VPA reconciled")
+			return ctrl.Result{Requeue: true}, nil
+		}
+
+		if recommendation := vpaRecommendation(vpaFound, target.component, target.container); recommendation != nil {
+			recommendations = append(recommendations, *recommendation)
+		}
+	}
+
+	// only update the status when it changes to avoid triggering new reconciliations
+	if equality.Semantic.DeepEqual(recommendations, pulp.Status.ResourceRecommendations) {
+		return ctrl.Result{}, nil
+	}
+	pulp.Status.ResourceRecommendations = recommendations
+	if err := r.Status().Update(ctx, pulp); err != nil {
+		log.Error(err, "Failed to update resource recommendations status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// autoscalersConflict sets a status condition (and emits an event once) with the components whose VPA
+// (update_mode: Auto) and HPA are both based on CPU. A VPA recreating the pods with new CPU requests changes
+// the utilization used by a CPU based HPA of the same component, making both autoscalers react to each other.
+func (r *PulpReconciler) autoscalersConflict(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) error {
+	conditionType := cases.Title(language.English, cases.Compact).String(pulp.Spec.DeploymentType) + "-Autoscalers-Conflict"
+
+	var conflicts []string
+	autoscaled := autoscaledComponents(pulp)
+	for _, target := range vpaTargets(pulp) {
+		if target.vpa != nil && target.vpa.UpdateMode == "Auto" && hpaScalesOnCPU(pulp, target.component, autoscaled[target.component]) {
+			conflicts = append(conflicts, target.component)
+		}
+	}
+
+	condition := v1.FindStatusCondition(pulp.Status.Conditions, conditionType)
+	if len(conflicts) == 0 {
+		if condition == nil {
+			return nil
+		}
+		v1.RemoveStatusCondition(&pulp.Status.Conditions, conditionType)
+		return r.Status().Update(ctx, pulp)
+	}
+
+	message := "The VPA (update_mode: Auto) and HPA of " + strings.Join(conflicts, ", ") + " are both based on CPU, consider using update_mode: Off or Initial or scaling on other metrics"
+	// only update the status (and emit the event) when the conflicting components change
+	if condition != nil && condition.Message == message {
+		return nil
+	}
+	log.Info(message)
+	r.recorder.Event(pulp, corev1.EventTypeWarning, "Conflict", "VPA with update_mode Auto conflicts with the CPU based HPA of "+strings.Join(conflicts, ", "))
+	v1.SetStatusCondition(&pulp.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionTrue,
+		Reason:             "VPAConflictsWithHPA",
+		LastTransitionTime: metav1.Now(),
+		Message:            message,
+	})
+	return r.Status().Update(ctx, pulp)
+}
@@ -18,7 +18,6 @@ package pulp
 
 import (
 	"context"
-	"os"
 	"strings"
 	"time"
 
@@ -69,9 +68,22 @@ func (r *PulpReconciler) pulpWebController(ctx context.Context, pulp *repomanage
 	webDeployment := &appsv1.Deployment{}
 	err = r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-web", Namespace: pulp.Namespace}, webDeployment)
 	newWebDeployment := r.deploymentForPulpWeb(pulp)
+	if err := validateVolumes("web", newWebDeployment.Spec.Template.Spec); err != nil {
+		log.Error(err, "Invalid web volumes configuration")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "InvalidVolumes", err.Error())
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid web volumes configuration: "+err.Error())
+		return ctrl.Result{}, err
+	}
+	if err := validateContainers("web", newWebDeployment.Spec.Template.Spec); err != nil {
+		log.Error(err, "Invalid web containers configuration")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "InvalidContainers", err.Error())
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid web containers configuration: "+err.Error())
+		return ctrl.Result{}, err
+	}
 	if err != nil && errors.IsNotFound(err) {
 		log.Info("Creating a new Pulp Web Deployment", "Deployment.Namespace", newWebDeployment.Namespace, "Deployment.Name", newWebDeployment.Name)
 		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "CreatingWebDeployment", "Creating "+pulp.Name+"-web deployment resource")
+		r.warnEnvOverrides(pulp, "web", newWebDeployment.Spec.Template.Spec)
 		err = r.Create(ctx, newWebDeployment)
 		if err != nil {
 			log.Error(err, "Failed to create new Pulp Web Deployment", "Deployment.Namespace", newWebDeployment.Namespace, "Deployment.Name", newWebDeployment.Name)
@@ -87,11 +99,15 @@ func (r *PulpReconciler) pulpWebController(ctx context.Context, pulp *repomanage
 		return ctrl.Result{}, err
 	}
 
+	// the number of replicas is managed by the HPA when autoscaling is enabled
+	newWebDeployment.Spec.Replicas = autoscaledReplicas(pulp.Spec.Web.Autoscaling, *effectivePulp(pulp).Spec.Web.Replicas, webDeployment)
+
 	// Reconcile Deployment
-	if !equality.Semantic.DeepDerivative(newWebDeployment.Spec, webDeployment.Spec) {
+	if !equality.Semantic.DeepDerivative(newWebDeployment.Spec, webDeployment.Spec) || podTemplateModified(newWebDeployment.Spec.Template, webDeployment.Spec.Template) {
 		log.Info("The Web Deployment has been modified! Reconciling ...")
 		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "UpdatingWebDeployment", "Reconciling "+pulp.Name+"-web deployment resource")
 		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updating", "Reconciling Web Deployment")
+		r.warnEnvOverrides(pulp, "web", newWebDeployment.Spec.Template.Spec)
 		err = r.Update(ctx, newWebDeployment)
 		if err != nil {
 			log.Error(err, "Error trying to update the Web Deployment object ... ")
@@ -131,16 +147,12 @@ func (r *PulpReconciler) pulpWebController(ctx context.Context, pulp *repomanage
 
 // deploymentForPulpWeb returns a pulp-web Deployment object
 func (r *PulpReconciler) deploymentForPulpWeb(m *repomanagerv1alpha1.Pulp) *appsv1.Deployment {
+	m = effectivePulp(m)
 
 	ls := labelsForPulpWeb(m)
-	replicas := m.Spec.Web.Replicas
+	replicas := autoscaledReplicas(m.Spec.Web.Autoscaling, *m.Spec.Web.Replicas, nil)
 	resources := m.Spec.Web.ResourceRequirements
-	ImageWeb := os.Getenv("RELATED_IMAGE_PULP_WEB")
-	if len(m.Spec.ImageWeb) > 0 && len(m.Spec.ImageWebVersion) > 0 {
-		ImageWeb = m.Spec.ImageWeb + ":" + m.Spec.ImageWebVersion
-	} else if ImageWeb == "" {
-		ImageWeb = "quay.io/pulp/pulp-web:stable"
-	}
+	ImageWeb := webImage(m)
 
 	readinessProbe := m.Spec.Web.ReadinessProbe
 	if readinessProbe == nil {
@@ -162,13 +174,53 @@ func (r *PulpReconciler) deploymentForPulpWeb(m *repomanagerv1alpha1.Pulp) *apps
 		}
 	}
 
+	// the readiness probe checks pulp-api status through nginx, so pulp-web is only ready after
+	// pulp-api finished its boot process. The liveness probe only checks nginx, to avoid restarting
+	// pulp-web pods when pulp-api is unavailable.
 	livenessProbe := m.Spec.Web.LivenessProbe
+	if livenessProbe == nil {
+		livenessProbe = &corev1.Probe{
+			FailureThreshold: 5,
+			ProbeHandler: corev1.ProbeHandler{
+				TCPSocket: &corev1.TCPSocketAction{
+					Port: intstr.IntOrString{
+						IntVal: 8080,
+					},
+				},
+			},
+			InitialDelaySeconds: 30,
+			PeriodSeconds:       20,
+			SuccessThreshold:    1,
+			TimeoutSeconds:      10,
+		}
+	}
+
+	affinity := &corev1.Affinity{}
+	if m.Spec.Web.Affinity.NodeAffinity != nil {
+		affinity.NodeAffinity = m.Spec.Web.Affinity.NodeAffinity
+	}
+	if m.Spec.Web.Affinity.PodAffinity != nil {
+		affinity.PodAffinity = m.Spec.Web.Affinity.PodAffinity
+	}
+	if m.Spec.Web.Affinity.PodAntiAffinity != nil {
+		affinity.PodAntiAffinity = m.Spec.Web.Affinity.PodAntiAffinity
+	}
 
 	nodeSelector := map[string]string{}
 	if m.Spec.Web.NodeSelector != nil {
 		nodeSelector = m.Spec.Web.NodeSelector
 	}
 
+	toleration := []corev1.Toleration{}
+	if m.Spec.Web.Tolerations != nil {
+		toleration = m.Spec.Web.Tolerations
+	}
+
+	topologySpreadConstraint := []corev1.TopologySpreadConstraint{}
+	if m.Spec.Web.TopologySpreadConstraints != nil {
+		topologySpreadConstraint = m.Spec.Web.TopologySpreadConstraints
+	}
+
 	dep := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      m.Name + "-web",
@@ -183,7 +235,8 @@ func (r *PulpReconciler) deploymentForPulpWeb(m *repomanagerv1alpha1.Pulp) *apps
 			},
 		},
 		Spec: appsv1.DeploymentSpec{
-			Replicas: &replicas,
+			Replicas: replicas,
+			Strategy: m.Spec.Web.Strategy,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: ls,
 			},
@@ -192,13 +245,21 @@ func (r *PulpReconciler) deploymentForPulpWeb(m *repomanagerv1alpha1.Pulp) *apps
 					Labels: ls,
 				},
 				Spec: corev1.PodSpec{
-					NodeSelector:       nodeSelector,
-					ServiceAccountName: m.Name,
-					Containers: []corev1.Container{{
+					Affinity:                      affinity,
+					NodeSelector:                  nodeSelector,
+					Tolerations:                   toleration,
+					TopologySpreadConstraints:     topologySpreadConstraint,
+					ServiceAccountName:            m.Name,
+					PriorityClassName:             m.Spec.Web.PriorityClassName,
+					RuntimeClassName:              m.Spec.Web.RuntimeClassName,
+					HostAliases:                   m.Spec.Web.HostAliases,
+					TerminationGracePeriodSeconds: terminationGracePeriod(m.Spec.Web.TerminationGracePeriodSeconds, defaultTerminationGracePeriod),
+					InitContainers:                m.Spec.Web.InitContainers,
+					Containers: append([]corev1.Container{{
 						Image:     ImageWeb,
 						Name:      "web",
 						Resources: resources,
-						Env: []corev1.EnvVar{
+						Env: append([]corev1.EnvVar{
 							{
 								Name: "NODE_IP",
 								ValueFrom: &corev1.EnvVarSource{
@@ -207,24 +268,27 @@ func (r *PulpReconciler) deploymentForPulpWeb(m *repomanagerv1alpha1.Pulp) *apps
 									},
 								},
 							},
-						},
+						}, m.Spec.Web.Env...),
+						EnvFrom: m.Spec.Web.EnvFrom,
 						Ports: []corev1.ContainerPort{{
 							ContainerPort: 8080,
 							Protocol:      "TCP",
 						}},
 						LivenessProbe:  livenessProbe,
 						ReadinessProbe: readinessProbe,
-						VolumeMounts: []corev1.VolumeMount{
+						StartupProbe:   m.Spec.Web.StartupProbe,
+						VolumeMounts: append([]corev1.VolumeMount{
 							{
 								Name:      m.Name + "-nginx-conf",
 								MountPath: "/etc/nginx/nginx.conf",
 								SubPath:   "nginx.conf",
 								ReadOnly:  true,
 							},
-						},
-					}},
-					SecurityContext: &corev1.PodSecurityContext{},
-					Volumes: []corev1.Volume{
+						}, m.Spec.Web.VolumeMounts...),
+						SecurityContext: componentSecurityContext("web", m.Spec.Web.SecurityContext),
+					}}, m.Spec.Web.SidecarContainers...),
+					SecurityContext: componentPodSecurityContext("web", m.Spec.Web.PodSecurityContext),
+					Volumes: append([]corev1.Volume{
 						{
 							Name: m.Name + "-nginx-conf",
 							VolumeSource: corev1.VolumeSource{
@@ -238,12 +302,13 @@ func (r *PulpReconciler) deploymentForPulpWeb(m *repomanagerv1alpha1.Pulp) *apps
 								},
 							},
 						},
-					},
+					}, m.Spec.Web.Volumes...),
 				},
 			},
 		},
 	}
 	// Set Pulp instance as the owner and controller
+	setPodMetadata(m, &dep.Spec.Template, m.Spec.Web.PodLabels, m.Spec.Web.PodAnnotations)
 	ctrl.SetControllerReference(m, dep, r.Scheme)
 	return dep
 }
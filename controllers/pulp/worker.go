@@ -18,7 +18,6 @@ package pulp
 
 import (
 	"context"
-	"os"
 	"strconv"
 	"time"
 
@@ -44,13 +43,34 @@ func (r *PulpReconciler) pulpWorkerController(ctx context.Context, pulp *repoman
 	// conditionType is used to update .status.conditions with the current resource state
 	conditionType := cases.Title(language.English, cases.Compact).String(pulp.Spec.DeploymentType) + "-Worker-Ready"
 
+	// the pods are redeployed when settings.py is modified
+	settingsChecksum, err := r.settingsChecksum(ctx, pulp)
+	if err != nil {
+		log.Error(err, "Failed to get the checksum of settings.py")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "ErrorGettingSettings", "Failed to get "+pulp.Name+"-server secret: "+err.Error())
+		return ctrl.Result{}, err
+	}
+
 	// Worker Deployment
 	workerDeployment := &appsv1.Deployment{}
-	err := r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-worker", Namespace: pulp.Namespace}, workerDeployment)
-	newWorkerDeployment := r.deploymentForPulpWorker(pulp)
+	err = r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-worker", Namespace: pulp.Namespace}, workerDeployment)
+	newWorkerDeployment := r.deploymentForPulpWorker(pulp, settingsChecksum)
+	if err := validateVolumes("worker", newWorkerDeployment.Spec.Template.Spec); err != nil {
+		log.Error(err, "Invalid worker volumes configuration")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "InvalidVolumes", err.Error())
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid worker volumes configuration: "+err.Error())
+		return ctrl.Result{}, err
+	}
+	if err := validateContainers("worker", newWorkerDeployment.Spec.Template.Spec); err != nil {
+		log.Error(err, "Invalid worker containers configuration")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "InvalidContainers", err.Error())
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid worker containers configuration: "+err.Error())
+		return ctrl.Result{}, err
+	}
 	if err != nil && errors.IsNotFound(err) {
 		log.Info("Creating a new Pulp Worker Deployment", "Deployment.Namespace", newWorkerDeployment.Namespace, "Deployment.Name", newWorkerDeployment.Name)
 		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "CreatingWorkerDeployment", "Creating "+pulp.Name+"-worker deployment resource")
+		r.warnEnvOverrides(pulp, "worker", newWorkerDeployment.Spec.Template.Spec)
 		err = r.Create(ctx, newWorkerDeployment)
 		if err != nil {
 			log.Error(err, "Failed to create new Pulp Worker Deployment", "Deployment.Namespace", newWorkerDeployment.Namespace, "Deployment.Name", newWorkerDeployment.Name)
@@ -66,11 +86,17 @@ func (r *PulpReconciler) pulpWorkerController(ctx context.Context, pulp *repoman
 		return ctrl.Result{}, err
 	}
 
+	// the number of replicas is managed by the worker autoscaling when it is enabled
+	if pulp.Spec.Worker.Autoscaling != nil {
+		newWorkerDeployment.Spec.Replicas = workerDeployment.Spec.Replicas
+	}
+
 	// Reconcile Deployment
-	if !equality.Semantic.DeepDerivative(newWorkerDeployment.Spec, workerDeployment.Spec) {
+	if !equality.Semantic.DeepDerivative(newWorkerDeployment.Spec, workerDeployment.Spec) || podTemplateModified(newWorkerDeployment.Spec.Template, workerDeployment.Spec.Template) {
 		log.Info("The Worker Deployment has been modified! Reconciling ...")
 		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "UpdatingWorkerDeployment", "Reconciling "+pulp.Name+"-worker deployment resource")
 		r.recorder.Event(pulp, corev1.EventTypeNormal, "Updating", "Reconciling Worker Deployment")
+		r.warnEnvOverrides(pulp, "worker", newWorkerDeployment.Spec.Template.Spec)
 		err = r.Update(ctx, newWorkerDeployment)
 		if err != nil {
 			log.Error(err, "Error trying to update the Worker Deployment object ... ")
@@ -82,6 +108,11 @@ func (r *PulpReconciler) pulpWorkerController(ctx context.Context, pulp *repoman
 		return ctrl.Result{Requeue: true, RequeueAfter: time.Second}, nil
 	}
 
+	// the worker pools are reconciled with pulp-worker, so Worker-Ready also reflects them
+	if res, err := r.workerPoolsController(ctx, pulp, log); err != nil || res.Requeue || res.RequeueAfter > 0 {
+		return res, err
+	}
+
 	// we should only update the status when Worker-Ready==false
 	if v1.IsStatusConditionFalse(pulp.Status.Conditions, conditionType) {
 		r.updateStatus(ctx, pulp, metav1.ConditionTrue, conditionType, "WorkerTasksFinished", "All Worker tasks ran successfully")
@@ -91,7 +122,8 @@ func (r *PulpReconciler) pulpWorkerController(ctx context.Context, pulp *repoman
 }
 
 // deploymentForPulpWorker returns a pulp-worker Deployment object
-func (r *PulpReconciler) deploymentForPulpWorker(m *repomanagerv1alpha1.Pulp) *appsv1.Deployment {
+func (r *PulpReconciler) deploymentForPulpWorker(m *repomanagerv1alpha1.Pulp, settingsChecksum string) *appsv1.Deployment {
+	m = effectivePulp(m)
 	ls := labelsForPulpWorker(m)
 	labels := map[string]string{
 		"app.kubernetes.io/name":       m.Spec.DeploymentType + "-worker",
@@ -101,25 +133,26 @@ func (r *PulpReconciler) deploymentForPulpWorker(m *repomanagerv1alpha1.Pulp) *a
 		"app.kubernetes.io/managed-by": m.Spec.DeploymentType + "-operator",
 		"owner":                        "pulp-dev",
 	}
-	replicas := m.Spec.Worker.Replicas
+	replicas := *m.Spec.Worker.Replicas
+	if m.Spec.Worker.Autoscaling != nil {
+		replicas = m.Spec.Worker.Autoscaling.Min
+	}
 
 	affinity := &corev1.Affinity{}
 	if m.Spec.Worker.Affinity.NodeAffinity != nil {
 		affinity.NodeAffinity = m.Spec.Worker.Affinity.NodeAffinity
 	}
+	if m.Spec.Worker.Affinity.PodAffinity != nil {
+		affinity.PodAffinity = m.Spec.Worker.Affinity.PodAffinity
+	}
+	if m.Spec.Worker.Affinity.PodAntiAffinity != nil {
+		affinity.PodAntiAffinity = m.Spec.Worker.Affinity.PodAntiAffinity
+	}
 
 	// pulp image is built to run with user 0
-	// we are enforcing the containers to run as 1000
-	runAsUser := int64(700)
-	fsGroup := int64(700)
-	podSecurityContext := &corev1.PodSecurityContext{}
+	// we are enforcing the containers to run as 700 (restricted Pod Security Standard)
+	podSecurityContext := componentPodSecurityContext("worker", m.Spec.Worker.PodSecurityContext)
 	IsOpenShift, _ := controllers.IsOpenShift()
-	if !IsOpenShift {
-		podSecurityContext = &corev1.PodSecurityContext{
-			RunAsUser: &runAsUser,
-			FSGroup:   &fsGroup,
-		}
-	}
 
 	nodeSelector := map[string]string{}
 	if m.Spec.Worker.NodeSelector != nil {
@@ -314,6 +347,7 @@ func (r *PulpReconciler) deploymentForPulpWorker(m *repomanagerv1alpha1.Pulp) *a
 			redisEnvVars := []corev1.EnvVar{
 				{Name: "REDIS_SERVICE_HOST", Value: cacheHost},
 				{Name: "REDIS_SERVICE_PORT", Value: cachePort},
+				redisPasswordEnvVar(m),
 			}
 			envVars = append(envVars, redisEnvVars...)
 		} else {
@@ -362,6 +396,7 @@ func (r *PulpReconciler) deploymentForPulpWorker(m *repomanagerv1alpha1.Pulp) *a
 			}
 			envVars = append(envVars, redisEnvVars...)
 		}
+		envVars = append(envVars, redisTLSEnvVars(m)...)
 	}
 
 	if m.Spec.SigningSecret != "" {
@@ -373,6 +408,9 @@ func (r *PulpReconciler) deploymentForPulpWorker(m *repomanagerv1alpha1.Pulp) *a
 		envVars = append(envVars, signingKeyEnvVars...)
 	}
 
+	// the variables from pulp CR are added after the ones defined by the operator (so they take precedence)
+	envVars = append(envVars, m.Spec.Worker.Env...)
+
 	volumeMounts := []corev1.VolumeMount{
 		{
 			Name:      m.Name + "-ansible-tmp",
@@ -441,13 +479,18 @@ func (r *PulpReconciler) deploymentForPulpWorker(m *repomanagerv1alpha1.Pulp) *a
 		volumes, volumeMounts = mountCASpec(m, volumes, volumeMounts)
 	}
 
+	// mountExternalDBCerts adds the client certificates from the external database secret
+	volumes, volumeMounts = mountExternalDBCerts(m, volumes, volumeMounts)
+
+	// mountRedisCerts adds the CA bundle used to verify the redis server certificate
+	volumes, volumeMounts = mountRedisCerts(m, volumes, volumeMounts)
+
+	// the volumes from pulp CR are added after the ones defined by the operator
+	volumes = append(volumes, m.Spec.Worker.Volumes...)
+	volumeMounts = append(volumeMounts, m.Spec.Worker.VolumeMounts...)
+
 	resources := m.Spec.Worker.ResourceRequirements
-	Image := os.Getenv("RELATED_IMAGE_PULP")
-	if len(m.Spec.Image) > 0 && len(m.Spec.ImageVersion) > 0 {
-		Image = m.Spec.Image + ":" + m.Spec.ImageVersion
-	} else if Image == "" {
-		Image = "quay.io/pulp/pulp:stable"
-	}
+	Image := deployedComponentImage(m, "worker")
 
 	readinessProbe := m.Spec.Worker.ReadinessProbe
 	livenessProbe := m.Spec.Worker.LivenessProbe
@@ -467,31 +510,44 @@ func (r *PulpReconciler) deploymentForPulpWorker(m *repomanagerv1alpha1.Pulp) *a
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: ls,
+					Annotations: map[string]string{
+						settingsChecksumAnnotation: settingsChecksum,
+					},
 				},
 				Spec: corev1.PodSpec{
-					Affinity:                  affinity,
-					SecurityContext:           podSecurityContext,
-					NodeSelector:              nodeSelector,
-					Tolerations:               toleration,
-					Volumes:                   volumes,
-					ServiceAccountName:        m.Name,
-					TopologySpreadConstraints: topologySpreadConstraint,
-					Containers: []corev1.Container{{
+					Affinity:                      affinity,
+					SecurityContext:               podSecurityContext,
+					NodeSelector:                  nodeSelector,
+					Tolerations:                   toleration,
+					Volumes:                       volumes,
+					ServiceAccountName:            m.Name,
+					PriorityClassName:             m.Spec.Worker.PriorityClassName,
+					RuntimeClassName:              m.Spec.Worker.RuntimeClassName,
+					HostAliases:                   m.Spec.Worker.HostAliases,
+					TerminationGracePeriodSeconds: terminationGracePeriod(m.Spec.Worker.TerminationGracePeriodSeconds, workerTerminationGracePeriod),
+					TopologySpreadConstraints:     topologySpreadConstraint,
+					InitContainers:                m.Spec.Worker.InitContainers,
+					Containers: append([]corev1.Container{{
 						Name:            "worker",
 						Image:           Image,
 						ImagePullPolicy: corev1.PullPolicy(m.Spec.ImagePullPolicy),
 						Args:            []string{"pulp-worker"},
 						Env:             envVars,
+						EnvFrom:         m.Spec.Worker.EnvFrom,
 						LivenessProbe:   livenessProbe,
+						StartupProbe:    m.Spec.Worker.StartupProbe,
 						ReadinessProbe:  readinessProbe,
 						VolumeMounts:    volumeMounts,
 						Resources:       resources,
-					}},
+						SecurityContext: componentSecurityContext("worker", m.Spec.Worker.SecurityContext),
+						Lifecycle:       workerLifecycle(),
+					}}, m.Spec.Worker.SidecarContainers...),
 				},
 			},
 		},
 	}
 	// Set Pulp instance as the owner and controller
+	setPodMetadata(m, &dep.Spec.Template, m.Spec.Worker.PodLabels, m.Spec.Worker.PodAnnotations)
 	ctrl.SetControllerReference(m, dep, r.Scheme)
 	return dep
 }
@@ -0,0 +1,338 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	"github.com/pulp/pulp-operator/controllers"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// tasksViewerUser is the Pulp user used by the operator to query the tasks queue.
+// It only has the core.task_viewer role, so its credentials cannot be used to modify Pulp.
+const tasksViewerUser = "pulp-operator-tasks-viewer"
+
+// tasksViewerProvisionedAnnotation is set in the tasks viewer secret once its user has been created in Pulp
+const tasksViewerProvisionedAnnotation = "repo-manager.pulpproject.org/tasks-viewer-provisioned"
+
+// tasksViewerScript creates (or resets the password of) the tasks viewer user in Pulp.
+// The password is read from stdin to keep it out of the process list.
+const tasksViewerScript = `import sys
+from django.contrib.auth import get_user_model
+from pulpcore.app.models.role import Role, UserRole
+user, _ = get_user_model().objects.get_or_create(username="%v")
+user.is_superuser = False
+user.is_staff = False
+user.set_password(sys.stdin.readline().strip())
+user.save()
+UserRole.objects.get_or_create(user=user, role=Role.objects.get(name="core.task_viewer"), content_type=None, object_id=None)`
+
+// errPulpAPIUnauthorized is returned when Pulp API rejects the credentials of the operator
+var errPulpAPIUnauthorized = fmt.Errorf("the operator credentials were rejected by Pulp API")
+
+// pulpTasksQueue is the number of tasks in each state of Pulp tasks queue
+type pulpTasksQueue struct {
+	Waiting int32
+	Running int32
+}
+
+// pulpAPIURL returns the address used by the operator to reach Pulp API
+func (r *PulpReconciler) pulpAPIURL(m *repomanagerv1alpha1.Pulp) string {
+	if r.PulpAPIURL != nil {
+		return r.PulpAPIURL(m)
+	}
+	return "http://" + m.Name + "-api-svc." + m.Namespace + ".svc.cluster.local:24817"
+}
+
+// workerTasksPerWorker returns the number of waiting tasks handled by each additional worker
+func workerTasksPerWorker(autoscaling *repomanagerv1alpha1.WorkerAutoscaling) int32 {
+	if autoscaling.TasksPerWorker < 1 {
+		return 2
+	}
+	return autoscaling.TasksPerWorker
+}
+
+// workerPollInterval returns the interval between two queries to Pulp tasks API
+func workerPollInterval(autoscaling *repomanagerv1alpha1.WorkerAutoscaling) time.Duration {
+	if autoscaling.PollInterval < 5 {
+		return 30 * time.Second
+	}
+	return time.Duration(autoscaling.PollInterval) * time.Second
+}
+
+// workerScaleDownCooldown returns the minimum time between a scaling operation and a scale down
+func workerScaleDownCooldown(autoscaling *repomanagerv1alpha1.WorkerAutoscaling) time.Duration {
+	if autoscaling.ScaleDownCooldown == nil || *autoscaling.ScaleDownCooldown < 0 {
+		return 300 * time.Second
+	}
+	return time.Duration(*autoscaling.ScaleDownCooldown) * time.Second
+}
+
+// validateWorkerAutoscaling returns an error if the worker autoscaling configuration is not consistent
+func validateWorkerAutoscaling(autoscaling *repomanagerv1alpha1.WorkerAutoscaling) error {
+	if autoscaling.Min < 0 {
+		return fmt.Errorf("worker.autoscaling.min (%v) cannot be negative", autoscaling.Min)
+	}
+	if autoscaling.Max < 1 || autoscaling.Max < autoscaling.Min {
+		return fmt.Errorf("worker.autoscaling.max (%v) should be greater than 0 and not lower than worker.autoscaling.min (%v)", autoscaling.Max, autoscaling.Min)
+	}
+	return nil
+}
+
+// desiredWorkerReplicas returns the number of workers needed to handle the tasks queue:
+// one worker for each running task plus one worker for each tasks_per_worker waiting tasks,
+// bounded by worker.autoscaling.min and worker.autoscaling.max
+func desiredWorkerReplicas(autoscaling *repomanagerv1alpha1.WorkerAutoscaling, queue pulpTasksQueue) int32 {
+	tasksPerWorker := workerTasksPerWorker(autoscaling)
+	desired := queue.Running + (queue.Waiting+tasksPerWorker-1)/tasksPerWorker
+	if desired < autoscaling.Min {
+		return autoscaling.Min
+	}
+	if desired > autoscaling.Max {
+		return autoscaling.Max
+	}
+	return desired
+}
+
+// countPulpTasks returns the number of tasks in the given state from Pulp tasks API
+func countPulpTasks(ctx context.Context, apiURL, user, password, state string) (int32, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL+"/pulp/api/v3/tasks/?limit=1&fields=pulp_href&state="+state, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.SetBasicAuth(user, password)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return 0, errPulpAPIUnauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected response from Pulp tasks API: %v", resp.Status)
+	}
+
+	tasks := struct {
+		Count int32 `json:"count"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&tasks); err != nil {
+		return 0, fmt.Errorf("failed to decode Pulp tasks API response: %v", err)
+	}
+	return tasks.Count, nil
+}
+
+// countPulpTasksQueue returns the number of waiting and running tasks from Pulp tasks API
+func countPulpTasksQueue(ctx context.Context, apiURL, user, password string) (pulpTasksQueue, error) {
+	queue := pulpTasksQueue{}
+	var err error
+	if queue.Waiting, err = countPulpTasks(ctx, apiURL, user, password, "waiting"); err != nil {
+		return queue, err
+	}
+	if queue.Running, err = countPulpTasks(ctx, apiURL, user, password, "running"); err != nil {
+		return queue, err
+	}
+	return queue, nil
+}
+
+// tasksViewerSecret returns the secret with the credentials of the tasks viewer user
+func tasksViewerSecret(m *repomanagerv1alpha1.Pulp) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.Name + "-tasks-viewer",
+			Namespace: m.Namespace,
+		},
+		StringData: map[string]string{
+			"username": tasksViewerUser,
+			"password": createPwd(32),
+		},
+	}
+}
+
+// tasksViewerCredentials returns the secret with the credentials of the tasks viewer user,
+// creating it if it does not exist yet
+func (r *PulpReconciler) tasksViewerCredentials(ctx context.Context, pulp *repomanagerv1alpha1.Pulp) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-tasks-viewer", Namespace: pulp.Namespace}, secret)
+	if err != nil && errors.IsNotFound(err) {
+		secret = tasksViewerSecret(pulp)
+		ctrl.SetControllerReference(pulp, secret, r.Scheme)
+		if err := r.Create(ctx, secret); err != nil {
+			return nil, err
+		}
+		return secret, nil
+	}
+	return secret, err
+}
+
+// provisionTasksViewer creates the tasks viewer user in Pulp through a running pulp-api pod
+func (r *PulpReconciler) provisionTasksViewer(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, user, password string) error {
+	podList := &corev1.PodList{}
+	if err := r.List(ctx, podList, client.InNamespace(pulp.Namespace), client.MatchingLabels(labelsForPulpApi(pulp))); err != nil {
+		return err
+	}
+	for _, pod := range podList.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		execCmd := []string{"pulpcore-manager", "shell", "-c", fmt.Sprintf(tasksViewerScript, user)}
+		_, err := controllers.ContainerExecWithStdin(r, &pod, execCmd, strings.NewReader(password+"\n"), "api", pod.Namespace)
+		return err
+	}
+	return fmt.Errorf("no running pulp-api pod found to create the %v user", user)
+}
+
+// pulpTasks returns the number of waiting and running tasks from Pulp tasks API.
+// The operator authenticates with a dedicated user that can only read the tasks, which is
+// created in Pulp only once for each secret, the first time its credentials are rejected.
+func (r *PulpReconciler) pulpTasks(ctx context.Context, pulp *repomanagerv1alpha1.Pulp) (pulpTasksQueue, error) {
+	secret, err := r.tasksViewerCredentials(ctx, pulp)
+	if err != nil {
+		return pulpTasksQueue{}, err
+	}
+	user, password := string(secret.Data["username"]), string(secret.Data["password"])
+	_, provisioned := secret.Annotations[tasksViewerProvisionedAnnotation]
+
+	queue, err := countPulpTasksQueue(ctx, r.pulpAPIURL(pulp), user, password)
+	if err == errPulpAPIUnauthorized && provisioned {
+		return queue, fmt.Errorf("%v, remove the %v secret to create the %v user again", err, secret.Name, user)
+	} else if err == errPulpAPIUnauthorized {
+		if err := r.provisionTasksViewer(ctx, pulp, user, password); err != nil {
+			return queue, fmt.Errorf("failed to create the %v user in Pulp: %v", user, err)
+		}
+		queue, err = countPulpTasksQueue(ctx, r.pulpAPIURL(pulp), user, password)
+	}
+	if err != nil || provisioned {
+		return queue, err
+	}
+
+	// the credentials have been accepted, so they are not provisioned again if they get rejected later
+	patch := client.MergeFrom(secret.DeepCopy())
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[tasksViewerProvisionedAnnotation] = "true"
+	return queue, r.Patch(ctx, secret, patch)
+}
+
+// workerAutoscalingController scales the pulp-worker deployment based on the tasks queue
+func (r *PulpReconciler) workerAutoscalingController(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) (ctrl.Result, error) {
+	autoscaling := pulp.Spec.Worker.Autoscaling
+
+	// clean the status if autoscaling is not defined anymore
+	if autoscaling == nil {
+		if pulp.Status.WorkerAutoscaling != nil {
+			pulp.Status.WorkerAutoscaling = nil
+			if err := r.Status().Update(ctx, pulp); err != nil {
+				log.Error(err, "Failed to update worker autoscaling status")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if err := validateWorkerAutoscaling(autoscaling); err != nil {
+		log.Error(err, "Invalid worker autoscaling configuration")
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid worker autoscaling configuration: "+err.Error())
+		return ctrl.Result{}, err
+	}
+
+	workerDeployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-worker", Namespace: pulp.Namespace}, workerDeployment); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{RequeueAfter: workerPollInterval(autoscaling)}, nil
+		}
+		log.Error(err, "Failed to get Pulp Worker Deployment")
+		return ctrl.Result{}, err
+	}
+
+	// Pulp API can be unavailable (for example, during an upgrade), in this case we keep
+	// the current number of workers and try again in the next poll
+	queue, err := r.pulpTasks(ctx, pulp)
+	if err != nil {
+		log.Error(err, "Failed to get the tasks queue from Pulp API")
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "WorkerAutoscalingFailed", "Failed to get the tasks queue from Pulp API: "+err.Error())
+		return ctrl.Result{RequeueAfter: workerPollInterval(autoscaling)}, nil
+	}
+
+	current := int32(1)
+	if workerDeployment.Spec.Replicas != nil {
+		current = *workerDeployment.Spec.Replicas
+	}
+	desired := desiredWorkerReplicas(autoscaling, queue)
+
+	status := &repomanagerv1alpha1.WorkerAutoscalingStatus{
+		Replicas:     current,
+		WaitingTasks: queue.Waiting,
+		RunningTasks: queue.Running,
+	}
+	if pulp.Status.WorkerAutoscaling != nil {
+		status.LastScaleTime = pulp.Status.WorkerAutoscaling.LastScaleTime
+	}
+
+	// scale down only after the cooldown period to avoid flapping between polls,
+	// unless the number of workers is above worker.autoscaling.max
+	if desired < current && current <= autoscaling.Max && status.LastScaleTime != nil &&
+		time.Since(status.LastScaleTime.Time) < workerScaleDownCooldown(autoscaling) {
+		log.V(1).Info("Waiting for the cooldown period before scaling down the workers", "current", current, "desired", desired)
+		desired = current
+	}
+
+	if desired != current {
+		log.Info("Scaling Pulp Worker Deployment", "from", current, "to", desired, "waiting tasks", queue.Waiting, "running tasks", queue.Running)
+		workerDeployment.Spec.Replicas = &desired
+		if err := r.Update(ctx, workerDeployment); err != nil {
+			log.Error(err, "Failed to scale Pulp Worker Deployment")
+			r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to scale Worker Deployment")
+			return ctrl.Result{}, err
+		}
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "WorkersScaled", "Scaled Worker Deployment from "+strconv.Itoa(int(current))+" to "+strconv.Itoa(int(desired))+
+			" replicas (waiting tasks: "+strconv.Itoa(int(queue.Waiting))+", running tasks: "+strconv.Itoa(int(queue.Running))+")")
+		now := metav1.Now()
+		status.Replicas = desired
+		status.LastScaleTime = &now
+	}
+
+	// only update the status when it changes to avoid triggering new reconciliations
+	if pulp.Status.WorkerAutoscaling == nil || *status != *pulp.Status.WorkerAutoscaling {
+		pulp.Status.WorkerAutoscaling = status
+		if err := r.Status().Update(ctx, pulp); err != nil {
+			log.Error(err, "Failed to update worker autoscaling status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: workerPollInterval(autoscaling)}, nil
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// workerPoolLabel is set in the deployments and pods of the worker pools with the name of the pool
+const workerPoolLabel = "repo-manager.pulpproject.org/worker-pool"
+
+// workerPoolDeploymentName returns the name of the deployment of a worker pool
+func workerPoolDeploymentName(m *repomanagerv1alpha1.Pulp, pool string) string {
+	return m.Name + "-worker-" + pool
+}
+
+// workerPoolPDBName returns the name of the PDB of a worker pool
+func workerPoolPDBName(pool string) string {
+	return "worker-" + pool + "-pdb"
+}
+
+// labelsForPulpWorkerPool returns the labels for selecting the pods of a worker pool.
+// The instance label is different from the one of pulp-worker pods, so that the selectors
+// of pulp-worker deployment (and PDB) do not match the pods from the pools.
+func labelsForPulpWorkerPool(m *repomanagerv1alpha1.Pulp, pool string) map[string]string {
+	labels := labelsForPulpWorker(m)
+	labels["app.kubernetes.io/instance"] = m.Spec.DeploymentType + "-worker-" + pool + "-" + m.Name
+	labels[workerPoolLabel] = pool
+	return labels
+}
+
+// validateWorkerPools returns an error if the worker pools have duplicated names
+func validateWorkerPools(m *repomanagerv1alpha1.Pulp) error {
+	names := map[string]bool{}
+	for _, pool := range m.Spec.WorkerPools {
+		if names[pool.Name] {
+			return fmt.Errorf("worker pool %v is defined more than once", pool.Name)
+		}
+		names[pool.Name] = true
+	}
+	return nil
+}
+
+// deploymentForPulpWorkerPool returns the Deployment of a worker pool: a pulp-worker
+// Deployment with the replicas, resources, placement and environment variables of the pool
+func (r *PulpReconciler) deploymentForPulpWorkerPool(m *repomanagerv1alpha1.Pulp, pool repomanagerv1alpha1.WorkerPool, settingsChecksum string) *appsv1.Deployment {
+	dep := r.deploymentForPulpWorker(m, settingsChecksum)
+	ls := labelsForPulpWorkerPool(m, pool.Name)
+
+	dep.Name = workerPoolDeploymentName(m, pool.Name)
+	dep.Labels["app.kubernetes.io/instance"] = ls["app.kubernetes.io/instance"]
+	dep.Labels[workerPoolLabel] = pool.Name
+	dep.Spec.Selector = &metav1.LabelSelector{MatchLabels: ls}
+	for k, v := range ls {
+		dep.Spec.Template.Labels[k] = v
+	}
+
+	replicas := pool.Replicas
+	dep.Spec.Replicas = &replicas
+
+	podSpec := &dep.Spec.Template.Spec
+	if pool.NodeSelector != nil {
+		podSpec.NodeSelector = pool.NodeSelector
+	}
+	if pool.Tolerations != nil {
+		podSpec.Tolerations = pool.Tolerations
+	}
+	if pool.ResourceRequirements != nil {
+		podSpec.Containers[0].Resources = *pool.ResourceRequirements
+	}
+	podSpec.Containers[0].Env = append(podSpec.Containers[0].Env, pool.Env...)
+
+	return dep
+}
+
+// workerPoolsController creates and reconciles the deployments of the worker pools
+// and removes the ones from the pools that are not defined in pulp CR anymore
+func (r *PulpReconciler) workerPoolsController(ctx context.Context, pulp *repomanagerv1alpha1.Pulp, log logr.Logger) (ctrl.Result, error) {
+
+	// conditionType is used to update .status.conditions with the current resource state
+	conditionType := cases.Title(language.English, cases.Compact).String(pulp.Spec.DeploymentType) + "-Worker-Ready"
+
+	if err := validateWorkerPools(pulp); err != nil {
+		log.Error(err, "Invalid worker pools configuration")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "InvalidWorkerPools", err.Error())
+		r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Invalid worker pools configuration: "+err.Error())
+		return ctrl.Result{}, err
+	}
+
+	// the pods are redeployed when settings.py is modified
+	settingsChecksum, err := r.settingsChecksum(ctx, pulp)
+	if err != nil {
+		log.Error(err, "Failed to get the checksum of settings.py")
+		r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "ErrorGettingSettings", "Failed to get "+pulp.Name+"-server secret: "+err.Error())
+		return ctrl.Result{}, err
+	}
+
+	pools := map[string]bool{}
+	for _, pool := range pulp.Spec.WorkerPools {
+		pools[pool.Name] = true
+		expected := r.deploymentForPulpWorkerPool(pulp, pool, settingsChecksum)
+		found := &appsv1.Deployment{}
+		err := r.Get(ctx, types.NamespacedName{Name: expected.Name, Namespace: pulp.Namespace}, found)
+
+		if err != nil && errors.IsNotFound(err) {
+			log.Info("Creating a new Pulp Worker Pool Deployment", "Deployment.Namespace", expected.Namespace, "Deployment.Name", expected.Name)
+			r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "CreatingWorkerDeployment", "Creating "+expected.Name+" deployment resource")
+			r.warnEnvOverrides(pulp, "worker", expected.Spec.Template.Spec)
+			if err := r.Create(ctx, expected); err != nil {
+				log.Error(err, "Failed to create new Pulp Worker Pool Deployment", "Deployment.Namespace", expected.Namespace, "Deployment.Name", expected.Name)
+				r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "ErrorCreatingWorkerDeployment", "Failed to create "+expected.Name+" deployment resource: "+err.Error())
+				r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to create new Worker Pool Deployment")
+				return ctrl.Result{}, err
+			}
+			r.recorder.Event(pulp, corev1.EventTypeNormal, "Created", "Worker Pool Deployment "+expected.Name+" created")
+			return ctrl.Result{Requeue: true}, nil
+		} else if err != nil {
+			log.Error(err, "Failed to get Pulp Worker Pool Deployment", "Deployment.Name", expected.Name)
+			return ctrl.Result{}, err
+		}
+
+		if !equality.Semantic.DeepDerivative(expected.Spec, found.Spec) || podTemplateModified(expected.Spec.Template, found.Spec.Template) {
+			log.Info("The Worker Pool Deployment has been modified! Reconciling ...", "Deployment.Name", expected.Name)
+			r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "UpdatingWorkerDeployment", "Reconciling "+expected.Name+" deployment resource")
+			r.recorder.Event(pulp, corev1.EventTypeNormal, "Updating", "Reconciling Worker Pool Deployment "+expected.Name)
+			r.warnEnvOverrides(pulp, "worker", expected.Spec.Template.Spec)
+			if err := r.Update(ctx, expected); err != nil {
+				log.Error(err, "Error trying to update the Worker Pool Deployment object ... ", "Deployment.Name", expected.Name)
+				r.updateStatus(ctx, pulp, metav1.ConditionFalse, conditionType, "ErrorUpdatingWorkerDeployment", "Failed to reconcile "+expected.Name+" deployment resource: "+err.Error())
+				r.recorder.Event(pulp, corev1.EventTypeWarning, "Failed", "Failed to reconcile Worker Pool Deployment "+expected.Name)
+				return ctrl.Result{}, err
+			}
+			r.recorder.Event(pulp, corev1.EventTypeNormal, "Updated", "Worker Pool Deployment "+expected.Name+" reconciled")
+			return ctrl.Result{Requeue: true}, nil
+		}
+	}
+
+	// remove the deployments (and PDBs) from the pools removed from pulp CR
+	deploymentList := &appsv1.DeploymentList{}
+	if err := r.List(ctx, deploymentList, client.InNamespace(pulp.Namespace), client.HasLabels{workerPoolLabel}); err != nil {
+		log.Error(err, "Failed to list Worker Pool Deployments")
+		return ctrl.Result{}, err
+	}
+	for i := range deploymentList.Items {
+		deployment := &deploymentList.Items[i]
+		pool := deployment.Labels[workerPoolLabel]
+		if owner := metav1.GetControllerOf(deployment); owner == nil || owner.UID != pulp.UID || pools[pool] {
+			continue
+		}
+		log.Info("Removing Worker Pool Deployment", "Deployment.Name", deployment.Name)
+		if err := r.Delete(ctx, deployment); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to remove Worker Pool Deployment", "Deployment.Name", deployment.Name)
+			return ctrl.Result{}, err
+		}
+		pdb := &policy.PodDisruptionBudget{ObjectMeta: metav1.ObjectMeta{Name: workerPoolPDBName(pool), Namespace: pulp.Namespace}}
+		if err := r.Delete(ctx, pdb); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to remove Worker Pool PDB", "PDB.Name", pdb.Name)
+			return ctrl.Result{}, err
+		}
+		r.recorder.Event(pulp, corev1.EventTypeNormal, "Deleted", "Worker Pool Deployment "+deployment.Name+" removed")
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// workerDeploymentsNotReady returns the names of pulp-worker and worker pools deployments
+// that have unavailable replicas
+func (r *PulpReconciler) workerDeploymentsNotReady(ctx context.Context, pulp *repomanagerv1alpha1.Pulp) ([]string, error) {
+	names := []string{pulp.Name + "-worker"}
+	for _, pool := range pulp.Spec.WorkerPools {
+		names = append(names, workerPoolDeploymentName(pulp, pool.Name))
+	}
+
+	notReady := []string{}
+	for _, name := range names {
+		deployment := &appsv1.Deployment{}
+		if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: pulp.Namespace}, deployment); err != nil {
+			return nil, err
+		}
+		if !isDeploymentReady(deployment) {
+			notReady = append(notReady, name)
+		}
+	}
+	return notReady, nil
+}
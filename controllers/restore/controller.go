@@ -18,6 +18,7 @@ package pulp_restore
 
 import (
 	"context"
+	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -67,6 +68,21 @@ func (r *PulpRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, err
 	}
 
+	// the restore tasks already ran, only the readiness of the rescaled deployments is pending
+	if waitingDeployments(pulpRestore) {
+		if result, err := r.checkDeploymentsReady(ctx, pulpRestore); err != nil || result.RequeueAfter > 0 {
+			return result, err
+		}
+
+		log.Info("Cleaning up restore resources ...")
+		r.updateStatus(ctx, pulpRestore, metav1.ConditionFalse, "RestoreComplete", "Cleaning up restore resources ...", "DeletingMgmtPod")
+
+		r.cleanup(ctx, pulpRestore)
+		r.updateStatus(ctx, pulpRestore, metav1.ConditionTrue, "RestoreComplete", "All restore tasks run!", "RestoreTasksFinished")
+		log.Info("Restore tasks finished!")
+		return ctrl.Result{}, nil
+	}
+
 	r.updateStatus(ctx, pulpRestore, metav1.ConditionFalse, "RestoreComplete", "Restore process running ...", "StartingRestoreProcess")
 
 	// [TODO] REVIEW THIS
@@ -161,13 +177,9 @@ func (r *PulpRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, err
 	}
 
-	log.Info("Cleaning up restore resources ...")
-	r.updateStatus(ctx, pulpRestore, metav1.ConditionFalse, "RestoreComplete", "Cleaning up restore resources ...", "DeletingMgmtPod")
-
-	r.cleanup(ctx, pulpRestore)
-	r.updateStatus(ctx, pulpRestore, metav1.ConditionTrue, "RestoreComplete", "All restore tasks run!", "RestoreTasksFinished")
-	log.Info("Restore tasks finished!")
-	return ctrl.Result{}, nil
+	// wait for the pulpcore pods to be READY with the restored number of replicas before finishing the restore
+	log.Info("Waiting operator tasks ...")
+	return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -10,12 +10,23 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
 )
 
+const (
+	// deploymentsReadyCondition is the PulpRestore condition with the state of the rescaled pulpcore deployments
+	deploymentsReadyCondition = "DeploymentsReady"
+
+	// deploymentsReadyTimeout is how long the restore waits for the rescaled deployments to be ready
+	deploymentsReadyTimeout = 3 * time.Minute
+)
+
+// PodReplicas are the replicas defined in the pulp CR from backup (nil if not defined)
 type PodReplicas struct {
-	Api, Content, Worker, Web int32
+	Api, Content, Worker, Web *int32
 }
 
 // restorePulpCR recreates the pulp CR with the content from backup
@@ -57,10 +68,11 @@ func (r *PulpRestoreReconciler) restorePulpCR(ctx context.Context, pulpRestore *
 			Web:     pulp.Spec.Web.Replicas,
 		}
 
-		pulp.Spec.Api.Replicas = 0
-		pulp.Spec.Content.Replicas = 0
-		pulp.Spec.Worker.Replicas = 0
-		pulp.Spec.Web.Replicas = 0
+		noReplicas := int32(0)
+		pulp.Spec.Api.Replicas = &noReplicas
+		pulp.Spec.Content.Replicas = &noReplicas
+		pulp.Spec.Worker.Replicas = &noReplicas
+		pulp.Spec.Web.Replicas = &noReplicas
 		if err = r.Create(ctx, &pulp); err != nil {
 			log.Error(err, "Error trying to restore "+pulpRestore.Spec.DeploymentName+" CR!")
 			r.updateStatus(ctx, pulpRestore, metav1.ConditionFalse, "RestoreComplete", "Failed to restore cr_object!", "FailedRestore"+pulpRestore.Spec.DeploymentName+"CR")
@@ -92,11 +104,12 @@ func (r *PulpRestoreReconciler) scaleDeployments(ctx context.Context, pulpRestor
 		pulp.Spec.Worker.Replicas = podReplicas.Worker
 		pulp.Spec.Web.Replicas = podReplicas.Web
 	} else {
-		pulp.Spec.Api.Replicas = 1
-		pulp.Spec.Content.Replicas = 1
-		pulp.Spec.Worker.Replicas = 1
+		replicas := int32(1)
+		pulp.Spec.Api.Replicas = &replicas
+		pulp.Spec.Content.Replicas = &replicas
+		pulp.Spec.Worker.Replicas = &replicas
 		if pulp.Spec.IngressType != "route" && pulp.Spec.IngressType != "Route" {
-			pulp.Spec.Web.Replicas = 1
+			pulp.Spec.Web.Replicas = &replicas
 		}
 	}
 
@@ -105,38 +118,70 @@ func (r *PulpRestoreReconciler) scaleDeployments(ctx context.Context, pulpRestor
 		return err
 	}
 
-	log.Info("Waiting operator tasks ...")
-	// wait operator finish update before proceeding
-	for timeout := 0; timeout < 18; timeout++ {
-		time.Sleep(time.Second * 10)
-
-		// [TODO] we should use the operator status to make sure that it finished its execution, but the
-		// .status.condition is not reflecting the real state.
-		// pulp-api and pulp-web were not READY and Pulp-Operator-Finished-Execution was set to true
-		/* r.Get(ctx, types.NamespacedName{Name: pulpRestore.Spec.DeploymentName, Namespace: pulpRestore.Namespace}, pulp)
-		if v1.IsStatusConditionPresentAndEqual(pulp.Status.Conditions, cases.Title(language.English, cases.Compact).String(pulp.Spec.DeploymentType)+"-Operator-Finished-Execution", metav1.ConditionTrue) {
-			break
-		} */
-
-		apiDeployment := &appsv1.Deployment{}
-		r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-api", Namespace: pulp.Namespace}, apiDeployment)
-		if apiDeployment.Status.ReadyReplicas == apiDeployment.Status.Replicas {
-			break
-		}
+	// restart the wait for the deployments (the LastTransitionTime of the condition is used as the start of the timeout)
+	v1.RemoveStatusCondition(&pulpRestore.Status.Conditions, deploymentsReadyCondition)
+	r.updateStatus(ctx, pulpRestore, metav1.ConditionFalse, deploymentsReadyCondition, "Waiting for the deployments to be ready ...", "WaitingDeployments")
+
+	return nil
+}
+
+// waitingDeployments returns true if the restore tasks already ran and the pulpcore deployments are being rescaled
+func waitingDeployments(pulpRestore *repomanagerv1alpha1.PulpRestore) bool {
+	condition := v1.FindStatusCondition(pulpRestore.Status.Conditions, deploymentsReadyCondition)
+	return condition != nil && condition.Reason == "WaitingDeployments"
+}
+
+// checkDeploymentsReady verifies if the pulp-api pods (and pulp-web pods) are READY with the restored number of
+// replicas. While they are not ready (and the timeout has not been reached) the request is requeued instead of
+// blocking the reconciliation.
+func (r *PulpRestoreReconciler) checkDeploymentsReady(ctx context.Context, pulpRestore *repomanagerv1alpha1.PulpRestore) (ctrl.Result, error) {
+	log := r.RawLogger
+	pulp := &repomanagerv1alpha1.Pulp{}
+	if err := r.Get(ctx, types.NamespacedName{Name: pulpRestore.Spec.DeploymentName, Namespace: pulpRestore.Namespace}, pulp); err != nil {
+		log.Error(err, "Failed to get Pulp CR")
+		return ctrl.Result{}, err
+	}
+
+	deployments := []string{pulp.Name + "-api"}
+	// pulp-web readiness probe checks pulp-api status through nginx, so pulp-web pods are
+	// only READY after pulp-api finished its boot process
+	if pulp.Spec.IngressType != "route" && pulp.Spec.IngressType != "Route" {
+		deployments = append(deployments, pulp.Name+"-web")
 	}
 
-	// There is a small interval in which pulp-web stays in a READY state and crash after a few seconds because pulp-api
-	// didn't finish it boot process. This sleep is a workaround to try to mitigate this.
-	// [TODO] add readiness probe to pulp-web pods
-	time.Sleep(time.Second * 60)
-	for timeout := 0; timeout < 18; timeout++ {
-		webDeployment := &appsv1.Deployment{}
-		r.Get(ctx, types.NamespacedName{Name: pulp.Name + "-web", Namespace: pulp.Namespace}, webDeployment)
-		if webDeployment.Status.ReadyReplicas == webDeployment.Status.Replicas {
-			break
+	for _, deployment := range deployments {
+		if r.deploymentReady(ctx, pulp.Namespace, deployment) {
+			continue
 		}
-		time.Sleep(time.Second * 10)
+
+		condition := v1.FindStatusCondition(pulpRestore.Status.Conditions, deploymentsReadyCondition)
+		if time.Since(condition.LastTransitionTime.Time) < deploymentsReadyTimeout {
+			log.Info("Waiting for " + deployment + " deployment to be ready ...")
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+		}
+
+		log.Info("Timed out waiting for " + deployment + " deployment to be ready")
+		r.updateStatus(ctx, pulpRestore, metav1.ConditionFalse, deploymentsReadyCondition, "Timed out waiting for "+deployment+" deployment to be ready!", "DeploymentsReadyTimeout")
+		return ctrl.Result{}, nil
 	}
 
-	return nil
+	r.updateStatus(ctx, pulpRestore, metav1.ConditionTrue, deploymentsReadyCondition, "All deployments ready", "DeploymentsReady")
+	return ctrl.Result{}, nil
+}
+
+// deploymentReady returns true if the deployment is scaled to the replicas from its spec and all of them are READY.
+// The Status of a deployment that has just been scaled up can still report 0 (ready) out of 0 replicas, so it is
+// only checked after the deployment controller observed the change.
+func (r *PulpRestoreReconciler) deploymentReady(ctx context.Context, namespace, name string) bool {
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, deployment); err != nil {
+		return false
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	return deployment.Status.ObservedGeneration >= deployment.Generation &&
+		deployment.Status.UpdatedReplicas == replicas &&
+		deployment.Status.ReadyReplicas == replicas
 }
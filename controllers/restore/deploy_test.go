@@ -0,0 +1,111 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pulp_restore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCheckDeploymentsReady(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	repomanagerv1alpha1.AddToScheme(scheme)
+
+	replicas := int32(2)
+	tests := []struct {
+		name            string
+		readyReplicas   int32
+		waitingSince    time.Duration
+		expectedRequeue bool
+		expectedReason  string
+	}{
+		{
+			name:            "deployments not ready",
+			readyReplicas:   1,
+			waitingSince:    time.Minute,
+			expectedRequeue: true,
+			expectedReason:  "WaitingDeployments",
+		},
+		{
+			name:           "deployments not ready after the timeout",
+			readyReplicas:  1,
+			waitingSince:   deploymentsReadyTimeout + time.Minute,
+			expectedReason: "DeploymentsReadyTimeout",
+		},
+		{
+			name:           "deployments ready",
+			readyReplicas:  2,
+			waitingSince:   time.Minute,
+			expectedReason: "DeploymentsReady",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pulp := &repomanagerv1alpha1.Pulp{
+				ObjectMeta: metav1.ObjectMeta{Name: "pulp", Namespace: "pulp"},
+			}
+			pulp.Spec.IngressType = "route"
+			pulpRestore := &repomanagerv1alpha1.PulpRestore{
+				ObjectMeta: metav1.ObjectMeta{Name: "pulp-restore", Namespace: "pulp"},
+				Spec:       repomanagerv1alpha1.PulpRestoreSpec{DeploymentName: "pulp"},
+			}
+			v1.SetStatusCondition(&pulpRestore.Status.Conditions, metav1.Condition{
+				Type:               deploymentsReadyCondition,
+				Status:             metav1.ConditionFalse,
+				Reason:             "WaitingDeployments",
+				LastTransitionTime: metav1.NewTime(time.Now().Add(-tt.waitingSince)),
+			})
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "pulp-api", Namespace: "pulp"},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				Status:     appsv1.DeploymentStatus{UpdatedReplicas: replicas, ReadyReplicas: tt.readyReplicas},
+			}
+
+			r := &PulpRestoreReconciler{
+				Client:    fake.NewClientBuilder().WithScheme(scheme).WithObjects([]client.Object{pulp, pulpRestore, deployment}...).Build(),
+				RawLogger: logr.Discard(),
+				Scheme:    scheme,
+			}
+			result, err := r.checkDeploymentsReady(context.TODO(), pulpRestore)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if requeue := result.RequeueAfter > 0; requeue != tt.expectedRequeue {
+				t.Errorf("got requeue %v, expected %v", requeue, tt.expectedRequeue)
+			}
+			if condition := v1.FindStatusCondition(pulpRestore.Status.Conditions, deploymentsReadyCondition); condition.Reason != tt.expectedReason {
+				t.Errorf("got reason %v, expected %v", condition.Reason, tt.expectedReason)
+			}
+			if waiting := waitingDeployments(pulpRestore); waiting != tt.expectedRequeue {
+				t.Errorf("got waitingDeployments %v, expected %v", waiting, tt.expectedRequeue)
+			}
+		})
+	}
+}
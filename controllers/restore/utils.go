@@ -157,11 +157,13 @@ func (r *PulpRestoreReconciler) createRestorePod(ctx context.Context, pulpRestor
 					"sleep",
 					"infinity",
 				},
-				VolumeMounts:   volumeMounts,
-				ReadinessProbe: readinessProbe,
+				VolumeMounts:    volumeMounts,
+				ReadinessProbe:  readinessProbe,
+				SecurityContext: pulpRestore.Spec.SecurityContext,
 			}},
-			Volumes:       volumes,
-			RestartPolicy: corev1.RestartPolicyNever,
+			SecurityContext: pulpRestore.Spec.PodSecurityContext,
+			Volumes:         volumes,
+			RestartPolicy:   corev1.RestartPolicyNever,
 		},
 	}
 	err := r.Get(ctx, types.NamespacedName{Name: pulpRestore.Name + "-backup-manager", Namespace: pulpRestore.Namespace}, restorePod)
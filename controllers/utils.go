@@ -2,8 +2,10 @@ package controllers
 
 import (
 	"bytes"
+	"io"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	repomanagerv1alpha1 "github.com/pulp/pulp-operator/api/v1alpha1"
@@ -43,8 +45,32 @@ func IgnoreUpdateCRStatusPredicate() predicate.Predicate {
 	}
 }
 
-// IsOpenShift returns true if the platform cluster is OpenShift
+// openShift caches the result of IsOpenShift, the platform does not change while the operator is running
+var openShift struct {
+	sync.Mutex
+	detected, isOpenShift bool
+}
+
+// IsOpenShift returns true if the platform cluster is OpenShift.
+// The platform is only detected once (the errors are not cached, so it is detected again
+// in the next call), instead of querying the discovery API in every reconciliation.
 func IsOpenShift() (bool, error) {
+	openShift.Lock()
+	defer openShift.Unlock()
+	if openShift.detected {
+		return openShift.isOpenShift, nil
+	}
+	isOpenShift, err := IsAPIAvailable("config.openshift.io/v1")
+	if err != nil {
+		return false, err
+	}
+	openShift.detected, openShift.isOpenShift = true, isOpenShift
+	return isOpenShift, nil
+}
+
+// IsAPIAvailable returns true if the groupVersion (for example, "monitoring.coreos.com/v1")
+// is served by the cluster
+func IsAPIAvailable(groupVersion string) (bool, error) {
 	cfg, err := config.GetConfig()
 	if err != nil {
 		return false, err
@@ -54,7 +80,7 @@ func IsOpenShift() (bool, error) {
 		return false, err
 	}
 
-	_, err = client.ServerResourcesForGroupVersion("config.openshift.io/v1")
+	_, err = client.ServerResourcesForGroupVersion(groupVersion)
 
 	if err != nil && errors.IsNotFound(err) {
 		return false, nil
@@ -129,6 +155,12 @@ func MultiStorageConfigured(pulp *repomanagerv1alpha1.Pulp, resource string) (bo
 
 // ContainerExec runs a command in the container
 func ContainerExec[T any](client T, pod *corev1.Pod, command []string, container, namespace string) (string, error) {
+	return ContainerExecWithStdin(client, pod, command, nil, container, namespace)
+}
+
+// ContainerExecWithStdin runs a command in the container with stdin as its standard input,
+// which should be used to provide the secrets that cannot be passed in the command arguments
+func ContainerExecWithStdin[T any](client T, pod *corev1.Pod, command []string, stdin io.Reader, container, namespace string) (string, error) {
 
 	// get the concrete value of client ({PulpBackup,PulpBackupReconciler,PulpRestoreReconciler})
 	clientConcrete := reflect.ValueOf(client)
@@ -152,6 +184,7 @@ func ContainerExec[T any](client T, pod *corev1.Pod, command []string, container
 		VersionedParams(&corev1.PodExecOptions{
 			Container: container,
 			Command:   command,
+			Stdin:     stdin != nil,
 			Stdout:    true,
 			Stderr:    true,
 		}, runtime.NewParameterCodec(&runtimeScheme))
@@ -164,6 +197,7 @@ func ContainerExec[T any](client T, pod *corev1.Pod, command []string, container
 	stdout := new(bytes.Buffer)
 	stderr := new(bytes.Buffer)
 	err = exec.Stream(remotecommand.StreamOptions{
+		Stdin:  stdin,
 		Stdout: stdout,
 		Stderr: stderr,
 		Tty:    false,
@@ -228,3 +262,100 @@ func CheckEmptyDir(pulp *repomanagerv1alpha1.Pulp, resource string) {
 		logger.Warn("CONFIGURING " + strings.ToUpper(resource) + " POD VOLUME AS EMPTYDIR. THIS SHOULD NOT BE USED IN PRODUCTION CLUSTERS.")
 	}
 }
+
+// PostgresCertsMountPath is where the client certificates from the external database
+// secret are mounted in the pods that connect to the database
+const PostgresCertsMountPath = "/etc/pulp/postgres-certs"
+
+// postgresCertFiles maps the external database secret keys to the libpq
+// connection parameters and the name of the files mounted in the pods
+var postgresCertFiles = []struct{ secretKey, option, file string }{
+	{"POSTGRES_SSLROOTCERT", "sslrootcert", "root.crt"},
+	{"POSTGRES_SSLCERT", "sslcert", "postgresql.crt"},
+	{"POSTGRES_SSLKEY", "sslkey", "postgresql.key"},
+}
+
+// PostgresCertsVolume returns the volume with the client certificates from the external database secret.
+// The volume is marked as optional because all the certificate keys are optional.
+func PostgresCertsVolume(secretName string) corev1.Volume {
+	// libpq only accepts private keys with 0600 or, if owned by root, 0640 permissions
+	mode := int32(0640)
+	optional := true
+	items := []corev1.KeyToPath{}
+	for _, cert := range postgresCertFiles {
+		items = append(items, corev1.KeyToPath{Key: cert.secretKey, Path: cert.file})
+	}
+
+	return corev1.Volume{
+		Name: "postgres-certs",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  secretName,
+				Items:       items,
+				DefaultMode: &mode,
+				Optional:    &optional,
+			},
+		},
+	}
+}
+
+// PostgresCertsVolumeMount returns the mount point of PostgresCertsVolume
+func PostgresCertsVolumeMount() corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      "postgres-certs",
+		MountPath: PostgresCertsMountPath,
+		ReadOnly:  true,
+	}
+}
+
+// PostgresConnectionOptions returns the libpq connection parameters defined in the external database secret:
+// POSTGRES_SSLMODE, the client certificates (POSTGRES_SSLROOTCERT, POSTGRES_SSLCERT, POSTGRES_SSLKEY)
+// and the "key=value" pairs from POSTGRES_EXTRA_OPTIONS (for example, "connect_timeout=10 keepalives_idle=60")
+func PostgresConnectionOptions(secretData map[string][]byte) map[string]string {
+	options := map[string]string{}
+	for _, option := range strings.Fields(string(secretData["POSTGRES_EXTRA_OPTIONS"])) {
+		if key, value, found := strings.Cut(option, "="); found && len(key) > 0 {
+			options[key] = value
+		}
+	}
+	if sslMode := string(secretData["POSTGRES_SSLMODE"]); len(sslMode) > 0 {
+		options["sslmode"] = sslMode
+	}
+	for _, cert := range postgresCertFiles {
+		if len(secretData[cert.secretKey]) > 0 {
+			options[cert.option] = PostgresCertsMountPath + "/" + cert.file
+		}
+	}
+	return options
+}
+
+// RestrictedPodSecurityContext returns the pod security context compliant with the "restricted"
+// Pod Security Standard used by default in the pods deployed by the operator.
+// On OpenShift, the user, fsGroup and seccomp profile are not defined because they are assigned
+// by the SCC (the legacy restricted SCC rejects the pods defining a seccomp profile).
+func RestrictedPodSecurityContext(user *int64) *corev1.PodSecurityContext {
+	runAsNonRoot := true
+	podSecurityContext := &corev1.PodSecurityContext{
+		RunAsNonRoot: &runAsNonRoot,
+	}
+	if IsOpenShift, _ := IsOpenShift(); IsOpenShift {
+		return podSecurityContext
+	}
+	podSecurityContext.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	if user != nil {
+		podSecurityContext.RunAsUser = user
+		podSecurityContext.FSGroup = user
+	}
+	return podSecurityContext
+}
+
+// RestrictedSecurityContext returns the container security context compliant with the "restricted"
+// Pod Security Standard used by default in the containers deployed by the operator
+func RestrictedSecurityContext(readOnlyRootFilesystem bool) *corev1.SecurityContext {
+	allowPrivilegeEscalation := false
+	return &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+		ReadOnlyRootFilesystem:   &readOnlyRootFilesystem,
+	}
+}
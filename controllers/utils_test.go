@@ -0,0 +1,97 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestPostgresCertsVolume(t *testing.T) {
+	volume := PostgresCertsVolume("external-database")
+
+	if volume.Secret == nil || volume.Secret.SecretName != "external-database" {
+		t.Fatalf("expected a volume from the external-database secret, got %+v", volume.VolumeSource)
+	}
+	if *volume.Secret.DefaultMode != 0640 {
+		t.Errorf("expected mode 0640, got %#o", *volume.Secret.DefaultMode)
+	}
+	if !*volume.Secret.Optional {
+		t.Errorf("expected an optional volume")
+	}
+	expectedItems := []corev1.KeyToPath{
+		{Key: "POSTGRES_SSLROOTCERT", Path: "root.crt"},
+		{Key: "POSTGRES_SSLCERT", Path: "postgresql.crt"},
+		{Key: "POSTGRES_SSLKEY", Path: "postgresql.key"},
+	}
+	if !reflect.DeepEqual(volume.Secret.Items, expectedItems) {
+		t.Errorf("got items %v, expected %v", volume.Secret.Items, expectedItems)
+	}
+	if mount := PostgresCertsVolumeMount(); mount.Name != volume.Name || mount.MountPath != PostgresCertsMountPath || !mount.ReadOnly {
+		t.Errorf("unexpected volume mount %+v", mount)
+	}
+}
+
+func TestPostgresConnectionOptions(t *testing.T) {
+	tests := []struct {
+		name       string
+		secretData map[string][]byte
+		expected   map[string]string
+	}{
+		{
+			name:       "no options",
+			secretData: map[string][]byte{"POSTGRES_HOST": []byte("postgres.example.com")},
+			expected:   map[string]string{},
+		},
+		{
+			name: "certificates, sslmode and extra options",
+			secretData: map[string][]byte{
+				"POSTGRES_SSLMODE":       []byte("verify-full"),
+				"POSTGRES_SSLROOTCERT":   []byte("root-ca"),
+				"POSTGRES_SSLCERT":       []byte("client-cert"),
+				"POSTGRES_SSLKEY":        []byte("client-key"),
+				"POSTGRES_EXTRA_OPTIONS": []byte("connect_timeout=10  keepalives_idle=60 invalid =empty-key"),
+			},
+			expected: map[string]string{
+				"sslmode":         "verify-full",
+				"sslrootcert":     PostgresCertsMountPath + "/root.crt",
+				"sslcert":         PostgresCertsMountPath + "/postgresql.crt",
+				"sslkey":          PostgresCertsMountPath + "/postgresql.key",
+				"connect_timeout": "10",
+				"keepalives_idle": "60",
+			},
+		},
+		{
+			name: "sslmode takes precedence over the extra options",
+			secretData: map[string][]byte{
+				"POSTGRES_SSLMODE":       []byte("require"),
+				"POSTGRES_EXTRA_OPTIONS": []byte("sslmode=disable"),
+			},
+			expected: map[string]string{"sslmode": "require"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if options := PostgresConnectionOptions(tt.secretData); !reflect.DeepEqual(options, tt.expected) {
+				t.Errorf("got %v, expected %v", options, tt.expected)
+			}
+		})
+	}
+}